@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// actionID is what action payloads accept as an action ID.
+var actionID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// actionPayload encodes a bridge code running the action named by the
+// action option, an ID in the bridge's -actions file such as start-stream
+// or mute-mic, then posting the message to the bridge's -webhook. As with
+// matrix codes, the message is referenced by ID by default, by=text embeds
+// it and by=none only runs the action.
+func actionPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	action := opts["action"]
+	if action == "" {
+		return "", errors.New("action payloads need -payload-opt action=…, an action ID in the bridge's -actions file")
+	}
+	if !actionID.MatchString(action) {
+		return "", fmt.Errorf("action ID %q must be letters, digits, _, . or -", action)
+	}
+	return messageBridgeCode("action", action, msg, opts["by"])
+}
+
+// messageBridgeCode builds a bridge code for service addressed to to that
+// also posts msg: referenced by ID for by "" or id, embedded for text, or
+// not at all for none.
+func messageBridgeCode(service, to string, msg ChatMsg, by string) (string, error) {
+	switch by {
+	case "", "id":
+		return bridgeCode(service, []string{to, msg.Key()}, nil), nil
+	case "text":
+		return bridgeCode(service, []string{to}, url.Values{"text": {msg.Code}}), nil
+	case "none":
+		return bridgeCode(service, []string{to}, nil), nil
+	}
+	return "", fmt.Errorf("%s payloads reference messages by id, text or none, not %q", service, by)
+}
+
+// actionSet is a bridge's -actions file: what each action ID scanned in
+// action codes does in OBS Studio and on Stream Deck buttons, e.g.
+//
+//	{"obs": {"url": "ws://localhost:4455", "password": "…"},
+//	 "companion": "http://localhost:8000",
+//	 "actions": {
+//	   "start-stream": {"obs": "StartStream"},
+//	   "mute-mic": {"obs": "ToggleInputMute", "data": {"inputName": "Mic/Aux"}},
+//	   "brb": {"obs": "SetCurrentProgramScene", "data": {"sceneName": "BRB"}, "press": "1/0/3"}}}
+type actionSet struct {
+	OBS obsClient `json:"obs"`
+	// Companion is the URL of Bitfocus Companion, which the Stream Deck
+	// buttons of Press are pressed through.
+	Companion string            `json:"companion,omitempty"`
+	Actions   map[string]action `json:"actions"`
+}
+
+// action is what one action ID does, in order: an OBS request, if any,
+// then a Stream Deck button press.
+type action struct {
+	// OBS is an obs-websocket request type, e.g. StartStream, with Data
+	// its fields.
+	OBS  string         `json:"obs,omitempty"`
+	Data map[string]any `json:"data,omitempty"`
+	// Press is the Companion button to press, as page/row/column.
+	Press string `json:"press,omitempty"`
+}
+
+// loadActions reads an -actions file; an empty path means no actions.
+func loadActions(path string) (*actionSet, error) {
+	if path == "" {
+		return &actionSet{}, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set actionSet
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for id, a := range set.Actions {
+		switch {
+		case a.OBS == "" && a.Press == "":
+			return nil, fmt.Errorf("%s: action %q has no obs request or press", path, id)
+		case a.OBS != "" && set.OBS.URL == "":
+			return nil, fmt.Errorf("%s: action %q needs an obs url", path, id)
+		case a.Press != "" && set.Companion == "":
+			return nil, fmt.Errorf("%s: action %q needs a companion URL", path, id)
+		case a.Press != "" && strings.Count(a.Press, "/") != 2:
+			return nil, fmt.Errorf("%s: action %q presses %q, want page/row/column", path, id, a.Press)
+		}
+	}
+	return &set, nil
+}
+
+// service runs the action of bridge codes of the form
+// cb://action/<action>/<id>, then posts their message with post.
+func (s *actionSet) service(set *messageSet, post func(text string) error) bridgeService {
+	return func(u *url.URL, path []string) error {
+		if len(path) == 0 {
+			return fmt.Errorf("%s: no action", u)
+		}
+		a, ok := s.Actions[path[0]]
+		if !ok {
+			return fmt.Errorf("%s: no action %q in -actions (want one of %s)", u, path[0], s.names())
+		}
+		if a.OBS != "" {
+			if err := s.OBS.Request(a.OBS, a.Data); err != nil {
+				return err
+			}
+		}
+		if a.Press != "" {
+			endpoint := strings.TrimSuffix(s.Companion, "/") + "/api/location/" + a.Press + "/press"
+			if err := sendJSON(http.MethodPost, endpoint, nil, nil); err != nil {
+				return fmt.Errorf("companion: %w", err)
+			}
+		}
+		return postBridgeText(set, u, path, post)
+	}
+}
+
+// postBridgeText posts the message of a code from messageBridgeCode, if it
+// has one, with post.
+func postBridgeText(set *messageSet, u *url.URL, path []string, post func(text string) error) error {
+	if len(path) < 2 && u.Query().Get("text") == "" {
+		return nil
+	}
+	text, err := bridgeText(set, u, path, 1)
+	if err != nil {
+		return err
+	}
+	return post(text)
+}
+
+func (s *actionSet) names() string {
+	names := make([]string, 0, len(s.Actions))
+	for name := range s.Actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
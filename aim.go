@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// aimID matches an AIM symbology identifier such as "]Q1" (QR Code) or
+// "]C0" (Code 128): a "]", a code character and a modifier character.
+// Scanners configured to transmit them prepend one to every scan.
+var aimID = regexp.MustCompile(`^\][A-Za-z][0-9A-Za-z]`)
+
+// stripAIM removes a leading AIM symbology identifier from scan.
+func stripAIM(scan string) string {
+	return aimID.ReplaceAllLiteralString(scan, "")
+}
+
+// checkAIMSafe reports payloads that themselves start with something that
+// looks like an AIM identifier; stripping would eat the start of the
+// message when the scanner doesn't actually send identifiers.
+func checkAIMSafe(msgs []ChatMsg) error {
+	for _, msg := range msgs {
+		if aimID.MatchString(msg.Code) {
+			return fmt.Errorf("payload %q starts with an AIM identifier lookalike; it can't be told apart from a scanner prefix", msg.Code)
+		}
+	}
+	return nil
+}
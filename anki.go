@@ -0,0 +1,197 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// The tables of an Anki 2 collection, see
+// https://github.com/ankidroid/Anki-Android/wiki/Database-Structure.
+var ankiSchema = []struct {
+	name, sql string
+	indexes   []sqliteIndex
+}{
+	{"col", "CREATE TABLE col (id integer primary key, crt integer not null, mod integer not null, scm integer not null, ver integer not null, dty integer not null, usn integer not null, ls integer not null, conf text not null, models text not null, decks text not null, dconf text not null, tags text not null)", nil},
+	{"notes", "CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null, usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null, flags integer not null, data text not null)", []sqliteIndex{
+		{"ix_notes_usn", "CREATE INDEX ix_notes_usn on notes (usn)", []int{4}},
+		{"ix_notes_csum", "CREATE INDEX ix_notes_csum on notes (csum)", []int{8}},
+	}},
+	{"cards", "CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null, mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null, ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null, odue integer not null, odid integer not null, flags integer not null, data text not null)", []sqliteIndex{
+		{"ix_cards_usn", "CREATE INDEX ix_cards_usn on cards (usn)", []int{5}},
+		{"ix_cards_nid", "CREATE INDEX ix_cards_nid on cards (nid)", []int{1}},
+		{"ix_cards_sched", "CREATE INDEX ix_cards_sched on cards (did, queue, due)", []int{2, 7, 8}},
+	}},
+	{"revlog", "CREATE TABLE revlog (id integer primary key, cid integer not null, usn integer not null, ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null, type integer not null)", []sqliteIndex{
+		{"ix_revlog_usn", "CREATE INDEX ix_revlog_usn on revlog (usn)", []int{2}},
+		{"ix_revlog_cid", "CREATE INDEX ix_revlog_cid on revlog (cid)", []int{1}},
+	}},
+	{"graves", "CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null)", nil},
+}
+
+// ankiCSS styles the cards of exported decks.
+const ankiCSS = `.card { font-family: sans-serif; font-size: 20px; text-align: center; }
+.card img { max-width: 100%; }
+.payload { font-family: monospace; white-space: pre-wrap; word-break: break-all; text-align: left; }
+.description { font-size: 16px; color: #666; margin-top: 1em; }`
+
+// ankiID returns a stable note type or deck ID for name, so importing a
+// newer export updates the deck rather than adding another.
+func ankiID(name string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return 1<<30 + int64(h.Sum32()>>2)
+}
+
+// writeAnki writes the cells of l as an Anki deck to path, a card per
+// message with its code on the front and its payload on the back, and
+// returns the number of cards.
+func writeAnki(path string, l *layout) (int, error) {
+	name := l.set.Name
+	if name == "" {
+		name = "chat-barcodes"
+	}
+	media := name
+	if l.set.Language != "" {
+		media += "-" + l.set.Language
+	}
+	deckID := ankiID("deck " + l.opts.Title)
+	modelID := ankiID("notetype " + name)
+	now := time.Now()
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	files := map[string]string{}
+	var notes, cards [][]any
+	for i, c := range l.manifest.Cells {
+		if strings.ContainsAny(c.ID, `/\`) {
+			return 0, fmt.Errorf("message id %q can't name an Anki image", c.ID)
+		}
+		// The front shows the code as printed, but not the description.
+		cell := l.cells[i]
+		cell.Description, cell.Mark = "", ""
+		width, height := l.opts.CellSize(l.cells, i)
+		img, err := sheet.RenderCell(l.opts, cell, width, height)
+		if err != nil {
+			return 0, err
+		}
+		w, err := z.Create(strconv.Itoa(i))
+		if err != nil {
+			return 0, err
+		}
+		if err := png.Encode(w, img); err != nil {
+			return 0, err
+		}
+		file := media + "-" + c.ID + ".png"
+		files[strconv.Itoa(i)] = file
+
+		label := l.cells[i].Label
+		if label == "" {
+			label = c.Payload
+		}
+		fields := []string{
+			`<img src="` + html.EscapeString(file) + `">`,
+			html.EscapeString(label),
+			html.EscapeString(c.Payload),
+			html.EscapeString(l.cells[i].Description),
+		}
+		var tags string
+		if category := strings.TrimSpace(l.cells[i].Category); category != "" {
+			tags = " " + strings.ReplaceAll(category, " ", "_") + " "
+		}
+		// The checksum is of the first field, its HTML stripped but media
+		// file names kept.
+		sum := sha1.Sum([]byte(file))
+		guid := sha256.Sum256([]byte(media + "\x00" + c.ID))
+		id := now.UnixMilli() + int64(i)
+		notes = append(notes, []any{id, hex.EncodeToString(guid[:5]), modelID, now.Unix(), int64(-1), tags,
+			strings.Join(fields, "\x1f"), label, int64(binary.BigEndian.Uint32(sum[:4])), int64(0), ""})
+		cards = append(cards, []any{id, id, deckID, int64(0), now.Unix(), int64(-1), int64(0), int64(0), int64(i + 1),
+			int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), ""})
+	}
+
+	field := func(name string, ord int) map[string]any {
+		return map[string]any{"name": name, "ord": ord, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{}}
+	}
+	models := map[string]any{strconv.FormatInt(modelID, 10): map[string]any{
+		"id": modelID, "name": name, "type": 0, "mod": now.Unix(), "usn": -1, "sortf": 1, "did": deckID,
+		"flds": []any{field("Code", 0), field("Label", 1), field("Payload", 2), field("Description", 3)},
+		"tmpls": []any{map[string]any{
+			"name": "Code", "ord": 0, "did": nil, "bqfmt": "", "bafmt": "",
+			"qfmt": "{{Code}}",
+			"afmt": `{{FrontSide}}<hr id=answer><div class=payload>{{Payload}}</div><div class=description>{{Description}}</div>`,
+		}},
+		"css": ankiCSS, "req": []any{[]any{0, "any", []int{0}}}, "tags": []string{}, "vers": []string{},
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+	}}
+	deck := func(id int64, name string) map[string]any {
+		return map[string]any{
+			"id": id, "name": name, "desc": "", "mod": now.Unix(), "usn": -1, "dyn": 0, "conf": 1,
+			"collapsed": false, "browserCollapsed": false, "extendNew": 10, "extendRev": 50,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	decks := map[string]any{"1": deck(1, "Default"), strconv.FormatInt(deckID, 10): deck(deckID, l.opts.Title)}
+	dconf := map[string]any{"1": map[string]any{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "dyn": false, "maxTaken": 60, "timer": 0, "autoplay": true, "replayq": true,
+		"new":   map[string]any{"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500, "order": 1, "perDay": 20, "bury": true, "separate": true},
+		"lapse": map[string]any{"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0},
+		"rev":   map[string]any{"perDay": 200, "ease4": 1.3, "fuzz": 0.05, "minSpace": 1, "ivlFct": 1, "maxIvl": 36500, "bury": true},
+	}}
+	conf := map[string]any{
+		"nextPos": len(cards) + 1, "estTimes": true, "activeDecks": []int64{1}, "sortType": "noteFld", "timeLim": 0,
+		"sortBackwards": false, "addToCur": true, "curDeck": 1, "newSpread": 0, "dueCounts": true,
+		"curModel": strconv.FormatInt(modelID, 10), "collapseTime": 1200,
+	}
+	var col []any
+	col = append(col, int64(1), now.Unix(), now.UnixMilli(), now.UnixMilli(), int64(11), int64(0), int64(0), int64(0))
+	for _, v := range []any{conf, models, decks, dconf, map[string]any{}} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return 0, err
+		}
+		col = append(col, string(b))
+	}
+
+	rows := map[string][][]any{"col": {col}, "notes": notes, "cards": cards}
+	tables := make([]sqliteTable, len(ankiSchema))
+	for i, t := range ankiSchema {
+		tables[i] = sqliteTable{name: t.name, sql: t.sql, key: t.name != "graves", rows: rows[t.name], indexes: t.indexes}
+	}
+	w, err := z.Create("collection.anki2")
+	if err != nil {
+		return 0, err
+	}
+	if err := writeSQLite(w, tables); err != nil {
+		return 0, err
+	}
+	b, err := json.Marshal(files)
+	if err != nil {
+		return 0, err
+	}
+	if w, err = z.Create("media"); err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return 0, err
+	}
+	if err := z.Close(); err != nil {
+		return 0, err
+	}
+	return len(cards), os.WriteFile(path, buf.Bytes(), 0o644)
+}
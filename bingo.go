@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// bingoSize is the number of cells across and down a bingo card, the
+// middle one free.
+const bingoSize = 5
+
+// dealBingo returns msgs dealt into cards bingo cards, one after another: a
+// different random choice and order of messages on each, with a free cell
+// in the middle whose code calls out the card's win. The same seed deals
+// the same cards.
+func dealBingo(msgs []ChatMsg, cards int, seed uint64) ([]ChatMsg, error) {
+	squares := bingoSize*bingoSize - 1
+	if len(msgs) < squares {
+		return nil, fmt.Errorf("-bingo needs at least %d messages, the set has %d", squares, len(msgs))
+	}
+	r := rand.New(rand.NewPCG(seed, 0))
+	dealt := make([]ChatMsg, 0, cards*(squares+1))
+	seen := map[string]bool{}
+	for card := 1; card <= cards; card++ {
+		var picks []int
+		// Deal again on the rare repeat, so every participant's card is
+		// their own.
+		for {
+			picks = r.Perm(len(msgs))[:squares]
+			if key := fmt.Sprint(picks); !seen[key] {
+				seen[key] = true
+				break
+			}
+		}
+		for i, p := range picks {
+			if i == squares/2 {
+				dealt = append(dealt, freeSquare(card))
+			}
+			m := msgs[p]
+			m.ID, m.Size = m.Key(), 0
+			dealt = append(dealt, m)
+		}
+	}
+	return dealt, nil
+}
+
+// freeSquare returns the free middle cell of card, scanned to claim a win.
+func freeSquare(card int) ChatMsg {
+	return ChatMsg{
+		ID:          fmt.Sprintf("bingo-%d", card),
+		Code:        fmt.Sprintf("BINGO! (card %d)", card),
+		Label:       "FREE",
+		Description: fmt.Sprintf("Card %d. Scan to call bingo when you have a line.", card),
+	}
+}
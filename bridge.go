@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// webhookFormats build the JSON body a chat webhook expects for a message.
+var webhookFormats = map[string]func(msg string) any{
+	"slack":   func(msg string) any { return map[string]string{"text": msg} },
+	"discord": func(msg string) any { return map[string]string{"content": msg} },
+	"teams":   func(msg string) any { return map[string]string{"text": msg} },
+	"generic": func(msg string) any { return map[string]string{"text": msg} },
+	// Mattermost and Rocket.Chat incoming webhooks post to the channel they
+	// were created for.
+	"mattermost": func(msg string) any { return map[string]string{"text": msg} },
+	"rocketchat": func(msg string) any { return map[string]string{"text": msg} },
+	"googlechat": func(msg string) any { return map[string]string{"text": msg} },
+}
+
+var errNoWebhook = errors.New("no -webhook for plain messages")
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// runBridge implements `chat-barcodes bridge`, a long-running process that
+// posts every scan to a chat webhook. With a scanner plugged into a
+// Raspberry Pi this makes a standalone "chat button board" that doesn't
+// need any window to have keyboard focus.
+//
+// Plain scans go to -webhook; bridge codes (see bridgeCode) are routed to
+// the service they name.
+func runBridge(args []string) error {
+	fs := flag.NewFlagSet("bridge", flag.ExitOnError)
+	device := fs.String("device", "", "serial port, or /dev/input/event* device for keyboard wedge scanners")
+	baud := fs.Int("baud", 9600, "serial baud rate")
+	stripAIMIDs := fs.Bool("strip-aim", true, "remove AIM symbology identifiers (]Q1 etc.) the scanner prepends")
+	webhook := fs.String("webhook", "", "incoming webhook URL plain messages are posted to")
+	messagesPath := fs.String("messages", "", "JSON message set bridge codes reference messages from")
+	discordHooks := keyValueFlag{}
+	fs.Var(discordHooks, "discord", "Discord webhook for a channel name used in codes, as name=URL, may be repeated")
+	matrixRooms := keyValueFlag{}
+	fs.Var(matrixRooms, "matrix-room", "Matrix room ID for a room name used in codes, as name=!id:server, may be repeated")
+	matrixHomeserver := fs.String("matrix-homeserver", "", "Matrix homeserver URL, e.g. https://matrix.org")
+	matrixToken := fs.String("matrix-token", "", "access token of the Matrix bot user")
+	manifestPath := fs.String("manifest", "", "manifest whose shortened payloads are served under /s/, and whose -numbers and -indirect IDs are posted as their messages")
+	readStdin := fs.Bool("stdin", false, "also read scans (or typed issue links) from standard input")
+	comments := commenter{}
+	fs.StringVar(&comments.GitHubAPI, "github-api", "https://api.github.com", "GitHub API URL")
+	fs.StringVar(&comments.GitHubToken, "github-token", "", "GitHub token comment codes are posted with")
+	fs.StringVar(&comments.GitHubRepo, "github-repo", "", "owner/repo that #123 issue references refer to")
+	fs.StringVar(&comments.JiraURL, "jira-url", "", "Jira base URL, e.g. https://example.atlassian.net")
+	fs.StringVar(&comments.JiraUser, "jira-user", "", "Jira user comment codes are posted as")
+	fs.StringVar(&comments.JiraToken, "jira-token", "", "Jira API token")
+	homeAssistant := fs.String("homeassistant", "", "Home Assistant URL homeassistant codes trigger webhooks on, e.g. http://homeassistant.local:8123")
+	actionsPath := fs.String("actions", "", "JSON file of the OBS requests and Stream Deck buttons action codes' IDs run")
+	format := fs.String("format", "slack", "webhook payload format: "+webhookFormatNames())
+	listen := fs.String("listen", "", "address to serve trigger, token and short URLs on, e.g. :8080")
+	secret := fs.String("trigger-secret", "", "secret trigger URLs are signed with")
+	decryptKey := fs.String("decrypt-key", "", "secret key file from keygen, opening payloads sealed with -encrypt-to")
+	signSecret := fs.String("sign-secret", "", "secret sheets were signed with; scans of other codes aren't posted")
+	tokenSecret := fs.String("token-secret", "", "secret token codes were generated with (-payload-opt secret=…)")
+	speech := speechFlags(fs)
+	mqtt := mqttFlags(fs)
+	scanLog := scanLogFlags(fs)
+	fields := placeholderFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	body, ok := webhookFormats[*format]
+	if !ok {
+		return fmt.Errorf("unknown webhook format %q", *format)
+	}
+
+	if *device == "" && *listen == "" && !*readStdin {
+		return errors.New("need a -device or -stdin to read scans from and/or a -listen address")
+	}
+
+	set, err := loadMessages(*messagesPath)
+	if err != nil {
+		return err
+	}
+	postPlain := func(text string) error {
+		if *webhook == "" {
+			return errNoWebhook
+		}
+		return postJSON(*webhook, body(text))
+	}
+	// Without a secret anyone could work out the tokens, so none are taken.
+	var tokens map[string]string
+	if *tokenSecret != "" {
+		tokens = tokenIndex(set, *tokenSecret)
+	}
+	var m *manifest
+	var numbers map[string]string
+	if *manifestPath != "" {
+		if m, err = loadManifest(*manifestPath); err != nil {
+			return err
+		}
+		numbers = m.scanPayloads()
+	}
+	var key *ecdh.PrivateKey
+	if *decryptKey != "" {
+		if key, err = loadSecretKey(*decryptKey); err != nil {
+			return err
+		}
+	}
+
+	actions, err := loadActions(*actionsPath)
+	if err != nil {
+		return err
+	}
+
+	comments.set = set
+	services := map[string]bridgeService{
+		"t":       tokenService(tokens, postPlain),
+		"comment": comments.service,
+		"discord": discordService(set, discordHooks),
+		"matrix":  matrixService(set, *matrixHomeserver, *matrixToken, matrixRooms),
+		"action":  actions.service(set, postPlain),
+		"ha":      homeAssistantService(set, *homeAssistant, postPlain),
+	}
+
+	post := func(msg string) error {
+		if comments.Select(msg) {
+			log.Printf("bridge: comment codes now go to %s", msg)
+			return nil
+		}
+		msg = fields.resolve(msg)
+		var err error
+		if u, path, ok := parseBridgeCode(msg); ok {
+			if svc, ok := services[u.Host]; ok {
+				err = svc(u, path)
+			} else {
+				err = fmt.Errorf("unknown bridge service %q", u.Host)
+			}
+		} else {
+			err = postPlain(msg)
+		}
+		// A chat outage shouldn't take the bridge down; log and carry on
+		// with the next scan.
+		if err != nil {
+			log.Printf("bridge: posting %q: %v", msg, err)
+			return err
+		}
+		log.Printf("bridge: posted %q", msg)
+		return nil
+	}
+
+	// opened returns the message of a scan or scanned number, opened if it
+	// was sealed and if its signature checks out, and scanned posts it.
+	// Errors are logged and the bridge keeps reading.
+	speaker := speech(m)
+	publisher, err := mqtt(m)
+	if err != nil {
+		return err
+	}
+	scans, err := scanLog(m)
+	if err != nil {
+		return err
+	}
+	opened := func(scan string) (string, bool) {
+		msg, err := openScan(key, expandNumber(numbers, scan))
+		if err == nil {
+			msg, err = verifyScan(*signSecret, msg)
+		}
+		if err != nil {
+			log.Printf("bridge: %v", err)
+			return "", false
+		}
+		return msg, true
+	}
+	scanned := func(scan, msg string) error {
+		speaker.announce(scan, msg)
+		publisher.publish(scan, msg)
+		scans.record(scan, msg)
+		post(msg)
+		return nil
+	}
+
+	errc := make(chan error, 3)
+	if *listen != "" {
+		mux := http.NewServeMux()
+		if *secret != "" {
+			mux.Handle("GET /trigger", triggerHandler(*secret, post))
+		}
+		mux.Handle("GET /t/{token}", tokenHandler(tokens, postPlain))
+		if m != nil {
+			mux.Handle("GET /s/{key}", shortLinkHandler(m))
+		}
+		log.Printf("bridge: serving trigger and token URLs on %s", *listen)
+		go func() { errc <- http.ListenAndServe(*listen, mux) }()
+	}
+	if *device != "" {
+		port, err := openScanner(*device, *baud)
+		if err != nil {
+			return err
+		}
+		defer port.Close()
+
+		log.Printf("bridge: posting scans from %s", *device)
+		go func() {
+			errc <- readMessages(port, *stripAIMIDs, opened, scanned)
+		}()
+	}
+	if *readStdin {
+		go func() {
+			errc <- readMessages(os.Stdin, *stripAIMIDs, opened, scanned)
+		}()
+	}
+	return <-errc
+}
+
+// triggerHandler posts the text of correctly signed trigger URLs, see
+// triggerPayload.
+func triggerHandler(secret string, post func(msg string) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text, sig := r.URL.Query().Get("text"), r.URL.Query().Get("sig")
+		if text == "" || !verifyText(secret, text, sig) {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		if err := post(text); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintf(w, "Sent: %s\n", text)
+	})
+}
+
+// postJSON posts v as JSON to url and fails on non-2xx responses.
+func postJSON(url string, v any) error {
+	return sendJSON(http.MethodPost, url, nil, v)
+}
+
+// sendJSON sends v as JSON to url with the given extra headers and fails on
+// non-2xx responses.
+func sendJSON(method, url string, header http.Header, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+func webhookFormatNames() string {
+	names := make([]string, 0, len(webhookFormats))
+	for name := range webhookFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
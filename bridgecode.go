@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bridge codes are payloads addressed to the bridge rather than typed into a
+// chat window. They are URLs with the cb scheme whose host names the
+// service, e.g.
+//
+//	cb://discord/mods/got-it
+//	cb://discord/mods?text=Got+it%2C+thanks%21
+const bridgeScheme = "cb"
+
+// bridgeCode builds a bridge code for service.
+func bridgeCode(service string, path []string, q url.Values) string {
+	escaped := make([]string, len(path))
+	for i, p := range path {
+		escaped[i] = url.PathEscape(p)
+	}
+	u := bridgeScheme + "://" + service + "/" + strings.Join(escaped, "/")
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return u
+}
+
+// parseBridgeCode parses scan as a bridge code, returning the URL (whose
+// Host is the service) and its unescaped path segments.
+func parseBridgeCode(scan string) (u *url.URL, path []string, ok bool) {
+	if !strings.HasPrefix(scan, bridgeScheme+"://") {
+		return nil, nil, false
+	}
+	u, err := url.Parse(scan)
+	if err != nil {
+		return nil, nil, false
+	}
+	if p := strings.Trim(u.Path, "/"); p != "" {
+		path = strings.Split(p, "/")
+	}
+	return u, path, true
+}
+
+// bridgeService handles bridge codes for one service.
+type bridgeService func(u *url.URL, path []string) error
+
+// bridgeText returns the message a bridge code carries: its text parameter,
+// or the message whose ID is the path segment at i.
+func bridgeText(set *messageSet, u *url.URL, path []string, i int) (string, error) {
+	if text := u.Query().Get("text"); text != "" {
+		return text, nil
+	}
+	if i >= len(path) {
+		return "", fmt.Errorf("%s: no message text or ID", u)
+	}
+	msg, ok := set.find(path[i])
+	if !ok {
+		return "", fmt.Errorf("%s: unknown message ID %q", u, path[i])
+	}
+	return msg.Code, nil
+}
+
+// discordService posts bridge codes of the form cb://discord/<channel>/<id>
+// to the webhook configured for <channel>.
+func discordService(set *messageSet, webhooks map[string]string) bridgeService {
+	return func(u *url.URL, path []string) error {
+		if len(path) == 0 {
+			return fmt.Errorf("%s: no channel", u)
+		}
+		webhook, ok := webhooks[path[0]]
+		if !ok {
+			return fmt.Errorf("%s: no -discord webhook for channel %q", u, path[0])
+		}
+		text, err := bridgeText(set, u, path, 1)
+		if err != nil {
+			return err
+		}
+		return postJSON(webhook, webhookFormats["discord"](text))
+	}
+}
+
+// matrixService sends bridge codes of the form cb://matrix/<room>/<id> to
+// the room ID configured for <room>, as the bot user owning token.
+func matrixService(set *messageSet, homeserver, token string, rooms map[string]string) bridgeService {
+	return func(u *url.URL, path []string) error {
+		if len(path) == 0 {
+			return fmt.Errorf("%s: no room", u)
+		}
+		roomID, ok := rooms[path[0]]
+		if !ok {
+			return fmt.Errorf("%s: no -matrix-room for %q", u, path[0])
+		}
+		if homeserver == "" || token == "" {
+			return errors.New("matrix codes need -matrix-homeserver and -matrix-token")
+		}
+		text, err := bridgeText(set, u, path, 1)
+		if err != nil {
+			return err
+		}
+		txn := strconv.FormatInt(time.Now().UnixNano(), 36)
+		endpoint := strings.TrimSuffix(homeserver, "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + txn
+		return sendJSON(http.MethodPut, endpoint, http.Header{"Authorization": {"Bearer " + token}},
+			map[string]string{"msgtype": "m.text", "body": text})
+	}
+}
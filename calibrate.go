@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/arran4/chat-barcodes/sheet"
+	"github.com/fogleman/gg"
+)
+
+// runCalibrate implements `chat-barcodes calibrate`, which renders a test
+// page of codes at a range of module widths, QR error correction levels
+// and symbologies. Scanning it shows the smallest codes a scanner reads
+// reliably, before choosing -columns, -cell-style or sticker stock.
+func runCalibrate(args []string) error {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	out := fs.String("o", "scanner-calibration.png", "output PNG")
+	dpi := fs.Float64("dpi", 300, "printer resolution the page is rendered at")
+	var fonts fontFlags
+	fonts.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts := sheet.DefaultOptions()
+	if *dpi != opts.DPI {
+		opts = sheet.AtDPI(opts, *dpi)
+	}
+	opts.Fonts = fonts.Fonts()
+	img, err := sheet.RenderCalibration(opts)
+	if err != nil {
+		return err
+	}
+	if err := gg.SavePNG(*out, img); err != nil {
+		return fmt.Errorf("failed to save PNG: %w", err)
+	}
+	fmt.Println("Saved:", *out)
+	return nil
+}
+
+// pageSizes are the paper sizes of -page, in inches.
+var pageSizes = map[string][2]float64{
+	"a4":     {8.27, 11.69},
+	"letter": {8.5, 11},
+}
+
+// runPrintTest implements `chat-barcodes printtest`, which renders a page
+// of rulers, a 50 mm square and grey and stripe patches for checking that
+// the printer prints at actual size rather than shrinking pages to fit,
+// which shrinks a sheet's QR codes below what scanners read.
+func runPrintTest(args []string) error {
+	fs := flag.NewFlagSet("printtest", flag.ExitOnError)
+	out := fs.String("o", "printer-test.png", "output PNG")
+	dpi := fs.Float64("dpi", 300, "printer resolution the page is rendered at")
+	page := fs.String("page", "a4", "paper size, a4 or letter")
+	var fonts fontFlags
+	fonts.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	size, ok := pageSizes[*page]
+	if !ok {
+		return fmt.Errorf("unknown -page %q, want a4 or letter", *page)
+	}
+
+	opts := sheet.DefaultOptions()
+	if *dpi != opts.DPI {
+		opts = sheet.AtDPI(opts, *dpi)
+	}
+	opts.WidthInches, opts.HeightInches = size[0], size[1]
+	opts.Fonts = fonts.Fonts()
+	img, err := sheet.RenderPrintTest(opts)
+	if err != nil {
+		return err
+	}
+	if err := gg.SavePNG(*out, img); err != nil {
+		return fmt.Errorf("failed to save PNG: %w", err)
+	}
+	fmt.Println("Saved:", *out)
+	fmt.Printf("Print it at actual size and measure the square: it should be %d mm each way.\n", sheet.PrintTestSquare)
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// categoryPalette colours categories that don't set their own, in order of
+// first appearance. The colours stay readable as label text on white.
+var categoryPalette = []string{
+	"#1f77b4", "#d62728", "#2ca02c", "#e6550d", "#9467bd",
+	"#8c564b", "#d6336c", "#1098ad", "#5c940d", "#495057",
+}
+
+// categoryAccents returns the accent colour of each category of set: those
+// set in the file and, if auto, palette colours for the rest.
+func categoryAccents(set *messageSet, auto bool) (map[string]color.Color, error) {
+	accents := map[string]color.Color{}
+	for name, hex := range set.Categories {
+		c, err := sheet.ParseColor(hex)
+		if err != nil {
+			return nil, fmt.Errorf("category %s: %w", name, err)
+		}
+		accents[name] = c
+	}
+	if !auto {
+		return accents, nil
+	}
+	next := 0
+	for _, m := range set.Messages {
+		if _, ok := accents[m.Category]; ok || m.Category == "" {
+			continue
+		}
+		c, _ := sheet.ParseColor(categoryPalette[next%len(categoryPalette)])
+		accents[m.Category] = c
+		next++
+	}
+	return accents, nil
+}
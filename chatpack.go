@@ -0,0 +1,352 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// A .chatpack bundle is a zip of a message set and everything needed to
+// print it the same way elsewhere:
+//
+//	chatpack.json   format and sheet flag settings, see packManifest
+//	messages.json   the message set, icon paths relative to the bundle
+//	logo.*, background.*, locale.json, icons/*
+//
+// Fonts aren't bundled. An imported bundle is the same files in a
+// directory.
+const (
+	packFormat   = 1
+	packManifest = "chatpack.json"
+	packMessages = "messages.json"
+)
+
+// packSetting is a sheet flag set to a value.
+type packSetting struct {
+	Flag  string `json:"flag"`
+	Value string `json:"value"`
+}
+
+// packInfo is chatpack.json.
+type packInfo struct {
+	Format   int           `json:"format"`
+	Name     string        `json:"name,omitempty"`
+	Version  string        `json:"version,omitempty"`
+	Settings []packSetting `json:"settings"`
+}
+
+// packFiles are the flags naming files that are bundled; a -roster is
+// bundled as the message set.
+var packFiles = map[string]bool{"messages": true, "roster": true, "logo": true, "background": true, "locale": true}
+
+// packSettings are the other sheet flags a bundle may set: how its messages
+// are worded and printed. Flags choosing where codes lead or what secrets
+// they're signed with, such as -payload, -shortener, -footer-link or
+// -sign-secret, are left to whoever renders it.
+var packSettings = map[string]bool{
+	"fragments": true, "emoji": true, "target": true, "transform": true, "normalize": true,
+	"ascii-punctuation": true, "aim-safe": true, "indirect": true,
+	"theme": true, "invert-codes": true, "watermark": true, "logo-align": true, "dpi": true,
+	"columns": true, "stock": true, "cell-style": true, "description-columns": true,
+	"description-align": true, "description-lines": true, "hyphenate": true,
+	"integer-modules": true, "tent": true, "back": true, "duplex": true, "pixel-snap": true,
+	"page-rows": true, "page-numbers": true, "keep-categories": true, "scan-distance": true,
+	"fingerprint": true, "keyboard-strip": true, "large-print": true, "category-colors": true,
+	"lang": true, "variant": true, "shuffle": true, "bingo": true, "numbers": true,
+	"prefix": true, "suffix": true, "disclaimer": true,
+}
+
+// recordedValue records the values a flag is set to.
+type recordedValue struct {
+	flag.Value
+	name     string
+	settings *[]packSetting
+}
+
+// String is the flag's value, "" for the zero recordedValue flag.PrintDefaults
+// makes to compare defaults with.
+func (v *recordedValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+func (v *recordedValue) Set(s string) error {
+	if err := v.Value.Set(s); err != nil {
+		return err
+	}
+	*v.settings = append(*v.settings, packSetting{v.name, s})
+	return nil
+}
+
+func (v *recordedValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// recordSettings records the sheet flags registered on fs since before in
+// c.settings as they are set. Font flags and -pack aren't bundle settings.
+func (c *sheetConfig) recordSettings(fs *flag.FlagSet, before map[string]bool) {
+	fonts := flag.NewFlagSet("", flag.ContinueOnError)
+	new(fontFlags).register(fonts)
+	fs.VisitAll(func(f *flag.Flag) {
+		if !before[f.Name] && fonts.Lookup(f.Name) == nil && f.Name != "pack" {
+			// Usage leaves out defaults matching the zero value of the
+			// flag's type, which for a recordedValue is always "".
+			if f.DefValue == zeroString(f.Value) {
+				f.DefValue = ""
+			}
+			f.Value = &recordedValue{f.Value, f.Name, &c.settings}
+		}
+	})
+}
+
+// zeroString returns the String of the zero value of v's type, as
+// flag.PrintDefaults works it out.
+func zeroString(v flag.Value) string {
+	t := reflect.TypeOf(v)
+	if t.Kind() != reflect.Pointer {
+		return reflect.Zero(t).Interface().(flag.Value).String()
+	}
+	return reflect.New(t.Elem()).Interface().(flag.Value).String()
+}
+
+// usePack applies -pack: the flags the bundle sets, unless set on the
+// command line or in the environment, and its message set. Bundles are
+// unpacked into a temporary directory until cleanup is called.
+func (c *sheetConfig) usePack(fs *flag.FlagSet) (cleanup func(), err error) {
+	cleanup = func() {}
+	if c.pack == "" {
+		return cleanup, nil
+	}
+	dir := c.pack
+	if fi, err := os.Stat(c.pack); err != nil {
+		return cleanup, err
+	} else if !fi.IsDir() {
+		if dir, err = os.MkdirTemp("", "chatpack"); err != nil {
+			return cleanup, err
+		}
+		cleanup = func() { os.RemoveAll(dir) }
+		if err := unpack(c.pack, dir); err != nil {
+			cleanup()
+			return func() {}, err
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, packManifest))
+	if err != nil {
+		cleanup()
+		return func() {}, fmt.Errorf("%s is not a chat pack: %w", c.pack, err)
+	}
+	var info packInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		cleanup()
+		return func() {}, fmt.Errorf("parsing %s: %w", packManifest, err)
+	}
+	if info.Format > packFormat {
+		cleanup()
+		return func() {}, fmt.Errorf("%s is chat pack format %d, this version reads %d", c.pack, info.Format, packFormat)
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	recorded := func(name string) bool {
+		_, ok := fs.Lookup(name).Value.(*recordedValue)
+		return ok
+	}
+	for _, s := range append(info.Settings, packSetting{"messages", packMessages}) {
+		if fs.Lookup(s.Flag) == nil || !recorded(s.Flag) {
+			cleanup()
+			return func() {}, fmt.Errorf("%s: %q is not a sheet setting", c.pack, s.Flag)
+		}
+		if !packFiles[s.Flag] && !packSettings[s.Flag] {
+			cleanup()
+			return func() {}, fmt.Errorf("%s: -%s is not a setting packs may apply, set it on the command line", c.pack, s.Flag)
+		}
+		if set[s.Flag] {
+			continue
+		}
+		if s.Flag == "messages" || s.Flag == "roster" {
+			c.packed = true
+		}
+		v := s.Value
+		if packFiles[s.Flag] {
+			if !filepath.IsLocal(v) {
+				cleanup()
+				return func() {}, fmt.Errorf("%s: %s %q is outside the pack", c.pack, s.Flag, v)
+			}
+			v = filepath.Join(dir, v)
+		}
+		if err := fs.Set(s.Flag, v); err != nil {
+			cleanup()
+			return func() {}, fmt.Errorf("%s: -%s: %w", c.pack, s.Flag, err)
+		}
+	}
+	return cleanup, nil
+}
+
+// unpack extracts the bundle at path into dir.
+func unpack(path, dir string) error {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	defer z.Close()
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !filepath.IsLocal(f.Name) {
+			return fmt.Errorf("%s: %q is outside the pack", path, f.Name)
+		}
+		dst := filepath.Join(dir, f.Name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", path, f.Name, err)
+		}
+		if err := os.WriteFile(dst, b, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPackExport bundles a message set, its images and the sheet flags
+// given into a .chatpack file.
+func runPackExport(args []string) error {
+	fs := flag.NewFlagSet("pack export", flag.ExitOnError)
+	out := fs.String("o", "", "bundle to write, e.g. support.chatpack")
+	var config sheetConfig
+	config.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-o is required")
+	}
+	cleanup, err := config.usePack(fs)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	set, err := config.messageSet()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	z := zip.NewWriter(f)
+	names := map[string]bool{}
+	add := func(src, name string) (string, error) {
+		ext := path.Ext(name)
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext)
+		}
+		names[name] = true
+		b, err := os.ReadFile(src)
+		if err != nil {
+			return "", err
+		}
+		w, err := z.Create(name)
+		if err != nil {
+			return "", err
+		}
+		_, err = w.Write(b)
+		return name, err
+	}
+
+	info := packInfo{Format: packFormat, Name: set.Name, Version: set.Version}
+	for _, s := range config.settings {
+		switch {
+		case packSettings[s.Flag]:
+			info.Settings = append(info.Settings, s)
+		case !packFiles[s.Flag]:
+			return fmt.Errorf("-%s can't be bundled, packs leave it to whoever renders them", s.Flag)
+		}
+	}
+	for _, file := range []packSetting{{"logo", config.logo}, {"background", config.background}, {"locale", config.localePath}} {
+		if file.Value == "" {
+			continue
+		}
+		name, err := add(file.Value, file.Flag+strings.ToLower(filepath.Ext(file.Value)))
+		if err != nil {
+			return err
+		}
+		info.Settings = append(info.Settings, packSetting{file.Flag, name})
+	}
+	for i, m := range set.Messages {
+		if m.Icon == "" || isBundledIcon(m.Icon) {
+			continue
+		}
+		if config.packed && !filepath.IsLocal(m.Icon) {
+			return fmt.Errorf("icon %q is outside the pack", m.Icon)
+		}
+		src := m.Icon
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(set.dir, src)
+		}
+		if set.Messages[i].Icon, err = add(src, "icons/"+filepath.Base(src)); err != nil {
+			return err
+		}
+	}
+	for name, v := range map[string]any{packManifest: info, packMessages: set} {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		w, err := z.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+	fmt.Println("Saved:", *out)
+	return f.Close()
+}
+
+// runPackImport unpacks a bundle into a directory, where it can be edited
+// and rendered with -pack.
+func runPackImport(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: pack import bundle.chatpack [dir]")
+	}
+	dir := strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	if len(args) == 2 {
+		dir = args[1]
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists", dir)
+	}
+	if err := unpack(args[0], dir); err != nil {
+		return err
+	}
+	fmt.Println("Saved:", dir)
+	fmt.Printf("Render it with: chat-barcodes -pack %s\n", dir)
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// defaultClipboard returns the command copying its standard input to the
+// system clipboard here: wl-copy under Wayland, xclip under X11, pbcopy on
+// macOS and PowerShell's Set-Clipboard on Windows.
+func defaultClipboard() string {
+	switch {
+	case runtime.GOOS == "windows":
+		return "powershell -NoProfile -Command $input | Set-Clipboard"
+	case runtime.GOOS == "darwin":
+		return "pbcopy"
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		return "wl-copy"
+	default:
+		return "xclip -selection clipboard"
+	}
+}
+
+// copyToClipboard runs the clipboard command with text as its input.
+func copyToClipboard(command, text string) error {
+	if strings.TrimSpace(command) == "" {
+		return fmt.Errorf("no clipboard command")
+	}
+	return pipeTo(command, text)
+}
+
+// pipeTo runs command, split into words without a shell, with text as its
+// input.
+func pipeTo(command, text string) error {
+	args := strings.Fields(command)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// issueRef is the GitHub issue/PR or Jira ticket comment codes are posted
+// to.
+type issueRef struct {
+	Repo   string // owner/name for GitHub issues
+	Number string
+	Key    string // PROJ-123 for Jira tickets
+}
+
+func (r issueRef) String() string {
+	if r.Key != "" {
+		return r.Key
+	}
+	return r.Repo + "#" + r.Number
+}
+
+var (
+	githubIssueURL = regexp.MustCompile(`^https://github\.com/([\w.-]+/[\w.-]+)/(?:issues|pull)/(\d+)`)
+	githubIssueRef = regexp.MustCompile(`^([\w.-]+/[\w.-]+)?#(\d+)$`)
+	jiraIssueURL   = regexp.MustCompile(`^https?://[^/]+/browse/([A-Z][A-Z0-9_]+-\d+)`)
+	jiraIssueKey   = regexp.MustCompile(`^[A-Z][A-Z0-9_]+-\d+$`)
+)
+
+// parseIssueRef recognises scans that pick the issue to comment on:
+// GitHub issue or PR URLs, owner/repo#123 (or #123 in defaultRepo), Jira
+// browse URLs and Jira keys.
+func parseIssueRef(scan, defaultRepo string) (issueRef, bool) {
+	if m := githubIssueURL.FindStringSubmatch(scan); m != nil {
+		return issueRef{Repo: m[1], Number: m[2]}, true
+	}
+	if m := githubIssueRef.FindStringSubmatch(scan); m != nil {
+		repo := m[1]
+		if repo == "" {
+			repo = defaultRepo
+		}
+		if repo == "" {
+			return issueRef{}, false
+		}
+		return issueRef{Repo: repo, Number: m[2]}, true
+	}
+	if m := jiraIssueURL.FindStringSubmatch(scan); m != nil {
+		return issueRef{Key: m[1]}, true
+	}
+	if jiraIssueKey.MatchString(scan) {
+		return issueRef{Key: scan}, true
+	}
+	return issueRef{}, false
+}
+
+// commentPayload encodes a bridge code posting the message as a comment on
+// whichever issue was scanned last, see commenter.
+func commentPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	return bridgeCode("comment", []string{msg.Key()}, nil), nil
+}
+
+// commenter posts comment templates to the current issue. Triagers scan (or
+// type) an issue link to select it and then scan cb://comment/<id> codes;
+// {issue} in a template expands to the selected issue.
+type commenter struct {
+	set *messageSet
+
+	GitHubAPI   string
+	GitHubToken string
+	GitHubRepo  string // for #123 shorthand
+
+	JiraURL   string
+	JiraUser  string
+	JiraToken string
+
+	mu      sync.Mutex
+	current *issueRef
+}
+
+// Select makes scan the current issue if it is an issue reference.
+func (c *commenter) Select(scan string) bool {
+	ref, ok := parseIssueRef(scan, c.GitHubRepo)
+	if !ok {
+		return false
+	}
+	c.mu.Lock()
+	c.current = &ref
+	c.mu.Unlock()
+	return true
+}
+
+func (c *commenter) service(u *url.URL, path []string) error {
+	c.mu.Lock()
+	ref := c.current
+	c.mu.Unlock()
+	if ref == nil {
+		return errors.New("no issue selected; scan an issue link first")
+	}
+	text, err := bridgeText(c.set, u, path, 0)
+	if err != nil {
+		return err
+	}
+	text = expandFields(text, map[string]string{"issue": ref.String()})
+
+	if ref.Key != "" {
+		if c.JiraURL == "" {
+			return errors.New("Jira comments need -jira-url")
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(c.JiraUser + ":" + c.JiraToken))
+		endpoint := strings.TrimSuffix(c.JiraURL, "/") + "/rest/api/2/issue/" + ref.Key + "/comment"
+		return sendJSON(http.MethodPost, endpoint, http.Header{"Authorization": {"Basic " + auth}},
+			map[string]string{"body": text})
+	}
+	if c.GitHubToken == "" {
+		return errors.New("GitHub comments need -github-token")
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/issues/%s/comments", strings.TrimSuffix(c.GitHubAPI, "/"), ref.Repo, ref.Number)
+	return sendJSON(http.MethodPost, endpoint, http.Header{
+		"Authorization": {"Bearer " + c.GitHubToken},
+		"Accept":        {"application/vnd.github+json"},
+	}, map[string]string{"body": text})
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Content payloads encode things a phone camera app knows how to act on
+// rather than chat text, so the same sheet generator can produce the
+// posters that hang next to the chat codes.
+
+var wifiEscaper = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `:`, `\:`, `"`, `\"`)
+
+// wifiPayload encodes a WIFI: network configuration for the ssid, password,
+// auth (WPA, WEP or nopass; WPA when a password is set) and hidden options.
+func wifiPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	ssid, password, auth := opts["ssid"], opts["password"], opts["auth"]
+	if ssid == "" {
+		return "", errors.New("wifi payloads need -payload-opt ssid=…")
+	}
+	if auth == "" {
+		auth = "WPA"
+		if password == "" {
+			auth = "nopass"
+		}
+	}
+	switch auth {
+	case "WPA", "WEP":
+		if password == "" {
+			return "", fmt.Errorf("%s networks need -payload-opt password=…", auth)
+		}
+	case "nopass":
+		password = ""
+	default:
+		return "", fmt.Errorf("wifi auth must be WPA, WEP or nopass, not %q", auth)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "WIFI:T:%s;S:%s;", auth, wifiEscaper.Replace(ssid))
+	if password != "" {
+		fmt.Fprintf(&b, "P:%s;", wifiEscaper.Replace(password))
+	}
+	if opts["hidden"] == "true" {
+		b.WriteString("H:true;")
+	}
+	b.WriteString(";")
+	return b.String(), nil
+}
+
+// textValueEscaper escapes vCard and iCalendar TEXT values, which share the
+// same rules.
+var textValueEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\r\n", `\n`, "\n", `\n`)
+
+// vcardPayload encodes a vCard 3.0 contact from the name, phone, email,
+// title, org and url options; the name defaults to the label so a team
+// contact sheet only needs an entry per person.
+func vcardPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	name := opts["name"]
+	if name == "" {
+		name = msg.Label
+	}
+	if name == "" {
+		return "", errors.New("vcard payloads need -payload-opt name=… or a label")
+	}
+	given, family := name, ""
+	if i := strings.LastIndexByte(name, ' '); i > 0 {
+		given, family = name[:i], name[i+1:]
+	}
+
+	lines := []string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"N:" + textValueEscaper.Replace(family) + ";" + textValueEscaper.Replace(given) + ";;;",
+		"FN:" + textValueEscaper.Replace(name),
+	}
+	if phone := opts["phone"]; phone != "" {
+		n, err := phoneNumber(phone)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, "TEL;TYPE=CELL:"+n)
+	}
+	// EMAIL and URL aren't TEXT values, so a comma or semicolon in them is
+	// written as it is; only a line break would end the property early.
+	for _, p := range []struct {
+		prop, key string
+		text      bool
+	}{
+		{"EMAIL", "email", false}, {"TITLE", "title", true}, {"ORG", "org", true}, {"URL", "url", false},
+	} {
+		v := opts[p.key]
+		switch {
+		case v == "":
+			continue
+		case p.text:
+			v = textValueEscaper.Replace(v)
+		case strings.ContainsAny(v, "\r\n"):
+			return "", fmt.Errorf("vcard %s %q has a line break in it", p.key, v)
+		}
+		lines = append(lines, p.prop+":"+v)
+	}
+	lines = append(lines, "END:VCARD")
+	return strings.Join(lines, "\r\n"), nil
+}
+
+// icalTimeLayouts are the DTSTART/DTEND forms accepted on input; RFC 3339
+// times are converted to UTC.
+var icalTimeLayouts = []struct {
+	layout, out string
+}{
+	{"20060102T150405Z", "20060102T150405Z"},
+	{"20060102T150405", "20060102T150405"},
+	{"20060102", "20060102"},
+	{time.RFC3339, "20060102T150405Z"},
+	{"2006-01-02T15:04", "20060102T150405"},
+	{"2006-01-02", "20060102"},
+}
+
+// parseICalTime parses s and returns the layout to write it back out with.
+func parseICalTime(s string) (time.Time, string, error) {
+	for _, l := range icalTimeLayouts {
+		if t, err := time.Parse(l.layout, s); err == nil {
+			if l.layout == time.RFC3339 {
+				t = t.UTC()
+			}
+			return t, l.out, nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("%q is not a date or time like 20260101T093000Z or 2026-01-01T09:30:00+10:00", s)
+}
+
+var (
+	rruleFreq = regexp.MustCompile(`^FREQ=(SECONDLY|MINUTELY|HOURLY|DAILY|WEEKLY|MONTHLY|YEARLY)$`)
+	rrulePart = regexp.MustCompile(`^[A-Z]+=[A-Za-z0-9,+-]+$`)
+)
+
+// validateRRule does a light check that rule looks like an RFC 5545
+// recurrence rule, catching typos before they're printed.
+func validateRRule(rule string) error {
+	parts := strings.Split(rule, ";")
+	if !rruleFreq.MatchString(parts[0]) {
+		return fmt.Errorf("rrule %q must start with FREQ=DAILY, WEEKLY, …", rule)
+	}
+	for _, p := range parts[1:] {
+		if !rrulePart.MatchString(p) {
+			return fmt.Errorf("rrule %q: bad part %q", rule, p)
+		}
+	}
+	return nil
+}
+
+// eventPayload encodes an iCalendar VEVENT, e.g. for the standup or the
+// incident bridge. Options: summary (default label), start, end or duration
+// (Go syntax, e.g. 15m), location, description (default the message text)
+// and rrule.
+func eventPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	if opts["start"] == "" {
+		return "", errors.New("event payloads need -payload-opt start=…")
+	}
+	start, layout, err := parseICalTime(opts["start"])
+	if err != nil {
+		return "", err
+	}
+	dtstart := start.Format(layout)
+
+	var dtend string
+	switch {
+	case opts["end"] != "" && opts["duration"] != "":
+		return "", errors.New("event payloads take end or duration, not both")
+	case opts["end"] != "":
+		end, endLayout, err := parseICalTime(opts["end"])
+		if err != nil {
+			return "", err
+		}
+		dtend = end.Format(endLayout)
+		if !end.After(start) {
+			return "", fmt.Errorf("event ends (%s) before it starts (%s)", opts["end"], opts["start"])
+		}
+	case opts["duration"] != "":
+		d, err := time.ParseDuration(opts["duration"])
+		if err != nil || d <= 0 {
+			return "", fmt.Errorf("bad event duration %q", opts["duration"])
+		}
+		if layout == "20060102" {
+			return "", errors.New("all-day events take an end date, not a duration")
+		}
+		dtend = start.Add(d).Format(layout)
+	}
+
+	summary := opts["summary"]
+	if summary == "" {
+		summary = msg.Label
+	}
+	description := opts["description"]
+	if description == "" {
+		description = msg.Code
+	}
+
+	lines := []string{
+		"BEGIN:VEVENT",
+		"SUMMARY:" + textValueEscaper.Replace(summary),
+		"DTSTART:" + dtstart,
+	}
+	if dtend != "" {
+		lines = append(lines, "DTEND:"+dtend)
+	}
+	if rule := opts["rrule"]; rule != "" {
+		if err := validateRRule(rule); err != nil {
+			return "", err
+		}
+		lines = append(lines, "RRULE:"+rule)
+	}
+	if loc := opts["location"]; loc != "" {
+		lines = append(lines, "LOCATION:"+textValueEscaper.Replace(loc))
+	}
+	if description != "" {
+		lines = append(lines, "DESCRIPTION:"+textValueEscaper.Replace(expandFields(description, msg.templateFields())))
+	}
+	lines = append(lines, "END:VEVENT")
+	return strings.Join(lines, "\r\n"), nil
+}
+
+// geoPayload encodes a location from the lat and lon options (decimal
+// degrees) as a geo: URI, or with maps=google or maps=apple as that maps
+// app's URL, which iPhones handle better than geo:. The q option names the
+// place and defaults to the label.
+func geoPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	lat, err := strconv.ParseFloat(opts["lat"], 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return "", fmt.Errorf("geo payloads need -payload-opt lat=… between -90 and 90, not %q", opts["lat"])
+	}
+	lon, err := strconv.ParseFloat(opts["lon"], 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return "", fmt.Errorf("geo payloads need -payload-opt lon=… between -180 and 180, not %q", opts["lon"])
+	}
+	q := opts["q"]
+	if q == "" {
+		q = msg.Label
+	}
+	ll := strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64)
+
+	switch opts["maps"] {
+	case "", "geo":
+		p := "geo:" + ll
+		if q != "" {
+			p += "?q=" + ll + "(" + uriEscape(q) + ")"
+		}
+		return p, nil
+	case "google":
+		query := ll
+		if q != "" {
+			query += "(" + q + ")"
+		}
+		return "https://www.google.com/maps/search/?api=1&query=" + uriEscape(query), nil
+	case "apple":
+		p := "https://maps.apple.com/?ll=" + ll
+		if q != "" {
+			p += "&q=" + uriEscape(q)
+		}
+		return p, nil
+	}
+	return "", fmt.Errorf("geo maps must be geo, google or apple, not %q", opts["maps"])
+}
+
+// meetingHosts are the video-call services meeting payloads accept, to
+// catch links copied from the wrong place (calendar wrappers, safelinks).
+var meetingHosts = []string{"zoom.us", "meet.google.com", "meet.jit.si", "teams.microsoft.com", "whereby.com"}
+
+// meetingPayload encodes a video-call join link from the url option. Set
+// host=any to allow self-hosted services.
+func meetingPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	u, err := url.Parse(opts["url"])
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("meeting payloads need -payload-opt url=https://…, not %q", opts["url"])
+	}
+	if opts["host"] != "any" {
+		ok := false
+		for _, h := range meetingHosts {
+			if u.Host == h || strings.HasSuffix(u.Host, "."+h) {
+				ok = true
+			}
+		}
+		if !ok {
+			return "", fmt.Errorf("%s is not a known meeting service (%s); set -payload-opt host=any to use it anyway", u.Host, strings.Join(meetingHosts, ", "))
+		}
+	}
+	return u.String(), nil
+}
+
+// meetingDialIn encodes the dialin option, with the pin option entered
+// after a pause, as the tel: URI of the small dial-in code.
+func meetingDialIn(msg ChatMsg, opts map[string]string) (string, error) {
+	if opts["dialin"] == "" {
+		return "", nil
+	}
+	number, err := phoneNumber(opts["dialin"])
+	if err != nil {
+		return "", err
+	}
+	if pin := opts["pin"]; pin != "" {
+		number += ",," + strings.TrimSuffix(pin, "#") + "#"
+	}
+	return "tel:" + uriEscape(number), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// densityReport is the -density report: how big and dense each QR code of
+// a sheet came out, for finding the messages worth shortening.
+type densityReport struct {
+	Title            string        `json:"title"`
+	Codes            int           `json:"codes"`
+	MaxVersion       int           `json:"max_version"`
+	SmallestModuleMM float64       `json:"smallest_module_mm"`
+	TotalBytes       int           `json:"total_bytes"`
+	AverageBytes     float64       `json:"average_bytes"`
+	Versions         map[int]int   `json:"versions"` // number of codes of each version
+	Cells            []densityCell `json:"cells"`
+}
+
+type densityCell struct {
+	Page     int     `json:"page,omitempty"`
+	Cell     string  `json:"cell"`
+	ID       string  `json:"id"`
+	Label    string  `json:"label"`
+	Version  int     `json:"version"`
+	Modules  int     `json:"modules"` // across
+	ModuleMM float64 `json:"module_mm"`
+	Bytes    int     `json:"bytes"` // of the payload
+}
+
+func density(l *layout) densityReport {
+	s := sheet.Summarize(l.opts, l.cells)
+	r := densityReport{Title: l.opts.Title, Codes: len(s.Codes), Versions: s.Versions}
+	for _, c := range s.Codes {
+		m := l.manifest.Cells[c.Cell]
+		r.Cells = append(r.Cells, densityCell{
+			Page: m.Page, Cell: m.Cell, ID: m.ID, Label: c.Label,
+			Version: c.Version, Modules: c.Modules, ModuleMM: math.Round(c.ModuleMM*100) / 100, Bytes: c.Payload,
+		})
+		r.MaxVersion = max(r.MaxVersion, c.Version)
+		if r.SmallestModuleMM == 0 || c.ModuleMM < r.SmallestModuleMM {
+			r.SmallestModuleMM = math.Round(c.ModuleMM*100) / 100
+		}
+		r.TotalBytes += c.Payload
+	}
+	if r.Codes > 0 {
+		r.AverageBytes = math.Round(float64(r.TotalBytes)/float64(r.Codes)*10) / 10
+	}
+	return r
+}
+
+// writeDensity writes the density report of l's codes to path, as JSON for
+// .json files and otherwise as a table, to standard output for "-".
+func writeDensity(path string, l *layout) error {
+	r := density(l)
+	if path == "-" {
+		return r.writeTable(os.Stdout)
+	}
+	var b strings.Builder
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		out, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		b.Write(append(out, '\n'))
+	} else if err := r.writeTable(&b); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+func (r densityReport) writeTable(w io.Writer) error {
+	fmt.Fprintf(w, "%s: %d QR codes, up to version %d, modules from %.2f mm, %d bytes (%.1f per code)\n\n",
+		r.Title, r.Codes, r.MaxVersion, r.SmallestModuleMM, r.TotalBytes, r.AverageBytes)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Cell\tVersion\tModules\tModule mm\tBytes\t\tLabel")
+	for _, c := range r.Cells {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%d\t\t%s\n", position(manifestCell{Page: c.Page, Cell: c.Cell}),
+			c.Version, c.Modules, c.ModuleMM, c.Bytes, oneLine(c.Label))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// Colours of the marks on a diff sheet.
+var (
+	addedColor   = color.RGBA{R: 0x31, G: 0xa3, B: 0x54, A: 255}
+	changedColor = color.RGBA{R: 0xe6, G: 0x55, B: 0x0d, A: 255}
+	movedColor   = color.RGBA{R: 0x31, G: 0x82, B: 0xbd, A: 255}
+)
+
+// cellChange is how a cell differs from the one with its ID in an older
+// manifest.
+type cellChange struct {
+	kind string // "added", "changed", "moved" or "removed"
+	cell manifestCell
+	was  manifestCell // in the old manifest, unless added
+}
+
+// position returns the cell's name as in lint output, with its page on
+// sheets of several.
+func position(c manifestCell) string {
+	if c.Page == 0 {
+		return c.Cell
+	}
+	return fmt.Sprintf("%s on page %d", c.Cell, c.Page)
+}
+
+func (c cellChange) String() string {
+	switch c.kind {
+	case "added":
+		return fmt.Sprintf("+ %s %s: added", position(c.cell), c.cell.ID)
+	case "removed":
+		return fmt.Sprintf("- %s %s: removed", position(c.was), c.was.ID)
+	case "moved":
+		return fmt.Sprintf("> %s %s: moved from %s", position(c.cell), c.cell.ID, position(c.was))
+	}
+	s := fmt.Sprintf("~ %s %s: changed", position(c.cell), c.cell.ID)
+	if c.cell.Cell != c.was.Cell || c.cell.Page != c.was.Page {
+		s += ", was at " + position(c.was)
+	}
+	return s
+}
+
+// diffManifests compares the cells of two manifests by ID: a cell is
+// changed if its label or payload differ, and moved if only its position
+// does. Removed cells come last.
+func diffManifests(old, cur *manifest) []cellChange {
+	was := make(map[string]manifestCell, len(old.Cells))
+	for _, c := range old.Cells {
+		was[c.ID] = c
+	}
+	var changes []cellChange
+	seen := make(map[string]bool, len(cur.Cells))
+	for _, c := range cur.Cells {
+		seen[c.ID] = true
+		prev, ok := was[c.ID]
+		switch {
+		case !ok:
+			changes = append(changes, cellChange{kind: "added", cell: c})
+		case prev.SHA256 != c.SHA256 || prev.Label != c.Label:
+			changes = append(changes, cellChange{kind: "changed", cell: c, was: prev})
+		case prev.Cell != c.Cell || prev.Page != c.Page:
+			changes = append(changes, cellChange{kind: "moved", cell: c, was: prev})
+		}
+	}
+	for _, c := range old.Cells {
+		if !seen[c.ID] {
+			changes = append(changes, cellChange{kind: "removed", was: c})
+		}
+	}
+	return changes
+}
+
+// markChanges marks the cells of l that changed, see diffManifests.
+func markChanges(l *layout, changes []cellChange) {
+	kinds := make(map[string]string, len(changes))
+	for _, c := range changes {
+		if c.kind != "removed" {
+			kinds[c.cell.ID] = c.kind
+		}
+	}
+	for i, c := range l.manifest.Cells {
+		switch kinds[c.ID] {
+		case "added":
+			l.cells[i].Mark, l.cells[i].MarkColor = sheet.Expand(l.locale.New, "NEW"), addedColor
+		case "changed":
+			l.cells[i].Mark, l.cells[i].MarkColor = sheet.Expand(l.locale.Changed, "CHANGED"), changedColor
+		case "moved":
+			l.cells[i].Mark, l.cells[i].MarkColor = sheet.Expand(l.locale.Moved, "MOVED"), movedColor
+		}
+	}
+}
+
+// runDiff renders the sheet with the cells added, changed or moved since
+// an older manifest marked, and lists the differences.
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	out := fs.String("o", "chat-qr-a4-diff.png", "output PNG")
+	var config sheetConfig
+	config.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: diff [flags] old-manifest.json")
+	}
+	cleanup, err := config.usePack(fs)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	old, err := loadManifest(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	l, err := config.build()
+	if err != nil {
+		return err
+	}
+	changes := diffManifests(old, &l.manifest)
+	markChanges(l, changes)
+
+	saved, err := sheet.SavePNG(*out, l.opts, l.cells)
+	if err != nil {
+		return fmt.Errorf("failed to save PNG: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("No changes since", fs.Arg(0))
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	fmt.Println("Saved:", strings.Join(saved, ", "))
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+//go:embed web/editor.html
+var editorHTML string
+
+// editorPage is the message set editor served at / by serve: it edits a
+// message set in the browser, previews it with POST /generate and downloads
+// the sheet or the message file.
+var editorPage = template.Must(template.New("editor").Parse(editorHTML))
+
+// editorChoices fill the editor's option lists, defaulting to the server's
+// flags.
+type editorChoices struct {
+	Themes, Targets, Payloads, Icons []string
+	Theme, Target, Payload           string
+}
+
+// handleEditor adds the editor to mux, with the message set it starts from
+// at /messages.json.
+func handleEditor(config *sheetConfig, mux *http.ServeMux) {
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := editorPage.Execute(w, editorChoices{
+			Themes:   strings.Split(themeNames(), "|"),
+			Targets:  strings.Split(targetNames(), "|"),
+			Payloads: strings.Split(payloadModeNames(), "|"),
+			Icons:    strings.Split(iconNames(), ", "),
+			Theme:    config.themeName,
+			Target:   config.targetName,
+			Payload:  config.payloadName,
+		})
+		if err != nil {
+			log.Printf("serve: editor: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /messages.json", func(w http.ResponseWriter, r *http.Request) {
+		set, err := config.messageSet()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(set)
+	})
+}
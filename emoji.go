@@ -0,0 +1,40 @@
+package main
+
+// emojiReaction is the prefix Slack and Discord treat as "react to the last
+// message" rather than sending a new one.
+const emojiReaction = "+"
+
+// EmojiReactions are common reaction shortcodes, labelled with the emoji
+// itself. Names valid in both Slack and Discord were picked where they
+// differ.
+var EmojiReactions = []ChatMsg{
+	{Code: emojiReaction + ":thumbsup:", Label: "👍", Description: ":thumbsup: – agree / sounds good"},
+	{Code: emojiReaction + ":thumbsdown:", Label: "👎", Description: ":thumbsdown: – disagree"},
+	{Code: emojiReaction + ":eyes:", Label: "👀", Description: ":eyes: – looking at it"},
+	{Code: emojiReaction + ":white_check_mark:", Label: "✅", Description: ":white_check_mark: – done"},
+
+	{Code: emojiReaction + ":x:", Label: "❌", Description: ":x: – no / failed"},
+	{Code: emojiReaction + ":hourglass:", Label: "⌛", Description: ":hourglass: – in progress"},
+	{Code: emojiReaction + ":warning:", Label: "⚠️", Description: ":warning: – careful"},
+	{Code: emojiReaction + ":bug:", Label: "🐛", Description: ":bug: – that's a bug"},
+
+	{Code: emojiReaction + ":tada:", Label: "🎉", Description: ":tada: – celebrate"},
+	{Code: emojiReaction + ":rocket:", Label: "🚀", Description: ":rocket: – shipped"},
+	{Code: emojiReaction + ":fire:", Label: "🔥", Description: ":fire: – great / on fire"},
+	{Code: emojiReaction + ":100:", Label: "💯", Description: ":100: – totally"},
+
+	{Code: emojiReaction + ":heart:", Label: "❤️", Description: ":heart: – love it"},
+	{Code: emojiReaction + ":pray:", Label: "🙏", Description: ":pray: – thank you"},
+	{Code: emojiReaction + ":clap:", Label: "👏", Description: ":clap: – well done"},
+	{Code: emojiReaction + ":raised_hands:", Label: "🙌", Description: ":raised_hands: – hooray"},
+
+	{Code: emojiReaction + ":joy:", Label: "😂", Description: ":joy: – hilarious"},
+	{Code: emojiReaction + ":thinking:", Label: "🤔", Description: ":thinking: – hmm"},
+	{Code: emojiReaction + ":sob:", Label: "😭", Description: ":sob: – sad"},
+	{Code: emojiReaction + ":muscle:", Label: "💪", Description: ":muscle: – you've got this"},
+
+	{Code: emojiReaction + ":wave:", Label: "👋", Description: ":wave: – hello / goodbye"},
+	{Code: emojiReaction + ":ok_hand:", Label: "👌", Description: ":ok_hand: – OK"},
+	{Code: emojiReaction + ":sparkles:", Label: "✨", Description: ":sparkles: – nice touch"},
+	{Code: emojiReaction + ":coffee:", Label: "☕", Description: ":coffee: – break time"},
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envPrefix starts the environment variables flags are read from.
+const envPrefix = "CHAT_BARCODES_"
+
+// parseFlags parses args after setting every flag of fs that has an
+// environment variable, so that container deployments can be configured
+// without arguments: -payload-opt is CHAT_BARCODES_PAYLOAD_OPT. Repeatable
+// flags take further values from numbered variables, CHAT_BARCODES_PAYLOAD_OPT_2
+// and so on. Arguments override (or, for repeatable flags, add to) the
+// environment.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	env := os.Environ()
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		for _, kv := range envValues(env, envName(f.Name)) {
+			if e := fs.Set(f.Name, kv[1]); e != nil {
+				err = fmt.Errorf("%s: invalid value %q: %w", kv[0], kv[1], e)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return fs.Parse(args)
+}
+
+// envName returns the environment variable of a flag.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// envValues returns the name and value of name and its numbered variables
+// (name_1, name_2, …) in env, in order.
+func envValues(env []string, name string) [][2]string {
+	var values [][2]string
+	numbered := map[int]string{}
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		if k == name {
+			values = append(values, [2]string{k, v})
+		} else if n, err := strconv.Atoi(strings.TrimPrefix(k, name+"_")); err == nil && strings.HasPrefix(k, name+"_") {
+			numbered[n] = v
+		}
+	}
+	ns := make([]int, 0, len(numbered))
+	for n := range numbered {
+		ns = append(ns, n)
+	}
+	sort.Ints(ns)
+	for _, n := range ns {
+		values = append(values, [2]string{fmt.Sprintf("%s_%d", name, n), numbered[n]})
+	}
+	return values
+}
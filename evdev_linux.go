@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	evioCGrab         = 0x40044590
+	keyCodeRightShift = 54
+	keyCodeKPEnter    = 96
+)
+
+// usRunes is the inverse of usKeys.
+var usRunes = map[usKey]rune{}
+
+func init() {
+	for r, k := range usKeys {
+		usRunes[k] = r
+	}
+}
+
+// openScanner opens the device a scanner is attached to. Keyboard wedge
+// scanners are read from their /dev/input event device, which is grabbed
+// so the scans don't also land in whatever window has focus; anything else
+// is treated as a serial port.
+func openScanner(device string, baud int) (io.ReadCloser, error) {
+	return openInput(device, baud, true)
+}
+
+// watchScanner opens the device a scanner is attached to like openScanner,
+// but without grabbing event devices, so scans still reach the focused
+// window too.
+func watchScanner(device string, baud int) (io.ReadCloser, error) {
+	return openInput(device, baud, false)
+}
+
+func openInput(device string, baud int, grab bool) (io.ReadCloser, error) {
+	if !strings.HasPrefix(device, "/dev/input/") {
+		return openSerial(device, baud)
+	}
+	f, err := os.Open(device)
+	if err != nil {
+		return nil, err
+	}
+	if !grab {
+		return &evdevReader{f: f}, nil
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), evioCGrab, 1); errno != 0 {
+		f.Close()
+		return nil, fmt.Errorf("grabbing %s: %w", device, errno)
+	}
+	return &evdevReader{f: f}, nil
+}
+
+// evdevReader turns key presses from an input event device back into the
+// text a US layout keyboard would have typed, with Enter as "\n".
+type evdevReader struct {
+	f     *os.File
+	shift bool
+	buf   []byte
+}
+
+func (r *evdevReader) Read(p []byte) (int, error) {
+	var ev inputEvent
+	raw := (*[unsafe.Sizeof(ev)]byte)(unsafe.Pointer(&ev))[:]
+	for len(r.buf) == 0 {
+		if _, err := io.ReadFull(r.f, raw); err != nil {
+			return 0, err
+		}
+		if ev.Type != evKey {
+			continue
+		}
+		switch ev.Code {
+		case keyCodeLeftShift, keyCodeRightShift:
+			r.shift = ev.Value != 0
+		case keyCodeEnter, keyCodeKPEnter:
+			if ev.Value == 1 {
+				r.buf = append(r.buf, '\n')
+			}
+		default:
+			if ev.Value != 1 {
+				continue
+			}
+			if c, ok := usRunes[usKey{ev.Code, r.shift}]; ok {
+				r.buf = append(r.buf, string(c)...)
+			}
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *evdevReader) Close() error {
+	return r.f.Close()
+}
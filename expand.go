@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// runExpand implements `chat-barcodes expand`, which turns scanned short
+// IDs back into their full messages:
+//
+//	chat-barcodes expand -device /dev/input/by-id/usb-Scanner-event-kbd -manifest sheet.json
+//
+// Keyboard wedge scanners are grabbed as by the bridge, so the ID never
+// reaches the focused window, and serial scanners are read as by the
+// typer. A scanned message ID or -numbers number of the manifest is pasted
+// as its message through the system clipboard, which is much faster than
+// typing long boilerplate and keeps every character intact; other scans
+// are pasted as they are.
+func runExpand(args []string) error {
+	fs := flag.NewFlagSet("expand", flag.ExitOnError)
+	device := fs.String("device", defaultSerialDevice, "serial port, or /dev/input/event* device for keyboard wedge scanners")
+	baud := fs.Int("baud", 9600, "serial baud rate")
+	manifestPath := fs.String("manifest", "", "manifest of the sheet, mapping its message IDs and numbers to their messages")
+	clipboard := fs.String("clipboard", defaultClipboard(), "command copying its input to the clipboard")
+	terminal := fs.Bool("terminal", false, "paste with Ctrl+Shift+V, as terminals want, rather than Ctrl+V")
+	enter := fs.Bool("enter", true, "send each message after pasting it")
+	targetName := fs.String("target", "", "chat application being pasted into, picks the send keys: "+targetNames())
+	stripAIMIDs := fs.Bool("strip-aim", true, "remove AIM symbology identifiers (]Q1 etc.) the scanner prepends")
+	speech := speechFlags(fs)
+	mqtt := mqttFlags(fs)
+	scanLog := scanLogFlags(fs)
+	fields := placeholderFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *manifestPath == "" {
+		return errors.New("usage: expand -manifest sheet.json [-device /dev/ttyACM0]")
+	}
+	m, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	expansions := m.expansions()
+	loaded := modTime(*manifestPath)
+	target, err := lookupTarget(*targetName)
+	if err != nil {
+		return err
+	}
+	paste := []key{keyCtrl, keyV}
+	if *terminal {
+		paste = []key{keyCtrl, keyShift, keyV}
+	}
+
+	port, err := openScanner(*device, *baud)
+	if err != nil {
+		return err
+	}
+	defer port.Close()
+
+	kb, err := newKeyboard()
+	if err != nil {
+		return err
+	}
+	defer kb.Close()
+
+	speaker := speech(m)
+	publisher, err := mqtt(m)
+	if err != nil {
+		return err
+	}
+	scans, err := scanLog(m)
+	if err != nil {
+		return err
+	}
+	log.Printf("expand: pasting the messages of %d codes scanned from %s", len(m.Cells), *device)
+	return readMessages(port, *stripAIMIDs, nil, func(scan, _ string) error {
+		// Messages reworded since the sheet was printed are picked up
+		// from a manifest made again with -indirect.
+		if t := modTime(*manifestPath); !t.Equal(loaded) {
+			if m, err := loadManifest(*manifestPath); err != nil {
+				log.Printf("expand: keeping the messages loaded before: %v", err)
+			} else {
+				expansions, loaded = m.expansions(), t
+				speaker.relabel(m)
+				publisher.relabel(m)
+				scans.relabel(m)
+				log.Printf("expand: reloaded %s", *manifestPath)
+			}
+		}
+		msg, ok := expansions[strings.TrimSpace(scan)]
+		if !ok {
+			msg = expandNumber(expansions, scan)
+		}
+		msg = fields.resolve(msg)
+		speaker.announce(scan, msg)
+		publisher.publish(scan, msg)
+		scans.record(scan, msg)
+		// A missing clipboard tool is worth stopping for, as every scan
+		// would fail the same way.
+		if err := copyToClipboard(*clipboard, msg); err != nil {
+			return fmt.Errorf("copying %q: %w", msg, err)
+		}
+		if err := kb.Press(paste...); err != nil {
+			return fmt.Errorf("pasting %q: %w", msg, err)
+		}
+		if !*enter {
+			return nil
+		}
+		return kb.Press(target.SendKeys...)
+	})
+}
+
+// expansions returns the messages of the manifest's cells by ID, and by
+// number with leading zeros dropped as scanPayloads has them: what a
+// shortened payload or -indirect ID stood for, or the payload itself.
+func (m *manifest) expansions() map[string]string {
+	expansions := m.scanPayloads()
+	for _, c := range m.Cells {
+		msg := c.Payload
+		if c.Original != "" {
+			msg = c.Original
+		}
+		expansions[c.ID] = msg
+		if c.Number != "" {
+			expansions[trimNumber(c.Number)] = msg
+		}
+	}
+	return expansions
+}
+
+// modTime returns when the file at path was last modified, or the zero
+// time if it can't be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
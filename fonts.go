@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// fontFlags are the font options of commands that render sheets.
+type fontFlags struct {
+	all       string
+	fallbacks fontListFlag
+	fonts     sheet.Fonts
+	antialias bool
+	subpixel  bool
+}
+
+func (f *fontFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.all, "font", "", "TTF/OTF font for all text instead of Go Regular")
+	fs.StringVar(&f.fonts.Title, "title-font", "", "font for the title, overrides -font")
+	fs.StringVar(&f.fonts.Label, "label-font", "", "font for labels, overrides -font")
+	fs.StringVar(&f.fonts.Description, "desc-font", "", "font for descriptions and the footer, overrides -font")
+	f.fallbacks.paths = sheet.FallbackFonts()
+	fs.Var(&f.fallbacks, "fallback-font", "font for characters the others lack, tried in order, may be repeated, '' for none")
+	fs.StringVar(&f.fonts.Emoji, "emoji-font", sheet.EmojiFont(), "monochrome emoji font for emoji the other fonts lack, empty for none")
+	fs.BoolVar(&f.antialias, "antialias", true, "anti-alias text; -antialias=false prints it crisp on thermal and low resolution printers")
+	fs.BoolVar(&f.subpixel, "subpixel", true, "place glyphs between pixels where they fall; -subpixel=false starts each on a whole pixel")
+}
+
+// Fonts returns the chosen fonts, -font filling in those not set on their
+// own.
+func (f *fontFlags) Fonts() sheet.Fonts {
+	fonts := f.fonts
+	fonts.Fallbacks = f.fallbacks.paths
+	fonts.Aliased, fonts.WholePixels = !f.antialias, !f.subpixel
+	for _, path := range []*string{&fonts.Title, &fonts.Label, &fonts.Description} {
+		if *path == "" {
+			*path = f.all
+		}
+	}
+	return fonts
+}
+
+// fontListFlag is a repeatable font path flag. Setting it replaces the
+// default rather than adding to it.
+type fontListFlag struct {
+	paths []string
+	set   bool
+}
+
+func (f *fontListFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.paths, ",")
+}
+
+func (f *fontListFlag) Set(s string) error {
+	if !f.set {
+		f.paths, f.set = nil, true
+	}
+	if s != "" {
+		f.paths = append(f.paths, s)
+	}
+	return nil
+}
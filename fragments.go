@@ -0,0 +1,61 @@
+package main
+
+import "strings"
+
+// Fragment payloads start with fragmentPrefix. Companion tools hold on to
+// fragments until sendCode (or a complete message) is scanned, then send
+// everything collected as one message. Both are plain ASCII so a keyboard
+// wedge typing them without a companion tool is harmless.
+const (
+	fragmentPrefix = "~+"
+	sendCode       = "~send"
+)
+
+// Fragments are mix-and-match pieces for composing replies from a small
+// sheet: a greeting, a body and a sign-off, followed by the send code.
+var Fragments = []ChatMsg{
+	// --- Greetings ---
+	{Code: fragmentPrefix + "Hi all,", Label: "Hi all", Description: "Greeting for a channel."},
+	{Code: fragmentPrefix + "Hi there,", Label: "Hi there", Description: "Greeting for one person."},
+	{Code: fragmentPrefix + "Thanks for reaching out –", Label: "Thanks for reaching out", Description: "Warm opener for support threads."},
+	{Code: fragmentPrefix + "Quick update:", Label: "Quick update", Description: "Lead-in for a status note."},
+
+	// --- Bodies ---
+	{Code: fragmentPrefix + "I’m looking into this now.", Label: "Looking now", Description: "You’re actively investigating."},
+	{Code: fragmentPrefix + "this should be fixed now.", Label: "Fixed now", Description: "Report a fix."},
+	{Code: fragmentPrefix + "we’re still investigating.", Label: "Still investigating", Description: "No news yet."},
+	{Code: fragmentPrefix + "can you share more detail?", Label: "More detail?", Description: "Ask for more information."},
+
+	// --- Sign-offs ---
+	{Code: fragmentPrefix + "I’ll follow up shortly.", Label: "Follow up", Description: "Promise a follow-up."},
+	{Code: fragmentPrefix + "Thanks!", Label: "Thanks", Description: "Simple sign-off."},
+	{Code: fragmentPrefix + "Let me know if it happens again.", Label: "Let me know", Description: "Invite a report if it recurs."},
+
+	// --- Terminator ---
+	{Code: sendCode, Label: "SEND", Description: "Sends everything scanned since the last send."},
+}
+
+// composer collects fragment scans into a single message.
+type composer struct {
+	parts []string
+}
+
+// Add feeds one scan to the composer. It returns the message to send and
+// true once a message is complete: on the send code, or when a regular
+// message is scanned, which is appended to any pending fragments.
+func (c *composer) Add(scan string) (string, bool) {
+	switch {
+	case strings.HasPrefix(scan, fragmentPrefix):
+		c.parts = append(c.parts, strings.TrimSpace(strings.TrimPrefix(scan, fragmentPrefix)))
+		return "", false
+	case scan == sendCode:
+		if len(c.parts) == 0 {
+			return "", false
+		}
+	default:
+		c.parts = append(c.parts, scan)
+	}
+	msg := strings.Join(c.parts, " ")
+	c.parts = nil
+	return msg, true
+}
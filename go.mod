@@ -6,9 +6,7 @@ require (
 	github.com/boombuler/barcode v1.1.0
 	github.com/fogleman/gg v1.3.0
 	golang.org/x/image v0.34.0
+	golang.org/x/text v0.32.0
 )
 
-require (
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	golang.org/x/text v0.32.0 // indirect
-)
+require github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// googleChatSpace is a Google Chat space ID, the part of its URL after
+// /room/ or "spaces/" in the API.
+var googleChatSpace = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// googleChatPayload encodes a link opening the Google Chat space given by
+// the space option, optionally at a thread. Google Chat can't pre-fill a
+// message from a link, so, like Slack's, this only opens the space: scan a
+// text code with -target googlechat to type the message, or post it with a
+// bridge running -format googlechat.
+func googleChatPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	space := strings.TrimPrefix(opts["space"], "spaces/")
+	if space == "" {
+		return "", fmt.Errorf("googlechat payloads need -payload-opt space=…, the ID in a space's chat.google.com/room/… link")
+	}
+	if !googleChatSpace.MatchString(space) {
+		return "", fmt.Errorf("%q is not a Google Chat space ID", opts["space"])
+	}
+	link := "https://chat.google.com/room/" + space
+	if thread := opts["thread"]; thread != "" {
+		if !googleChatSpace.MatchString(thread) {
+			return "", fmt.Errorf("%q is not a Google Chat thread ID", thread)
+		}
+		link += "/" + thread
+	}
+	return link, nil
+}
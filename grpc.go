@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// grpcGenerate is the path of SheetService.Generate, see proto/sheet.proto.
+const grpcGenerate = "/chatbarcodes.v1.SheetService/Generate"
+
+// gRPC status codes, see
+// https://grpc.github.io/grpc/core/md_doc_statuscodes.html.
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcUnimplemented   = 12
+	grpcInternal        = 13
+)
+
+// grpcError is an error with the gRPC status it is reported as.
+type grpcError struct {
+	code int
+	err  error
+}
+
+func (e *grpcError) Error() string { return e.err.Error() }
+
+// grpcHandler serves SheetService.Generate, the gRPC counterpart of POST
+// /generate, over HTTP/2. Only uncompressed messages are supported, which is
+// what clients send unless told otherwise.
+func grpcHandler(s *server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			http.Error(w, "gRPC needs HTTP/2 and application/grpc", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+
+		code := grpcOK
+		if err := s.generateGRPC(w, r.Body); err != nil {
+			code = grpcInternal
+			var ge *grpcError
+			if errors.As(err, &ge) {
+				code = ge.code
+			} else {
+				s.metrics.fail("grpc")
+			}
+			w.Header().Set("Grpc-Message", grpcEscape(err.Error()))
+		}
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	})
+}
+
+// generateGRPC reads a GenerateRequest from body and writes back its pages.
+func (s *server) generateGRPC(w http.ResponseWriter, body io.Reader) error {
+	b, err := io.ReadAll(io.LimitReader(body, maxGenerateBody+6))
+	if err != nil {
+		return err
+	}
+	if len(b) < 5 || int(binary.BigEndian.Uint32(b[1:5])) != len(b)-5 {
+		return &grpcError{grpcInvalidArgument, errors.New("want a single request message of at most 1 MiB")}
+	}
+	if b[0] != 0 {
+		return &grpcError{grpcUnimplemented, errors.New("compressed messages are not supported")}
+	}
+	req, err := decodeGenerateRequest(b[5:])
+	if err != nil {
+		return &grpcError{grpcInvalidArgument, err}
+	}
+	l, err := s.config.generate(req)
+	if err != nil {
+		return &grpcError{grpcInvalidArgument, err}
+	}
+
+	send := func(number int, contentType string, data []byte) error {
+		m := appendProtoVarint(nil, 1, uint64(number))
+		m = appendProtoBytes(m, 2, []byte(contentType))
+		m = appendProtoBytes(m, 3, data)
+		frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(m)))
+		if _, err := w.Write(append(frame, m...)); err != nil {
+			return err
+		}
+		http.NewResponseController(w).Flush()
+		return nil
+	}
+	start := time.Now()
+	switch req.Options.Format {
+	case "", "png":
+		// Each page is rendered as it's sent, so only one is held at a time.
+		var took time.Duration
+		for i := range sheet.PageStarts(l.opts, l.cells) {
+			start := time.Now()
+			page, err := sheet.RenderPage(l.opts, l.cells, i)
+			if err != nil {
+				return err
+			}
+			took += time.Since(start)
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, page); err != nil {
+				return err
+			}
+			if err := send(i+1, "image/png", buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		s.metrics.rendered("grpc", took)
+		return nil
+	case "pdf":
+		var buf bytes.Buffer
+		if err := sheet.WritePDF(&buf, l.opts, l.cells); err != nil {
+			return err
+		}
+		s.metrics.rendered("grpc", time.Since(start))
+		return send(1, "application/pdf", buf.Bytes())
+	}
+	return &grpcError{grpcInvalidArgument, fmt.Errorf("unknown format %q, want png or pdf", req.Options.Format)}
+}
+
+// decodeGenerateRequest decodes a GenerateRequest message.
+func decodeGenerateRequest(b []byte) (*generateRequest, error) {
+	var req generateRequest
+	err := readProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			req.Title = string(f.b)
+		case 2:
+			m, err := decodeMessage(f.b)
+			if err != nil {
+				return err
+			}
+			req.Messages = append(req.Messages, m)
+		case 3:
+			k, v, err := f.mapEntry()
+			if err != nil {
+				return err
+			}
+			if req.Categories == nil {
+				req.Categories = map[string]string{}
+			}
+			req.Categories[k] = v
+		case 4:
+			return decodeSheetOptions(f.b, &req.Options)
+		case 5:
+			req.Language = string(f.b)
+		case 6:
+			k, v, err := f.mapEntry()
+			if err != nil {
+				return err
+			}
+			if req.Titles == nil {
+				req.Titles = map[string]string{}
+			}
+			req.Titles[k] = v
+		}
+		return nil
+	})
+	return &req, err
+}
+
+// decodeMessage decodes a Message.
+func decodeMessage(b []byte) (ChatMsg, error) {
+	var m ChatMsg
+	err := readProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			m.ID = string(f.b)
+		case 2:
+			m.Code = string(f.b)
+		case 3:
+			m.Label = string(f.b)
+		case 4:
+			m.Description = string(f.b)
+		case 5:
+			m.Category = string(f.b)
+		case 6:
+			m.Icon = string(f.b)
+		case 7:
+			m.Type = string(f.b)
+		case 8:
+			k, v, err := f.mapEntry()
+			if err != nil {
+				return err
+			}
+			if m.Fields == nil {
+				m.Fields = map[string]string{}
+			}
+			m.Fields[k] = v
+		case 9:
+			m.Size = int(int32(f.v))
+		case 10:
+			lang, v, err := f.mapEntry()
+			if err != nil {
+				return err
+			}
+			t, err := decodeTranslation([]byte(v))
+			if err != nil {
+				return err
+			}
+			if m.Translations == nil {
+				m.Translations = map[string]Translation{}
+			}
+			m.Translations[lang] = t
+		case 11:
+			m.Short = string(f.b)
+		case 12:
+			m.Long = string(f.b)
+		}
+		return nil
+	})
+	return m, err
+}
+
+// decodeTranslation decodes a Translation.
+func decodeTranslation(b []byte) (Translation, error) {
+	var t Translation
+	err := readProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			t.Code = string(f.b)
+		case 2:
+			t.Label = string(f.b)
+		case 3:
+			t.Description = string(f.b)
+		case 4:
+			t.Short = string(f.b)
+		case 5:
+			t.Long = string(f.b)
+		}
+		return nil
+	})
+	return t, err
+}
+
+// decodeSheetOptions decodes a SheetOptions into o.
+func decodeSheetOptions(b []byte, o *generateOptions) error {
+	return readProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			o.Format = string(f.b)
+		case 2:
+			o.Target = string(f.b)
+		case 3:
+			o.Payload = string(f.b)
+		case 4:
+			k, v, err := f.mapEntry()
+			if err != nil {
+				return err
+			}
+			if o.PayloadOpts == nil {
+				o.PayloadOpts = map[string]string{}
+			}
+			o.PayloadOpts[k] = v
+		case 5:
+			o.Theme = string(f.b)
+		case 6:
+			o.InvertCodes = f.bool()
+		case 7:
+			o.Watermark = string(f.b)
+		case 8:
+			o.DPI = f.double()
+		case 9:
+			o.Columns = int(int32(f.v))
+		case 10:
+			o.Fingerprint = f.bool()
+		case 11:
+			o.LargePrint = f.bool()
+		case 12:
+			o.CategoryColors = f.bool()
+		case 13:
+			o.AIMSafe = f.bool()
+		case 14:
+			o.PageRows = int(int32(f.v))
+		case 15:
+			o.PageNumbers = f.bool()
+		case 16:
+			o.KeepCategories = f.bool()
+		case 17:
+			o.Lang = string(f.b)
+		case 18:
+			o.CellStyle = string(f.b)
+		case 19:
+			o.Variant = string(f.b)
+		case 20:
+			o.Prefix = string(f.b)
+		case 21:
+			o.Suffix = string(f.b)
+		}
+		return nil
+	})
+}
+
+// grpcEscape percent-encodes a grpc-message trailer value.
+func grpcEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// haWebhookID is what Home Assistant accepts as a webhook ID.
+var haWebhookID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// homeAssistantPayload encodes a bridge code triggering the Home Assistant
+// automation whose webhook ID is the webhook option, then posting the
+// message to the bridge's -webhook, so one code can both say "heading
+// home" and turn the office lights off. by picks how the message is
+// carried, as for action codes.
+func homeAssistantPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	id := opts["webhook"]
+	if id == "" {
+		return "", errors.New("homeassistant payloads need -payload-opt webhook=…, the webhook ID of an automation's webhook trigger")
+	}
+	if !haWebhookID.MatchString(id) {
+		return "", fmt.Errorf("webhook ID %q must be letters, digits, _ or -", id)
+	}
+	return messageBridgeCode("ha", id, msg, opts["by"])
+}
+
+// homeAssistantService triggers the webhook of bridge codes of the form
+// cb://ha/<webhook>/<id> on the Home Assistant at base, sending the
+// message and its ID as trigger.json.message and trigger.json.id, then
+// posts the message with post.
+func homeAssistantService(set *messageSet, base string, post func(text string) error) bridgeService {
+	return func(u *url.URL, path []string) error {
+		if len(path) == 0 {
+			return fmt.Errorf("%s: no webhook", u)
+		}
+		if base == "" {
+			return errors.New("homeassistant codes need -homeassistant")
+		}
+		body := map[string]string{}
+		if len(path) > 1 || u.Query().Get("text") != "" {
+			text, err := bridgeText(set, u, path, 1)
+			if err != nil {
+				return err
+			}
+			body["message"] = text
+		}
+		if len(path) > 1 {
+			body["id"] = path[1]
+		}
+		if err := postJSON(strings.TrimSuffix(base, "/")+"/api/webhook/"+url.PathEscape(path[0]), body); err != nil {
+			return fmt.Errorf("homeassistant: %w", err)
+		}
+		if text, ok := body["message"]; ok {
+			return post(text)
+		}
+		return nil
+	}
+}
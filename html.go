@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image/png"
+	"os"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// htmlPage is the -html sheet: every cell as printed, in a list a screen
+// reader can step through, each image's alt text saying what its code sends
+// and a heading for each run of a category.
+var htmlPage = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1rem auto; max-width: {{.Width}}px; padding: 0 1rem; }
+ul { display: grid; grid-template-columns: repeat({{.Columns}}, 1fr); gap: 0; list-style: none; margin: 0 0 1rem; padding: 0; }
+li { margin: 0; }
+figure { margin: 0; }
+img { display: block; height: auto; width: 100%; }
+.visually-hidden { clip: rect(0 0 0 0); clip-path: inset(50%); height: 1px; overflow: hidden; position: absolute; white-space: nowrap; width: 1px; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+{{range .Groups}}<section{{if .Heading}} aria-labelledby="{{.ID}}"{{else}} aria-label="Codes"{{end}}>
+{{if .Heading}}<h2 id="{{.ID}}">{{.Heading}}</h2>
+{{end}}<ul role="list" aria-label="{{len .Cells}} codes">
+{{range .Cells}}<li style="grid-column: span {{.Span}}; grid-row: span {{.Span}}">
+<figure>
+<img src="{{.Image}}" width="{{.Width}}" height="{{.Height}}" alt="QR code sending: {{.Payload}}">
+<figcaption class="visually-hidden">{{if .Number}}{{.Number}}: {{end}}{{.Label}}{{if .Description}}. {{.Description}}{{end}}</figcaption>
+</figure>
+</li>
+{{end}}</ul>
+</section>
+{{end}}</main>
+</body>
+</html>
+`))
+
+// htmlGroup is a run of cells of one category on the -html sheet.
+type htmlGroup struct {
+	ID, Heading string
+	Cells       []htmlCell
+}
+
+type htmlCell struct {
+	Label, Description, Number, Payload string
+	Image                               template.URL
+	Width, Height, Span                 int
+}
+
+// writeHTML writes the cells of l to path as an HTML page for reading and
+// scanning on screen, accessible to screen readers: each cell is its
+// rendered image, with the message its code sends as alt text and its
+// label and description as its caption.
+func writeHTML(path string, l *layout) error {
+	var groups []htmlGroup
+	for i, cell := range l.cells {
+		width, height := l.opts.CellSize(l.cells, i)
+		img, err := sheet.RenderCell(l.opts, cell, width, height)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		if len(groups) == 0 || groups[len(groups)-1].Heading != cell.Category {
+			groups = append(groups, htmlGroup{ID: fmt.Sprintf("category-%d", len(groups)+1), Heading: cell.Category})
+		}
+		payload := l.texts[i]
+		label := cell.Label
+		if label == "" {
+			label = payload
+		}
+		g := &groups[len(groups)-1]
+		// Images are shown at their printed size on a 96 DPI screen.
+		g.Cells = append(g.Cells, htmlCell{
+			Label: label, Description: cell.Description, Number: cell.Number, Payload: payload,
+			Image: template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())),
+			Width: int(float64(width) * 96 / l.opts.DPI), Height: int(float64(height) * 96 / l.opts.DPI),
+			Span: max(1, cell.Span),
+		})
+	}
+
+	lang := l.set.Language
+	if lang == "" {
+		lang = "en"
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = htmlPage.Execute(f, map[string]any{
+		"Lang":    lang,
+		"Title":   l.opts.Title,
+		"Columns": l.opts.Columns,
+		"Width":   int(l.opts.WidthInches * 96),
+		"Groups":  groups,
+	})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
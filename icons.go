@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// bundledIcons are the icons messages can name without supplying an image.
+//
+//go:embed icons/*.svg
+var bundledIcons embed.FS
+
+// iconLoader loads message icons, once each.
+type iconLoader struct {
+	dir    string // user icon paths are relative to this
+	height int
+	icons  map[string]image.Image
+
+	bundledOnly bool // refuse icon paths, for message sets from elsewhere
+	localOnly   bool // refuse icon paths outside dir, for -pack bundles
+}
+
+// load returns the icon a message names: a bundled icon, or else the path of
+// an SVG, PNG, JPEG or GIF image.
+func (l *iconLoader) load(name string) (image.Image, error) {
+	if img, ok := l.icons[name]; ok {
+		return img, nil
+	}
+	var img image.Image
+	if b, err := fs.ReadFile(bundledIcons, "icons/"+name+".svg"); err == nil {
+		if img, err = sheet.RasterizeSVG(bytes.NewReader(b), l.height); err != nil {
+			return nil, err
+		}
+	} else if l.bundledOnly {
+		return nil, fmt.Errorf("icon %q is not one of %s", name, iconNames())
+	} else if l.localOnly && !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("icon %q is outside the pack", name)
+	} else {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(l.dir, path)
+		}
+		if img, err = loadGraphic(path, l.height); err != nil {
+			return nil, fmt.Errorf("icon %q is not one of %s or an image: %w", name, iconNames(), err)
+		}
+	}
+	if l.icons == nil {
+		l.icons = map[string]image.Image{}
+	}
+	l.icons[name] = img
+	return img, nil
+}
+
+// isBundledIcon reports whether name is a bundled icon.
+func isBundledIcon(name string) bool {
+	_, err := fs.Stat(bundledIcons, "icons/"+name+".svg")
+	return err == nil
+}
+
+// iconNames lists the bundled icons.
+func iconNames() string {
+	entries, _ := bundledIcons.ReadDir("icons")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".svg"))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
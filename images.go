@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// loadImage reads a PNG, JPEG or GIF image.
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// loadGraphic reads a raster image like loadImage, or rasterises an SVG
+// image height pixels high.
+func loadGraphic(path string, height int) (image.Image, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".svg") {
+		return loadImage(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := sheet.RasterizeSVG(f, height)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ircMaxText is the longest message text we allow. IRC lines are capped at
+// 512 bytes including the command, target, the prefix the server adds when
+// relaying and CRLF; 400 leaves room for all of those.
+const ircMaxText = 400
+
+// validateIRC rejects text that can't be sent as a single IRC message.
+func validateIRC(text string) error {
+	if strings.ContainsAny(text, "\r\n\x00") {
+		return fmt.Errorf("%q: IRC messages can't contain CR, LF or NUL", text)
+	}
+	if strings.ContainsRune(text, '\x01') {
+		return fmt.Errorf("%q: \\x01 starts a CTCP request; use -payload-opt me=true for actions", text)
+	}
+	if len(text) > ircMaxText {
+		return fmt.Errorf("%q: %d bytes is too long for one IRC message (max %d)", text, len(text), ircMaxText)
+	}
+	return nil
+}
+
+// ircPayload turns a message into an IRC client command. With the to
+// option it is sent to a channel (#, &, + or ! prefix) or nick using /msg,
+// otherwise to the current window; me=true sends it as an action.
+func ircPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	if err := validateIRC(msg.Code); err != nil {
+		return "", err
+	}
+	to, me := opts["to"], opts["me"] == "true"
+	if to != "" {
+		if err := validateIRCTarget(to); err != nil {
+			return "", err
+		}
+	}
+	switch {
+	case me && to != "":
+		return "/describe " + to + " " + msg.Code, nil
+	case me:
+		return "/me " + msg.Code, nil
+	case to != "":
+		return "/msg " + to + " " + msg.Code, nil
+	}
+	return targets["irc"].Escape(msg.Code), nil
+}
+
+func validateIRCTarget(to string) error {
+	if to == "" || strings.ContainsAny(to, " ,\x07\r\n\x00") {
+		return fmt.Errorf("%q is not a valid IRC channel or nick", to)
+	}
+	if strings.ContainsRune("#&+!", rune(to[0])) {
+		if len(to) == 1 {
+			return errors.New("IRC channel name is empty")
+		}
+		return nil
+	}
+	if strings.ContainsRune("0123456789-", rune(to[0])) {
+		return fmt.Errorf("%q: IRC nicks can't start with a digit or dash", to)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Linux input event codes, see linux/input-event-codes.h.
+const (
+	evSyn = 0x00
+	evKey = 0x01
+
+	keyCodeEnter     = 28
+	keyCodeLeftCtrl  = 29
+	keyCodeLeftShift = 42
+	keyCodeSpace     = 57
+	keyCodeU         = 22
+	keyCodeV         = 47
+)
+
+// uinput ioctls, see linux/uinput.h.
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+)
+
+// usKey is the key code producing a rune on a US layout and whether Shift
+// has to be held for it.
+type usKey struct {
+	code  uint16
+	shift bool
+}
+
+// usKeys maps printable ASCII to its usKey.
+var usKeys = map[rune]usKey{}
+
+func init() {
+	rows := []struct {
+		plain, shifted string
+		first          uint16
+	}{
+		{"1234567890-=", "!@#$%^&*()_+", 2},
+		{"qwertyuiop[]", "QWERTYUIOP{}", 16},
+		{"asdfghjkl;'`", "ASDFGHJKL:\"~", 30},
+		{"\\zxcvbnm,./", "|ZXCVBNM<>?", 43},
+	}
+	for _, row := range rows {
+		shifted := []rune(row.shifted)
+		for i, r := range []rune(row.plain) {
+			code := row.first + uint16(i)
+			usKeys[r] = usKey{code, false}
+			usKeys[shifted[i]] = usKey{code, true}
+		}
+	}
+	usKeys[' '] = usKey{keyCodeSpace, false}
+}
+
+var linuxKeyCodes = map[key]uint16{
+	keyEnter: keyCodeEnter,
+	keyCtrl:  keyCodeLeftCtrl,
+	keyShift: keyCodeLeftShift,
+	keyV:     keyCodeV,
+}
+
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// uinputUserDev mirrors struct uinput_user_dev used by the legacy (but
+// universally supported) device setup protocol.
+type uinputUserDev struct {
+	Name       [80]byte
+	Bustype    uint16
+	Vendor     uint16
+	Product    uint16
+	Version    uint16
+	EffectsMax uint32
+	Absmax     [64]int32
+	Absmin     [64]int32
+	Absfuzz    [64]int32
+	Absflat    [64]int32
+}
+
+// uinputKeyboard is a virtual keyboard created through /dev/uinput. The
+// user running the typer needs write access to /dev/uinput.
+type uinputKeyboard struct {
+	f *os.File
+}
+
+func newKeyboard() (keyboard, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening uinput: %w", err)
+	}
+	kb := &uinputKeyboard{f: f}
+
+	if err := kb.ioctl(uiSetEvBit, evKey); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// Enable every key code we might emit.
+	for code := uint16(1); code <= keyCodeSpace; code++ {
+		if err := kb.ioctl(uiSetKeyBit, uintptr(code)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	var dev uinputUserDev
+	copy(dev.Name[:], "chat-barcodes typer")
+	dev.Bustype = 0x03 // BUS_USB
+	dev.Vendor = 0x1209
+	dev.Product = 0xcb01
+	dev.Version = 1
+	if _, err := f.Write((*[unsafe.Sizeof(dev)]byte)(unsafe.Pointer(&dev))[:]); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("setting up uinput device: %w", err)
+	}
+	if err := kb.ioctl(uiDevCreate, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Give udev and the display server a moment to pick up the new device,
+	// otherwise the first keystrokes are lost.
+	time.Sleep(500 * time.Millisecond)
+	return kb, nil
+}
+
+func (kb *uinputKeyboard) ioctl(req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, kb.f.Fd(), req, arg); errno != 0 {
+		return fmt.Errorf("uinput ioctl %#x: %w", req, errno)
+	}
+	return nil
+}
+
+func (kb *uinputKeyboard) emit(typ, code uint16, value int32) error {
+	ev := inputEvent{Type: typ, Code: code, Value: value}
+	_, err := kb.f.Write((*[unsafe.Sizeof(ev)]byte)(unsafe.Pointer(&ev))[:])
+	return err
+}
+
+func (kb *uinputKeyboard) chord(codes ...uint16) error {
+	for _, c := range codes {
+		if err := kb.emit(evKey, c, 1); err != nil {
+			return err
+		}
+	}
+	for i := len(codes) - 1; i >= 0; i-- {
+		if err := kb.emit(evKey, codes[i], 0); err != nil {
+			return err
+		}
+	}
+	if err := kb.emit(evSyn, 0, 0); err != nil {
+		return err
+	}
+	// Some clients drop keys that arrive faster than they poll.
+	time.Sleep(2 * time.Millisecond)
+	return nil
+}
+
+// Type sends s using a US key map. Runes with no key on that layout are
+// entered with the Ctrl+Shift+U hex sequence understood by GTK and IBus.
+func (kb *uinputKeyboard) Type(s string) error {
+	for _, r := range s {
+		k, ok := usKeys[r]
+		switch {
+		case ok && k.shift:
+			if err := kb.chord(keyCodeLeftShift, k.code); err != nil {
+				return err
+			}
+		case ok:
+			if err := kb.chord(k.code); err != nil {
+				return err
+			}
+		default:
+			if err := kb.typeUnicode(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (kb *uinputKeyboard) typeUnicode(r rune) error {
+	if err := kb.chord(keyCodeLeftCtrl, keyCodeLeftShift, keyCodeU); err != nil {
+		return err
+	}
+	for _, h := range strconv.FormatInt(int64(r), 16) {
+		if err := kb.chord(usKeys[h].code); err != nil {
+			return err
+		}
+	}
+	return kb.chord(keyCodeSpace)
+}
+
+func (kb *uinputKeyboard) Press(keys ...key) error {
+	codes := make([]uint16, 0, len(keys))
+	for _, k := range keys {
+		c, ok := linuxKeyCodes[k]
+		if !ok {
+			log.Printf("typer: no key code for key %d", k)
+			continue
+		}
+		codes = append(codes, c)
+	}
+	return kb.chord(codes...)
+}
+
+func (kb *uinputKeyboard) Close() error {
+	kb.ioctl(uiDevDestroy, 0)
+	return kb.f.Close()
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newKeyboard() (keyboard, error) {
+	return nil, fmt.Errorf("keyboard injection is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+const (
+	inputKeyboard    = 1
+	keyeventfKeyUp   = 0x0002
+	keyeventfUnicode = 0x0004
+	vkReturn         = 0x0D
+	vkShift          = 0x10
+	vkControl        = 0x11
+	vkV              = 0x56
+)
+
+var procSendInput = syscall.NewLazyDLL("user32.dll").NewProc("SendInput")
+
+var windowsVirtualKeys = map[key]uint16{
+	keyEnter: vkReturn,
+	keyCtrl:  vkControl,
+	keyShift: vkShift,
+	keyV:     vkV,
+}
+
+// keybdInput mirrors KEYBDINPUT.
+type keybdInput struct {
+	vk        uint16
+	scan      uint16
+	flags     uint32
+	time      uint32
+	extraInfo uintptr
+}
+
+// input mirrors INPUT with the keyboard member of the union selected; the
+// padding covers the larger MOUSEINPUT member.
+type input struct {
+	typ uint32
+	ki  keybdInput
+	_   [8]byte
+}
+
+// sendInputKeyboard injects keystrokes with SendInput. Text is sent as
+// KEYEVENTF_UNICODE events so it is independent of the keyboard layout.
+type sendInputKeyboard struct{}
+
+func newKeyboard() (keyboard, error) {
+	if err := procSendInput.Find(); err != nil {
+		return nil, err
+	}
+	return sendInputKeyboard{}, nil
+}
+
+func sendInput(inputs []input) error {
+	if len(inputs) == 0 {
+		return nil
+	}
+	n, _, err := procSendInput.Call(uintptr(len(inputs)), uintptr(unsafe.Pointer(&inputs[0])), unsafe.Sizeof(inputs[0]))
+	if int(n) != len(inputs) {
+		return fmt.Errorf("SendInput: %w", err)
+	}
+	return nil
+}
+
+func (sendInputKeyboard) Type(s string) error {
+	var inputs []input
+	for _, u := range utf16.Encode([]rune(s)) {
+		inputs = append(inputs,
+			input{typ: inputKeyboard, ki: keybdInput{scan: u, flags: keyeventfUnicode}},
+			input{typ: inputKeyboard, ki: keybdInput{scan: u, flags: keyeventfUnicode | keyeventfKeyUp}},
+		)
+	}
+	return sendInput(inputs)
+}
+
+func (sendInputKeyboard) Press(keys ...key) error {
+	var inputs []input
+	for _, k := range keys {
+		inputs = append(inputs, input{typ: inputKeyboard, ki: keybdInput{vk: windowsVirtualKeys[k]}})
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		inputs = append(inputs, input{typ: inputKeyboard, ki: keybdInput{vk: windowsVirtualKeys[keys[i]], flags: keyeventfKeyUp}})
+	}
+	return sendInput(inputs)
+}
+
+func (sendInputKeyboard) Close() error { return nil }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runKeygen creates a key pair for sealed payloads:
+//
+//	chat-barcodes keygen -o chat.key
+//
+// writes the secret key, for the typer's or bridge's -decrypt-key, and
+// prints the public key to pass to -encrypt-to when generating sheets.
+func runKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := fs.String("o", "", "file to write the secret key to, which must not exist yet")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("usage: keygen -o chat.key")
+	}
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	public := encodePublicKey(key.PublicKey())
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f, "# created: %s\n# public key: %s\n%s%s\n", time.Now().Format(time.RFC3339), public, secretPrefix, keyEncoding.EncodeToString(key.Bytes()))
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Println("Saved:", *out)
+	fmt.Println("Public key:", public)
+	return nil
+}
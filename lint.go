@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// printLint writes a line per code with warnings, naming cells as the
+// manifest does, then a summary.
+func printLint(w io.Writer, lints []sheet.CodeLint, opts sheet.Options, cells []sheet.Cell) {
+	positions := sheet.Positions(opts, cells)
+	warned := 0
+	for _, l := range lints {
+		if len(l.Warnings) == 0 {
+			continue
+		}
+		warned++
+		name := "footer"
+		if l.Cell >= 0 {
+			page, cell := cellPosition(positions[l.Cell], opts)
+			name = "cell " + cell
+			if page > 0 {
+				name = fmt.Sprintf("page %d %s", page, name)
+			}
+		}
+		fmt.Fprintf(w, "%s: %s\n", name, strings.Join(l.Warnings, "; "))
+	}
+	fmt.Fprintf(w, "Lint: %d of %d codes with warnings\n", warned, len(lints))
+}
+
+// distanceUnits are the units -scan-distance accepts, in millimetres.
+var distanceUnits = map[string]float64{"mm": 1, "cm": 10, "m": 1000, "in": 25.4, "ft": 304.8}
+
+// parseDistance parses a distance such as 50cm into millimetres, 0 for "".
+func parseDistance(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	num := strings.TrimRight(s, "abcdefghijklmnopqrstuvwxyz")
+	unit, ok := distanceUnits[s[len(num):]]
+	v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if !ok || err != nil || v <= 0 {
+		return 0, fmt.Errorf("bad distance %q, want e.g. 50cm, 500mm or 2ft", s)
+	}
+	return v * unit, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// locale is the text printed around the messages, read from a -locale
+// file to match the language of the message set:
+//
+//	{"title": "Códigos QR de chat", "page": "Página {page} de {pages}", …}
+//
+// Empty strings keep the English defaults.
+type locale struct {
+	Title          string `json:"title,omitempty"`           // when the message set has none
+	FragmentsTitle string `json:"fragments_title,omitempty"` // of the -fragments sheet
+	EmojiTitle     string `json:"emoji_title,omitempty"`     // of the -emoji sheet
+	Subtitle       string `json:"subtitle,omitempty"`        // "For {target} – program the scanner suffix as {send}"
+	Footer         string `json:"footer,omitempty"`          // URL printed and encoded at the bottom
+
+	// Badges of cells on diff sheets, see runDiff.
+	New     string `json:"new,omitempty"`
+	Changed string `json:"changed,omitempty"`
+	Moved   string `json:"moved,omitempty"`
+
+	sheet.Strings
+}
+
+// loadLocale reads a locale file, or returns the defaults if path is empty.
+func loadLocale(path string) (*locale, error) {
+	var l locale
+	if path == "" {
+		return &l, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &l, nil
+}
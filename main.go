@@ -1,231 +1,369 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"image/color"
 	"log"
-	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 
-	"github.com/boombuler/barcode"
-	"github.com/boombuler/barcode/qr"
+	"github.com/arran4/chat-barcodes/sheet"
 	"github.com/fogleman/gg"
-	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/goregular"
-	"golang.org/x/image/font/opentype"
 )
 
-// Scanner always appends a newline (<CR> / Enter).
-// All Code values are complete messages and do NOT include newline characters.
-
-type ChatMsg struct {
-	Code        string // exact text encoded in the QR code (no newline)
-	Label       string // short label under QR code
-	Description string // longer explanation under the label
-}
-
-// 36 messages => 4 x 9 grid.
-var Messages = []ChatMsg{
-	// --- Status / presence ---
-	{"On my way, be there soon.", "On my way", "Quick status: in transit, joining soon."},
-	{"BRB – back in 5 minutes.", "BRB 5", "Short break, back in 5."},
-	{"AFK for a bit, I’ll respond when I’m back.", "AFK", "Away-from-keyboard notice."},
-	{"Stepping out, please continue without me.", "Stepping out", "Let others know they can continue."},
-
-	// --- General acknowledgements ---
-	{"Got it, thanks!", "Got it", "Simple acknowledgement."},
-	{"Thanks for the heads up.", "Heads up", "Acknowledges a warning or FYI."},
-	{"Thanks, I’ll take a look.", "I'll look", "You’re taking ownership to investigate."},
-	{"Thanks, this is really helpful.", "Helpful", "Extra appreciative acknowledgement."},
-
-	// --- Requesting info ---
-	{"Can you please share a screenshot of the issue?", "Screenshot?", "Ask for a screenshot."},
-	{"Can you please paste the error message here?", "Error msg?", "Ask for the exact error message."},
-	{"Which OS / browser / version are you using?", "Env details?", "Ask for environment details."},
-	{"Can you describe the steps to reproduce this?", "Repro steps?", "Ask for a clear repro."},
-
-	// --- Triage / queueing ---
-	{"I’ve noted this down – it might take a little while before I can dig in.", "Noted, queued", "You’ve captured the issue, not immediate."},
-	{"I’m looking into this now.", "Looking now", "You’re actively investigating."},
-	{"This looks important – I’m prioritising it.", "Prioritising", "You’re giving it priority."},
-	{"Thanks – I think this is a duplicate of an existing issue, I’ll cross-link it.", "Duplicate", "Triage as duplicate."},
-
-	// --- Moderation / boundaries ---
-	{"Let’s keep the conversation respectful and on-topic, please.", "Respectful", "Gentle moderation reminder."},
-	{"This thread is getting heated – please take a break and come back later.", "Cool down", "Ask people to cool off."},
-	{"Please move this conversation to the appropriate channel.", "Wrong channel", "Redirect to the right channel."},
-	{"I’m going to lock this thread if the tone doesn’t improve.", "Tone warning", "Clear warning for behaviour."},
-
-	// --- Dev / infra / deploy chatter ---
-	{"Deploying to production now – expect a brief disruption.", "Deploying now", "Deploy in progress notice."},
-	{"Deployment finished successfully.", "Deploy OK", "Deployment success message."},
-	{"We’re rolling back this deployment due to issues.", "Rolling back", "Rollback notice."},
-	{"We’re investigating an issue in production – updates soon.", "Prod issue", "Production incident notice."},
-
-	// --- Support / closing loops ---
-	{"I believe this should be fixed now – can you confirm?", "Please confirm", "Ask user to verify fix."},
-	{"Closing this out for now – feel free to reopen if it happens again.", "Closing", "Gentle closure message."},
-	{"Thanks for your patience while we sorted this out.", "Thanks for patience", "Thank users after delays."},
-	{"Thanks again for the report – this really helps us improve.", "Thanks for report", "Reinforce helpfulness."},
-
-	// --- Generic “nice” utilities ---
-	{"Good morning! 👋", "GM", "Quick morning greeting."},
-	{"Good night, talk to you all tomorrow.", "GN", "Quick goodnight."},
-	{"Congratulations, that’s awesome news! 🎉", "Congrats", "Celebrate good news."},
-	{"Happy birthday! 🎂", "Birthday", "Birthday wish."},
-
-	// --- Meta / fallback messages ---
-	{"I don’t have enough context yet – can you give me a bit more detail?", "More context?", "Ask for more info, generic."},
-	{"I might be slow to respond for a while, but I am reading everything.", "Slow replies", "Set expectation for slower replies."},
-	{"I’ve created an internal note/ticket for this, and we’ll track it from there.", "Internal ticket", "Let them know it’s being tracked."},
-	{"If anyone else experiences this, please react to this message so we can gauge impact.", "React to gauge", "Ask for reactions to measure impact."},
+// commands maps subcommand names to their implementations. Running the
+// binary without a subcommand generates the sheet.
+var commands = map[string]func(args []string) error{
+	"generate":  runGenerate,
+	"typer":     runTyper,
+	"setup":     runSetup,
+	"calibrate": runCalibrate,
+	"printtest": runPrintTest,
+	"bridge":    runBridge,
+	"serve":     runServe,
+	"pack":      runPack,
+	"diff":      runDiff,
+	"merge":     runMerge,
+	"keygen":    runKeygen,
+	"expand":    runExpand,
+	"announce":  runAnnounce,
+	"report":    runReport,
+	"project":   runProject,
 }
 
-// font cache so we only parse Go Regular once per size.
-var fontCache = map[float64]font.Face{}
-
 func main() {
-	// A4 @ 300 DPI
-	const dpi = 300
-	const a4WidthInches = 8.27
-	const a4HeightInches = 11.69
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatalf("%s: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
 
-	width := int(a4WidthInches * dpi)
-	height := int(a4HeightInches * dpi)
+	if err := runGenerate(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	dc := gg.NewContext(width, height)
+// runGenerate renders the message sheet, by default to chat-qr-a4.png.
+func runGenerate(args []string) error {
+	return generateSheets(args, "", nil)
+}
 
-	// Background
-	dc.SetRGB(1, 1, 1)
-	dc.Clear()
+// generateSheets renders the sheets args ask for, with batch added to the
+// names of the files written; see -all. A sheet picking its messages like
+// a team, such as a project's, is given as pick.
+func generateSheets(args []string, batch string, pick *team) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	out := fs.String("o", "chat-qr-a4.png", "output PNG")
+	manifestPath := fs.String("manifest", "", "also write a JSON manifest of every printed cell to this file")
+	verify := fs.Bool("verify", false, "decode every QR code back from the written sheet and fail if any doesn't read as its payload")
+	quietZone := fs.Float64("verify-quiet-zone", 0, "with -verify, light space in modules required around each code")
+	densityPath := fs.String("density", "", "also write each QR code's version, modules, module size and payload bytes to this file, as JSON for .json and a table otherwise, - for standard output")
+	lint := fs.Bool("lint", false, "warn about codes on the written sheet that are likely hard to scan")
+	stats := fs.Bool("stats", false, "also write a summary page of message counts per category, payload lengths and QR versions, to the output with -stats added")
+	languages := fs.String("languages", "", "comma separated languages to print the message set's translations in, one sheet each named with -<lang> added, e.g. en,es")
+	ndefDir := fs.String("ndef", "", "also write each message as an NDEF record file for NFC tags into this directory")
+	ndefWriter := fs.String("ndef-writer", "", "with -ndef, command writing a tag from {file}, run for each message in turn")
+	markdownPath := fs.String("markdown", "", "also write a Markdown table of every printed cell's label, message and category to this file")
+	htmlPath := fs.String("html", "", "also write the sheet as an HTML page for screen readers, each code's alt text the message it sends, to this file")
+	ankiPath := fs.String("anki", "", "also write the messages as an Anki deck to this .apkg file, a card per code with its payload on the back")
+	numbersTable := fs.String("numbers-table", "", "also write a CSV table of the -numbers and the messages they stand for to this file; implies -numbers")
+	scriptPath := fs.String("script", "", "also write the messages as a script sending them by ID, number or hotkey without a scanner: AutoHotkey (.ahk) or xdotool/ydotool (.sh)")
+	cutLines := fs.String("cut-lines", "", "also write the outlines of the cells for cutting machines to this .svg or .dxf file")
+	teamsPath := fs.String("teams", "", "JSON file of teams, each with its messages and fields, to write a sheet for each named with -<team> added")
+	upload := fs.String("upload", "", "also render the sheet as a PDF and PUT it to this URL, e.g. a presigned storage URL, {lang} for the language; -digital-copy defaults to it without its query")
+	allDir := fs.String("all", "", "directory of message sets (.json) and chat packs (.chatpack, or imported directories) to render a sheet of each, named with -<file name> added")
+	cellCache := fs.String("cell-cache", "", "directory keeping rendered cells between runs, so only changed cells are redrawn")
+	var config sheetConfig
+	config.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *allDir != "" {
+		if pick != nil {
+			return errors.New("-all can't be used in project sheets")
+		}
+		if config.messagesPath != "" || config.roster != "" || config.pack != "" {
+			return errors.New("-all renders the message sets in its directory, so can't be given -messages, -roster or -pack")
+		}
+		configs, err := batchConfigs(*allDir)
+		if err != nil {
+			return err
+		}
+		// Each is rendered as if given on its own, its flags appended to
+		// override those of the batch.
+		for _, c := range configs {
+			if err := generateSheets(append(slices.Clone(args), "-all=", "-"+c.flag, c.path), "-"+c.name, nil); err != nil {
+				return fmt.Errorf("%s: %w", c.name, err)
+			}
+		}
+		return nil
+	}
+	cleanup, err := config.usePack(fs)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if *numbersTable != "" {
+		config.numbers = true
+	}
+	if *upload != "" && config.digitalCopy == "" {
+		config.digitalCopy = publicURL(*upload)
+	}
+	if config.shuffle.picked {
+		fmt.Printf("Shuffled: seed %d, -shuffle=%[1]d prints the same order again\n", config.shuffle.seed)
+	}
 
-	margin := 80.0
+	// generate writes the sheet and the files alongside it, with suffix
+	// added to their names.
+	generate := func(suffix string) error {
+		suffix = batch + suffix
+		name := func(path string) string {
+			if path == "" || suffix == "" {
+				return path
+			}
+			return suffixed(path, suffix)
+		}
+		out, manifestPath, ndef, anki, markdown := name(*out), name(*manifestPath), name(*ndefDir), name(*ankiPath), name(*markdownPath)
+		numbers, cuts, htmlPath, script, densityPath := name(*numbersTable), name(*cutLines), name(*htmlPath), name(*scriptPath), name(*densityPath)
+		upload := *upload
+		if config.team != nil {
+			upload = expandFields(upload, config.team.fields())
+		}
+		l, err := config.build()
+		if err != nil {
+			return err
+		}
+		if cuts != "" && l.opts.Tent {
+			return errors.New("-cut-lines can't be used with -tent")
+		}
+		if l.warning != "" {
+			fmt.Fprintln(os.Stderr, "Warning:", l.warning)
+		}
+		if len(l.untranslated) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d messages have no %s translation: %s\n", len(l.untranslated), l.set.Language, strings.Join(l.untranslated, ", "))
+		}
+		if len(l.normalized) > 0 {
+			fmt.Printf("Normalized: %d messages to %s: %s\n", len(l.normalized), strings.ToUpper(config.normalize), strings.Join(l.normalized, ", "))
+		}
+		if *cellCache != "" {
+			l.opts.Cache = sheet.DirCache(*cellCache)
+		}
 
-	// Title
-	dc.SetColor(color.Black)
-	dc.SetFontFace(mustGoRegularFace(24))
-	title := "Chat QR Codes – One Scan = One Message"
-	dc.DrawStringAnchored(title, float64(width)/2, margin/2, 0.5, 0.5)
+		saved, err := sheet.SavePNG(out, l.opts, l.cells)
+		if err != nil {
+			return fmt.Errorf("failed to save PNG: %w", err)
+		}
 
-	// Layout: 4 columns, N rows
-	cols := 4
-	rows := int(math.Ceil(float64(len(Messages)) / float64(cols)))
+		fmt.Println("Saved:", strings.Join(saved, ", "))
+
+		if *stats {
+			img, err := sheet.RenderStats(l.opts, l.cells)
+			if err != nil {
+				return err
+			}
+			path := suffixed(out, "-stats")
+			if err := gg.SavePNG(path, img); err != nil {
+				return fmt.Errorf("failed to save PNG: %w", err)
+			}
+			fmt.Println("Saved:", path)
+		}
 
-	top := margin
-	bottom := float64(height) - margin
-	left := margin
-	right := float64(width) - margin
+		if cuts != "" {
+			saved, err := sheet.SaveCutLines(cuts, l.opts, l.cells)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Saved:", strings.Join(saved, ", "))
+		}
 
-	cellWidth := (right - left) / float64(cols)
-	cellHeight := (bottom - top) / float64(rows)
+		if *verify {
+			if err := sheet.Verify(saved, l.opts, l.cells, *quietZone); err != nil {
+				return fmt.Errorf("verify:\n%w", err)
+			}
+			fmt.Println("Verified:", strings.Join(saved, ", "))
+		}
 
-	// QR codes are square; size them to fit comfortably in each cell.
-	qrSize := int(math.Min(cellWidth, cellHeight) * 0.6)
+		if upload != "" {
+			dest := strings.ReplaceAll(upload, "{lang}", l.set.Language)
+			if err := uploadPDF(dest, l); err != nil {
+				return fmt.Errorf("upload: %w", err)
+			}
+			fmt.Println("Uploaded:", publicURL(dest))
+		}
 
-	for i, msg := range Messages {
-		col := i % cols
-		row := i / cols
+		if *lint {
+			lints, err := sheet.Lint(saved, l.opts, l.cells)
+			if err != nil {
+				return err
+			}
+			printLint(os.Stdout, lints, l.opts, l.cells)
+		}
 
-		x := left + float64(col)*cellWidth
-		y := top + float64(row)*cellHeight
+		if densityPath != "" {
+			if err := writeDensity(densityPath, l); err != nil {
+				return err
+			}
+			if densityPath != "-" {
+				fmt.Println("Saved:", densityPath)
+			}
+		}
 
-		cx := x + cellWidth/2
+		if manifestPath != "" {
+			if err := l.manifest.save(manifestPath); err != nil {
+				return err
+			}
+			fmt.Println("Saved:", manifestPath)
+		}
 
-		// Light cell boundary
-		dc.SetLineWidth(0.4)
-		dc.SetColor(color.RGBA{R: 230, G: 230, B: 230, A: 255})
-		dc.DrawRectangle(x, y, cellWidth, cellHeight)
-		dc.Stroke()
+		if markdown != "" {
+			if err := writeMarkdown(markdown, l); err != nil {
+				return err
+			}
+			fmt.Println("Saved:", markdown)
+		}
 
-		// --- QR generation ---
-		raw, err := qr.Encode(msg.Code, qr.M, qr.Auto)
-		if err != nil {
-			log.Printf("QR encode error for %q: %v", msg.Code, err)
-			continue
+		if htmlPath != "" {
+			if err := writeHTML(htmlPath, l); err != nil {
+				return err
+			}
+			fmt.Println("Saved:", htmlPath)
 		}
 
-		scaled, err := barcode.Scale(raw, qrSize, qrSize)
-		if err != nil {
-			log.Printf("QR scale error for %q: %v", msg.Code, err)
-			continue
+		if numbers != "" {
+			if err := writeNumbersTable(numbers, l); err != nil {
+				return err
+			}
+			fmt.Println("Saved:", numbers)
 		}
 
-		// Draw QR near the top of the cell
-		bx := cx - float64(scaled.Bounds().Dx())/2
-		by := y + 6
-		dc.DrawImage(scaled, int(bx), int(by))
+		if script != "" {
+			n, err := writeScript(script, l)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Saved: %s (%d messages)\n", script, n)
+		}
 
-		// Label under QR
-		labelY := by + float64(qrSize) + 8
-		dc.SetColor(color.Black)
-		dc.SetFontFace(mustGoRegularFace(11))
-		label := msg.Label
-		if label == "" {
-			label = msg.Code
+		if anki != "" {
+			n, err := writeAnki(anki, l)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Saved: %s (%d cards)\n", anki, n)
 		}
-		dc.DrawStringAnchored(label, cx, labelY, 0.5, 0)
 
-		// Description under label
-		descY := labelY + 12
-		dc.SetFontFace(mustGoRegularFace(8))
-		dc.DrawStringWrapped(msg.Description, x+6, descY, 0, 0, cellWidth-12, 1.3, gg.AlignCenter)
+		if ndef != "" {
+			paths, err := writeNDEF(ndef, l)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Saved: %s (%d NDEF records)\n", ndef, len(paths))
+			if *ndefWriter != "" {
+				if err := writeTags(*ndefWriter, paths, os.Stdin, os.Stderr); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
 	}
 
-	// --- Footer: repo QR + text ---
-	footerText := "https://github.com/arran4/chat-barcodes"
-
-	footerRaw, err := qr.Encode(footerText, qr.M, qr.Auto)
-	if err != nil {
-		log.Printf("QR encode error for footer: %v", err)
-	} else {
-		// Keep the QR comfortably inside the bottom margin
-		footerSize := int(math.Min(float64(width)*0.18, margin*0.8))
-
-		footerScaled, err := barcode.Scale(footerRaw, footerSize, footerSize)
+	// Every team's sheet is made from the flags with its fields filled in.
+	teams := []*team{pick}
+	if *teamsPath != "" && pick != nil {
+		return errors.New("-teams can't be used in project sheets, which pick their own messages")
+	}
+	if *teamsPath != "" {
+		loaded, err := loadTeams(*teamsPath)
 		if err != nil {
-			log.Printf("QR scale error for footer: %v", err)
-		} else {
-			// Place QR above bottom margin, centered horizontally
-			fbX := float64(width)/2 - float64(footerScaled.Bounds().Dx())/2
-			fbY := float64(height) - margin - float64(footerSize) - 10
-			dc.DrawImage(footerScaled, int(fbX), int(fbY))
-
-			// Footer text just above the very bottom of the page
-			textY := float64(height) - 12
-			dc.SetColor(color.Black)
-			dc.SetFontFace(mustGoRegularFace(9))
-			dc.DrawStringAnchored(footerText, float64(width)/2, textY, 0.5, 0)
+			return err
+		}
+		teams = teams[:0]
+		for i := range loaded {
+			teams = append(teams, &loaded[i])
 		}
 	}
-
-	out := "chat-qr-a4.png"
-	if err := dc.SavePNG(out); err != nil {
-		log.Fatalf("failed to save PNG: %v", err)
+	prefix, suffix, digitalCopy := config.prefix, config.suffix, config.digitalCopy
+	for _, t := range teams {
+		name := ""
+		if t != nil {
+			fields := t.fields()
+			config.team, name = t, "-"+t.Name
+			config.prefix = expandFields(prefix, fields)
+			config.suffix = expandFields(suffix, fields)
+			config.digitalCopy = expandFields(digitalCopy, fields)
+		}
+		if *languages == "" {
+			if err := generate(name); err != nil {
+				return err
+			}
+			continue
+		}
+		// Every language's sheet has the same cells in the same places,
+		// named after the language.
+		for _, lang := range strings.Split(*languages, ",") {
+			lang = strings.TrimSpace(lang)
+			config.language = lang
+			if err := generate(name + "-" + lang); err != nil {
+				return fmt.Errorf("%s: %w", lang, err)
+			}
+		}
 	}
-
-	fmt.Println("Saved:", out)
+	return nil
 }
 
-// mustGoRegularFace returns a Go Regular font.Face at the given size,
-// always using the embedded goregular TTF.
-func mustGoRegularFace(size float64) font.Face {
-	if face, ok := fontCache[size]; ok {
-		return face
-	}
+// batchConfig is a message set or chat pack of an -all directory, rendered
+// with -messages or -pack.
+type batchConfig struct {
+	name, flag, path string
+}
 
-	fnt, err := opentype.Parse(goregular.TTF)
+// batchConfigs returns the message sets and chat packs in dir, in name
+// order. Other files are left alone.
+func batchConfigs(dir string) ([]batchConfig, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		log.Fatalf("failed to parse goregular TTF: %v", err)
+		return nil, err
 	}
-
-	face, err := opentype.NewFace(fnt, &opentype.FaceOptions{
-		Size:    size,
-		DPI:     72,
-		Hinting: font.HintingFull,
-	})
-	if err != nil {
-		log.Fatalf("failed to create goregular face (size=%.1f): %v", size, err)
+	var configs []batchConfig
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		ext := filepath.Ext(e.Name())
+		name := strings.TrimSuffix(e.Name(), ext)
+		switch {
+		case e.IsDir():
+			if _, err := os.Stat(filepath.Join(path, packManifest)); err == nil {
+				configs = append(configs, batchConfig{e.Name(), "pack", path})
+			}
+		case ext == ".chatpack":
+			configs = append(configs, batchConfig{name, "pack", path})
+		case ext == ".json":
+			configs = append(configs, batchConfig{name, "messages", path})
+		}
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no message sets or chat packs in %s", dir)
 	}
+	return configs, nil
+}
+
+// suffixed returns path with suffix added before its extension.
+func suffixed(path, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + suffix + ext
+}
 
-	fontCache[size] = face
-	return face
+func themeNames() string {
+	names := make([]string, 0, len(sheet.Themes))
+	for name := range sheet.Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
 }
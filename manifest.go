@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// manifest records what was printed: one entry per cell, in sheet order.
+type manifest struct {
+	Title string `json:"title"`
+
+	// Pack and Version name the message pack revision, see runPack.
+	Pack    string `json:"pack,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	// MessagesSHA256 is the SHA-256 of the message set, see messagesSHA256,
+	// and Fingerprint the short fingerprint printed with -fingerprint.
+	MessagesSHA256 string `json:"messages_sha256"`
+	Fingerprint    string `json:"fingerprint"`
+
+	// Shuffle is the -shuffle seed the cells were placed in order of.
+	Shuffle *uint64 `json:"shuffle,omitempty"`
+
+	// Indirect is set when the codes hold only their message IDs, see
+	// -indirect; each cell's Original is its message.
+	Indirect bool `json:"indirect,omitempty"`
+
+	Cells []manifestCell `json:"cells"`
+}
+
+type manifestCell struct {
+	Page    int    `json:"page,omitempty"` // from 1, for sheets printed on several pages
+	Cell    string `json:"cell"`           // column letter and row number, e.g. "C4"
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Payload string `json:"payload"`          // exactly what the QR code encodes
+	SHA256  string `json:"sha256"`           // of Payload
+	Number  string `json:"number,omitempty"` // printed with -numbers
+
+	// Set when a long payload was replaced by a short URL; Original alone
+	// is set on -indirect sheets.
+	Short    string `json:"short,omitempty"` // key served by the bridge under /s/
+	Original string `json:"original,omitempty"`
+}
+
+// messagesSHA256 returns the hex SHA-256 of msgs as JSON, changing with any
+// of their text, labels or settings.
+func messagesSHA256(msgs []ChatMsg) (string, error) {
+	b, err := json.Marshal(msgs)
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(b), nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprint returns a short fingerprint of everything the sheet's codes
+// encode: the first 12 hex digits of the SHA-256 of the cells' payload
+// hashes, in order.
+func (m *manifest) fingerprint() string {
+	var b strings.Builder
+	for _, c := range m.Cells {
+		b.WriteString(c.SHA256)
+	}
+	return sha256Hex([]byte(b.String()))[:12]
+}
+
+// cellName names the cell at a column and row: columns are letters, rows
+// count from 1.
+func cellName(col, row int) string {
+	return fmt.Sprintf("%c%d", 'A'+rune(col), row+1)
+}
+
+// cellPosition returns the page (0 on single page sheets) and name of a
+// cell at pos, see sheet.Positions. Cells spanning several are named by
+// their top left.
+func cellPosition(pos sheet.Position, opts sheet.Options) (page int, name string) {
+	if opts.PerPage() == 0 && opts.Duplex == "" {
+		return 0, cellName(pos.Column, pos.Row)
+	}
+	return pos.Page + 1, cellName(pos.Column, pos.Row)
+}
+
+func (m *manifest) save(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func loadManifest(path string) (*manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &m, nil
+}
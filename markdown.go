@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// markdownCell escapes s for a cell of a Markdown table.
+var markdownCell = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+// writeMarkdown writes the cells of l to path as a Markdown table of their
+// positions, labels, messages and categories: a text version of the sheet
+// for wikis and READMEs.
+func writeMarkdown(path string, l *layout) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", l.opts.Title)
+	if l.opts.Subtitle != "" {
+		fmt.Fprintf(&b, "%s\n\n", l.opts.Subtitle)
+	}
+	b.WriteString("| Cell | Label | Message | Category |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for i, c := range l.manifest.Cells {
+		label := c.Label
+		if label == "" {
+			label = l.texts[i]
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", position(c),
+			markdownCell.Replace(label), markdownCell.Replace(l.texts[i]), markdownCell.Replace(l.cells[i].Category))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Ways merge resolves a conflict, see runMerge.
+const (
+	keepAsk    = "ask"
+	keepFirst  = "first"
+	keepSecond = "second"
+	keepBoth   = "both"
+)
+
+// runMerge combines two message files into one, for consolidating sets
+// collected from several teams:
+//
+//	chat-barcodes merge [-o merged.json] [-keep ask|first|second|both] [-similarity 0.85] a.json b.json
+//
+// Messages of the second file are added after the first's. One with the
+// same ID as a different message of the first, or a payload nearly the
+// same as one of its messages', is a conflict, resolved by -keep or by
+// asking for each.
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := fs.String("o", "", "merged message file to write, standard output if empty")
+	keep := fs.String("keep", keepAsk, "how to resolve conflicts: ask (for each), first, second or both")
+	similarity := fs.Float64("similarity", 0.85, "how alike two payloads must be, from 0 to 1, to count as duplicates")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: merge [-o merged.json] [-keep ask|first|second|both] [-similarity 0.85] a.json b.json")
+	}
+	switch *keep {
+	case keepAsk, keepFirst, keepSecond, keepBoth:
+	default:
+		return fmt.Errorf("unknown -keep %q", *keep)
+	}
+	a, err := loadMessages(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := loadMessages(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	resolve := func(c mergeConflict) (string, error) { return *keep, nil }
+	if *keep == keepAsk {
+		in := bufio.NewReader(os.Stdin)
+		resolve = func(c mergeConflict) (string, error) { return askConflict(in, os.Stderr, c) }
+	}
+	merged, err := mergeSets(a, b, *similarity, resolve, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if err := merged.validate(); err != nil {
+		return err
+	}
+
+	j, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	j = append(j, '\n')
+	if *out == "" {
+		_, err := os.Stdout.Write(j)
+		return err
+	}
+	if err := os.WriteFile(*out, j, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved: %s (%d messages)\n", *out, len(merged.Messages))
+	return nil
+}
+
+// mergeConflict is a message of the second set clashing with one of the
+// first.
+type mergeConflict struct {
+	first, second ChatMsg
+	sameID        bool    // rather than a near-duplicate payload
+	similarity    float64 // of the payloads
+}
+
+func (c mergeConflict) String() string {
+	if c.sameID {
+		return fmt.Sprintf("%s is in both with different messages", c.first.Key())
+	}
+	return fmt.Sprintf("%s and %s are %.0f%% alike", c.first.Key(), c.second.Key(), c.similarity*100)
+}
+
+// mergeSets returns a with b's messages added, calling resolve with each
+// conflict (see runMerge) for one of the keep constants, and logging what
+// it did to log. The title, pack metadata and category colours are a's,
+// with b's filling in any it lacks.
+func mergeSets(a, b *messageSet, similarity float64, resolve func(mergeConflict) (string, error), log io.Writer) (*messageSet, error) {
+	merged := *a
+	merged.Messages = append([]ChatMsg(nil), a.Messages...)
+	if merged.Title == "" {
+		merged.Title = b.Title
+	}
+	if merged.Language == "" {
+		merged.Language = b.Language
+	}
+	if len(b.Categories) > 0 {
+		merged.Categories = map[string]string{}
+		for k, v := range b.Categories {
+			merged.Categories[k] = v
+		}
+		for k, v := range a.Categories {
+			merged.Categories[k] = v
+		}
+	}
+
+	ids := map[string]bool{}
+	for _, m := range merged.Messages {
+		ids[m.Key()] = true
+	}
+	for _, m := range b.Messages {
+		if o, ok := merged.find(m.Key()); ok && sameMessage(o, m) {
+			continue
+		}
+		i, c, ok := findConflict(merged.Messages, m, similarity)
+		if !ok {
+			merged.Messages = append(merged.Messages, m)
+			ids[m.Key()] = true
+			continue
+		}
+		keep, err := resolve(c)
+		if err != nil {
+			return nil, err
+		}
+		switch keep {
+		case keepFirst:
+			fmt.Fprintf(log, "%s: kept the first\n", c)
+		case keepSecond:
+			delete(ids, merged.Messages[i].Key())
+			merged.Messages[i] = m
+			ids[m.Key()] = true
+			fmt.Fprintf(log, "%s: kept the second\n", c)
+		case keepBoth:
+			if ids[m.Key()] {
+				m.ID = uniqueID(m.Key(), ids)
+			}
+			merged.Messages = append(merged.Messages, m)
+			ids[m.Key()] = true
+			fmt.Fprintf(log, "%s: kept both, the second as %s\n", c, m.Key())
+		}
+	}
+	return &merged, nil
+}
+
+// findConflict returns the index of the message in msgs that m conflicts
+// with, if any: one with its ID, or failing that the one whose payload is
+// most like m's, if at least similarity alike.
+func findConflict(msgs []ChatMsg, m ChatMsg, similarity float64) (int, mergeConflict, bool) {
+	for i, o := range msgs {
+		if o.Key() == m.Key() {
+			return i, mergeConflict{first: o, second: m, sameID: true, similarity: likeness(o.Code, m.Code)}, true
+		}
+	}
+	best, at := 0.0, -1
+	for i, o := range msgs {
+		if l := likeness(o.Code, m.Code); l > best {
+			best, at = l, i
+		}
+	}
+	if at < 0 || best < similarity {
+		return 0, mergeConflict{}, false
+	}
+	return at, mergeConflict{first: msgs[at], second: m, similarity: best}, true
+}
+
+// askConflict asks which side of c to keep, reading answers from in.
+func askConflict(in *bufio.Reader, out io.Writer, c mergeConflict) (string, error) {
+	fmt.Fprintf(out, "%s:\n  1: %q (%s)\n  2: %q (%s)\n", c, c.first.Code, c.first.Label, c.second.Code, c.second.Label)
+	for {
+		fmt.Fprint(out, "Keep 1, 2 or b(oth)? ")
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("reading answer: %w", err)
+		}
+		switch strings.TrimSpace(strings.ToLower(line)) {
+		case "1":
+			return keepFirst, nil
+		case "2":
+			return keepSecond, nil
+		case "b", "both":
+			return keepBoth, nil
+		}
+	}
+}
+
+// uniqueID returns id with the lowest number added that isn't in ids.
+func uniqueID(id string, ids map[string]bool) string {
+	for n := 2; ; n++ {
+		if next := fmt.Sprintf("%s-%d", id, n); !ids[next] {
+			return next
+		}
+	}
+}
+
+// likeness returns how alike payloads a and b are, from 0 to 1: one less
+// their edit distance over the longer's length, ignoring case, spacing and
+// punctuation.
+func likeness(a, b string) float64 {
+	ra, rb := []rune(fold(a)), []rune(fold(b))
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// fold lower-cases s and keeps only its letters and digits.
+func fold(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b []rune) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		diag := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			next := min(row[j]+1, row[j-1]+1, diag+cost)
+			diag, row[j] = row[j], next
+		}
+	}
+	return row[len(b)]
+}
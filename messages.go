@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Scanner always appends a newline (<CR> / Enter).
+// All Code values are complete messages and do NOT include newline characters.
+
+type ChatMsg struct {
+	ID          string `json:"id,omitempty"` // stable identifier, see Key
+	Code        string `json:"code"`         // exact text encoded in the QR code (no newline)
+	Label       string `json:"label"`        // short label under QR code
+	Description string `json:"description"`  // longer explanation under the label
+	Category    string `json:"category,omitempty"`
+	Icon        string `json:"icon,omitempty"` // bundled icon name or image path, see iconLoader
+	Size        int    `json:"size,omitempty"` // cells wide and high, for a bigger code; 1 if 0
+
+	// Short and Long are terser and fuller versions of Code, printed
+	// instead of it by -variant; see messageSet.variant.
+	Short string `json:"short,omitempty"`
+	Long  string `json:"long,omitempty"`
+
+	// Type picks the payload mode for this message, overriding -payload;
+	// Fields are its settings, overriding -payload-opt.
+	Type   string            `json:"type,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// Translations are the message in other languages by language code,
+	// e.g. "es", see messageSet.translate.
+	Translations map[string]Translation `json:"translations,omitempty"`
+}
+
+// Translation is a message in another language. Empty fields keep the
+// message's own.
+type Translation struct {
+	Code        string `json:"code,omitempty"`
+	Label       string `json:"label,omitempty"`
+	Description string `json:"description,omitempty"`
+	Short       string `json:"short,omitempty"`
+	Long        string `json:"long,omitempty"`
+}
+
+// 36 messages => 4 x 9 grid.
+var Messages = []ChatMsg{
+	// --- Status / presence ---
+	{Code: "On my way, be there soon.", Label: "On my way", Description: "Quick status: in transit, joining soon.", Category: "status"},
+	{Code: "BRB – back in 5 minutes.", Label: "BRB 5", Description: "Short break, back in 5.", Category: "status"},
+	{Code: "AFK for a bit, I’ll respond when I’m back.", Label: "AFK", Description: "Away-from-keyboard notice.", Category: "status"},
+	{Code: "Stepping out, please continue without me.", Label: "Stepping out", Description: "Let others know they can continue.", Category: "status"},
+
+	// --- General acknowledgements ---
+	{Code: "Got it, thanks!", Label: "Got it", Description: "Simple acknowledgement.", Category: "thanks"},
+	{Code: "Thanks for the heads up.", Label: "Heads up", Description: "Acknowledges a warning or FYI.", Category: "thanks"},
+	{Code: "Thanks, I’ll take a look.", Label: "I'll look", Description: "You’re taking ownership to investigate.", Category: "thanks"},
+	{Code: "Thanks, this is really helpful.", Label: "Helpful", Description: "Extra appreciative acknowledgement.", Category: "thanks"},
+
+	// --- Requesting info ---
+	{Code: "Can you please share a screenshot of the issue?", Label: "Screenshot?", Description: "Ask for a screenshot.", Category: "info"},
+	{Code: "Can you please paste the error message here?", Label: "Error msg?", Description: "Ask for the exact error message.", Category: "info"},
+	{Code: "Which OS / browser / version are you using?", Label: "Env details?", Description: "Ask for environment details.", Category: "info"},
+	{Code: "Can you describe the steps to reproduce this?", Label: "Repro steps?", Description: "Ask for a clear repro.", Category: "info"},
+
+	// --- Triage / queueing ---
+	{Code: "I’ve noted this down – it might take a little while before I can dig in.", Label: "Noted, queued", Description: "You’ve captured the issue, not immediate.", Category: "triage"},
+	{Code: "I’m looking into this now.", Label: "Looking now", Description: "You’re actively investigating.", Category: "triage"},
+	{Code: "This looks important – I’m prioritising it.", Label: "Prioritising", Description: "You’re giving it priority.", Category: "triage"},
+	{Code: "Thanks – I think this is a duplicate of an existing issue, I’ll cross-link it.", Label: "Duplicate", Description: "Triage as duplicate.", Category: "triage"},
+
+	// --- Moderation / boundaries ---
+	{Code: "Let’s keep the conversation respectful and on-topic, please.", Label: "Respectful", Description: "Gentle moderation reminder.", Category: "moderation"},
+	{Code: "This thread is getting heated – please take a break and come back later.", Label: "Cool down", Description: "Ask people to cool off.", Category: "moderation"},
+	{Code: "Please move this conversation to the appropriate channel.", Label: "Wrong channel", Description: "Redirect to the right channel.", Category: "moderation"},
+	{Code: "I’m going to lock this thread if the tone doesn’t improve.", Label: "Tone warning", Description: "Clear warning for behaviour.", Category: "moderation"},
+
+	// --- Dev / infra / deploy chatter ---
+	{Code: "Deploying to production now – expect a brief disruption.", Label: "Deploying now", Description: "Deploy in progress notice.", Category: "deploy"},
+	{Code: "Deployment finished successfully.", Label: "Deploy OK", Description: "Deployment success message.", Category: "deploy"},
+	{Code: "We’re rolling back this deployment due to issues.", Label: "Rolling back", Description: "Rollback notice.", Category: "deploy"},
+	{Code: "We’re investigating an issue in production – updates soon.", Label: "Prod issue", Description: "Production incident notice.", Category: "deploy"},
+
+	// --- Support / closing loops ---
+	{Code: "I believe this should be fixed now – can you confirm?", Label: "Please confirm", Description: "Ask user to verify fix.", Category: "support"},
+	{Code: "Closing this out for now – feel free to reopen if it happens again.", Label: "Closing", Description: "Gentle closure message.", Category: "support"},
+	{Code: "Thanks for your patience while we sorted this out.", Label: "Thanks for patience", Description: "Thank users after delays.", Category: "support"},
+	{Code: "Thanks again for the report – this really helps us improve.", Label: "Thanks for report", Description: "Reinforce helpfulness.", Category: "support"},
+
+	// --- Generic “nice” utilities ---
+	{Code: "Good morning! 👋", Label: "GM", Description: "Quick morning greeting.", Category: "social"},
+	{Code: "Good night, talk to you all tomorrow.", Label: "GN", Description: "Quick goodnight.", Category: "social"},
+	{Code: "Congratulations, that’s awesome news! 🎉", Label: "Congrats", Description: "Celebrate good news.", Category: "social"},
+	{Code: "Happy birthday! 🎂", Label: "Birthday", Description: "Birthday wish.", Category: "social"},
+
+	// --- Meta / fallback messages ---
+	{Code: "I don’t have enough context yet – can you give me a bit more detail?", Label: "More context?", Description: "Ask for more info, generic.", Category: "meta"},
+	{Code: "I might be slow to respond for a while, but I am reading everything.", Label: "Slow replies", Description: "Set expectation for slower replies.", Category: "meta"},
+	{Code: "I’ve created an internal note/ticket for this, and we’ll track it from there.", Label: "Internal ticket", Description: "Let them know it’s being tracked.", Category: "meta"},
+	{Code: "If anyone else experiences this, please react to this message so we can gauge impact.", Label: "React to gauge", Description: "Ask for reactions to measure impact.", Category: "meta"},
+}
+
+// Key returns the message's ID, falling back to a slug of its label so
+// built-in messages can be referenced without spelling out IDs.
+func (m ChatMsg) Key() string {
+	if m.ID != "" {
+		return m.ID
+	}
+	return slug(m.Label)
+}
+
+// slug lower-cases s and joins its letters and digits with dashes.
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// messageSet is the JSON file format accepted by -messages.
+type messageSet struct {
+	// Pack metadata, see runPack.
+	Name      string        `json:"name,omitempty"`
+	Version   string        `json:"version,omitempty"` // semantic version, e.g. 1.4.0
+	Author    string        `json:"author,omitempty"`
+	Changelog []packChanges `json:"changelog,omitempty"` // newest first
+
+	Title    string    `json:"title,omitempty"`
+	Messages []ChatMsg `json:"messages"`
+
+	// Language is the language code of the messages, and Titles the title
+	// in the languages they are translated into.
+	Language string            `json:"language,omitempty"`
+	Titles   map[string]string `json:"titles,omitempty"`
+
+	// Categories are accent colours by category, as #rrggbb.
+	Categories map[string]string `json:"categories,omitempty"`
+
+	// Transforms are the -transform steps the text of each category's
+	// messages goes through before the sheet's own.
+	Transforms map[string][]string `json:"transforms,omitempty"`
+
+	dir    string // directory of the file, relative icon paths are in it
+	posted bool   // received over HTTP, so may not name image files
+}
+
+// loadMessages reads a message set; an empty path means the built-in
+// Messages.
+func loadMessages(path string) (*messageSet, error) {
+	if path == "" {
+		return &messageSet{Messages: Messages}, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set messageSet
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := set.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	set.dir = filepath.Dir(path)
+	return &set, nil
+}
+
+// validate checks that the set has messages, that their IDs are unique and
+// that any version is a semantic version.
+func (s *messageSet) validate() error {
+	if len(s.Messages) == 0 {
+		return errors.New("no messages")
+	}
+	if s.Version != "" {
+		if _, err := parseSemver(s.Version); err != nil {
+			return err
+		}
+	}
+	seen := map[string]bool{}
+	for _, m := range s.Messages {
+		if seen[m.Key()] {
+			return fmt.Errorf("duplicate message id %q", m.Key())
+		}
+		seen[m.Key()] = true
+	}
+	return nil
+}
+
+// translate returns the set in language lang, and the keys of messages
+// without a translation, which are left as they are. Messages keep their
+// keys and order, so every language's sheet has the same message in the
+// same cell.
+func (s *messageSet) translate(lang string) (*messageSet, []string) {
+	if lang == "" || lang == s.Language {
+		return s, nil
+	}
+	t := *s
+	t.Language = lang
+	if title, ok := s.Titles[lang]; ok {
+		t.Title = title
+	}
+	t.Messages = make([]ChatMsg, len(s.Messages))
+	var missing []string
+	for i, m := range s.Messages {
+		m.ID = m.Key()
+		tr, ok := m.Translations[lang]
+		if !ok {
+			missing = append(missing, m.ID)
+		}
+		if tr.Code != "" || tr.Short != "" || tr.Long != "" {
+			// Untranslated variants would print in the wrong language.
+			m.Short, m.Long = tr.Short, tr.Long
+		}
+		if tr.Code != "" {
+			m.Code = tr.Code
+		}
+		if tr.Label != "" {
+			m.Label = tr.Label
+		}
+		if tr.Description != "" {
+			m.Description = tr.Description
+		}
+		t.Messages[i] = m
+	}
+	return &t, missing
+}
+
+// Payload variants, see messageSet.variant.
+const (
+	variantShort = "short"
+	variantLong  = "long"
+)
+
+// variant returns the set with each message's code replaced by its short
+// or long version, for the same labels sending terser or fuller messages
+// to different audiences. Messages without that version keep their code;
+// an empty name keeps them all.
+func (s *messageSet) variant(name string) (*messageSet, error) {
+	switch name {
+	case "":
+		return s, nil
+	case variantShort, variantLong:
+	default:
+		return nil, fmt.Errorf("unknown -variant %q", name)
+	}
+	v := *s
+	v.Messages = make([]ChatMsg, len(s.Messages))
+	for i, m := range s.Messages {
+		code := m.Short
+		if name == variantLong {
+			code = m.Long
+		}
+		if code != "" {
+			m.Code = code
+		}
+		v.Messages[i] = m
+	}
+	return &v, nil
+}
+
+// find returns the message with the given key.
+func (s *messageSet) find(key string) (ChatMsg, bool) {
+	for _, m := range s.Messages {
+		if m.Key() == key {
+			return m, true
+		}
+	}
+	return ChatMsg{}, false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// renderBuckets are the upper bounds, in seconds, of the render latency
+// histogram.
+var renderBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// serveMetrics counts serve's renders, by endpoint, and serves them in the
+// Prometheus text format.
+type serveMetrics struct {
+	mu           sync.Mutex
+	renders      map[string]*latency
+	errors       map[string]int
+	hits, misses int
+}
+
+// latency is a render latency histogram.
+type latency struct {
+	buckets []int // cumulative counts by renderBuckets
+	count   int
+	sum     float64
+}
+
+func (m *serveMetrics) rendered(endpoint string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.renders == nil {
+		m.renders = map[string]*latency{}
+	}
+	l := m.renders[endpoint]
+	if l == nil {
+		l = &latency{buckets: make([]int, len(renderBuckets))}
+		m.renders[endpoint] = l
+	}
+	for i, le := range renderBuckets {
+		if d.Seconds() <= le {
+			l.buckets[i]++
+		}
+	}
+	l.count++
+	l.sum += d.Seconds()
+}
+
+// fail counts a failed render, or message set build for "build".
+func (m *serveMetrics) fail(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.errors == nil {
+		m.errors = map[string]int{}
+	}
+	m.errors[endpoint]++
+}
+
+// cached counts a cache lookup.
+func (m *serveMetrics) cached(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hit {
+		m.hits++
+	} else {
+		m.misses++
+	}
+}
+
+func (m *serveMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintln(w, "# HELP chat_barcodes_renders_total Sheets, cells and PDFs rendered, by endpoint.")
+	fmt.Fprintln(w, "# TYPE chat_barcodes_renders_total counter")
+	for _, e := range sortedKeys(m.renders) {
+		fmt.Fprintf(w, "chat_barcodes_renders_total{endpoint=%q} %d\n", e, m.renders[e].count)
+	}
+	fmt.Fprintln(w, "# HELP chat_barcodes_render_duration_seconds Time taken to render, by endpoint.")
+	fmt.Fprintln(w, "# TYPE chat_barcodes_render_duration_seconds histogram")
+	for _, e := range sortedKeys(m.renders) {
+		l := m.renders[e]
+		for i, le := range renderBuckets {
+			fmt.Fprintf(w, "chat_barcodes_render_duration_seconds_bucket{endpoint=%q,le=\"%g\"} %d\n", e, le, l.buckets[i])
+		}
+		fmt.Fprintf(w, "chat_barcodes_render_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", e, l.count)
+		fmt.Fprintf(w, "chat_barcodes_render_duration_seconds_sum{endpoint=%q} %g\n", e, l.sum)
+		fmt.Fprintf(w, "chat_barcodes_render_duration_seconds_count{endpoint=%q} %d\n", e, l.count)
+	}
+	fmt.Fprintln(w, "# HELP chat_barcodes_errors_total Failed renders, and message sets that failed to load (endpoint \"build\").")
+	fmt.Fprintln(w, "# TYPE chat_barcodes_errors_total counter")
+	for _, e := range sortedKeys(m.errors) {
+		fmt.Fprintf(w, "chat_barcodes_errors_total{endpoint=%q} %d\n", e, m.errors[e])
+	}
+	fmt.Fprintln(w, "# HELP chat_barcodes_cache_requests_total Rendered response cache lookups, by result.")
+	fmt.Fprintln(w, "# TYPE chat_barcodes_cache_requests_total counter")
+	fmt.Fprintf(w, "chat_barcodes_cache_requests_total{result=\"hit\"} %d\n", m.hits)
+	fmt.Fprintf(w, "chat_barcodes_cache_requests_total{result=\"miss\"} %d\n", m.misses)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
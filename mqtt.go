@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"time"
+)
+
+// publisher publishes every scan to an MQTT topic as JSON, for dashboards
+// and automations to follow alongside the chat:
+//
+//	{"time": "2025-01-02T15:04:05Z", "scan": "042", "payload": "Deploy OK", "id": "deployed", "label": "Deployed"}
+//
+// Scans are published in turn while scanning carries on, at most once
+// (QoS 0); the broker is reconnected to after errors, and scans queued up
+// while it is down are dropped.
+type publisher struct {
+	broker *url.URL
+	topic  string
+	labels scanLabels
+	queue  chan []byte
+}
+
+// mqttKeepAlive is how often the broker hears from an idle publisher.
+const mqttKeepAlive = 60 * time.Second
+
+// mqttFlags registers the -mqtt flags of a command reading scans, and
+// returns a function making its publisher from the sheet's manifest, or
+// nil without -mqtt.
+func mqttFlags(fs *flag.FlagSet) func(m *manifest) (*publisher, error) {
+	broker := fs.String("mqtt", "", "MQTT broker to also publish every scan to, as mqtt://[user:password@]host[:port] or mqtts:// for TLS")
+	topic := fs.String("mqtt-topic", "chat-barcodes/scans", "with -mqtt, the topic scans are published to")
+	return func(m *manifest) (*publisher, error) {
+		if *broker == "" {
+			return nil, nil
+		}
+		u, err := url.Parse(*broker)
+		if err != nil || (u.Scheme != "mqtt" && u.Scheme != "mqtts") || u.Host == "" {
+			return nil, fmt.Errorf("-mqtt %q is not an mqtt:// or mqtts:// URL", *broker)
+		}
+		p := &publisher{broker: u, topic: *topic, labels: newScanLabels(m), queue: make(chan []byte, 16)}
+		go p.run()
+		return p, nil
+	}
+}
+
+// relabel looks labels up in m from now on.
+func (p *publisher) relabel(m *manifest) {
+	if p == nil {
+		return
+	}
+	p.labels = newScanLabels(m)
+}
+
+// publish queues a scan sending msg to be published. It does nothing on a
+// nil publisher, so commands can call it whether or not -mqtt is on.
+func (p *publisher) publish(scan, msg string) {
+	if p == nil {
+		return
+	}
+	b, _ := json.Marshal(newScanEvent(p.labels, scan, msg))
+	select {
+	case p.queue <- b:
+	default:
+		log.Printf("mqtt: dropping a scan, %s isn't keeping up", p.broker.Host)
+	}
+}
+
+func (p *publisher) run() {
+	var conn *mqttConn
+	ping := time.NewTicker(mqttKeepAlive / 2)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case b := <-p.queue:
+			if conn == nil {
+				if conn, err = dialMQTT(p.broker); err != nil {
+					log.Printf("mqtt: %v", err)
+					p.drain()
+					continue
+				}
+			}
+			err = conn.publish(p.topic, b)
+		case <-ping.C:
+			if conn != nil {
+				err = conn.write(0xc0, nil) // PINGREQ
+			}
+		case <-connDone(conn):
+			err = errors.New("disconnected by the broker")
+		}
+		if err != nil {
+			log.Printf("mqtt: %s: %v", p.broker.Host, err)
+			conn.Close()
+			conn = nil
+		}
+	}
+}
+
+// drain drops the scans queued while the broker is unreachable, rather
+// than publish them late.
+func (p *publisher) drain() {
+	for {
+		select {
+		case <-p.queue:
+		default:
+			return
+		}
+	}
+}
+
+// mqttConn is a connection to an MQTT 3.1.1 broker, just enough of it to
+// publish at QoS 0.
+type mqttConn struct {
+	net.Conn
+	done chan struct{} // closed when the broker closes the connection
+}
+
+// connDone returns the done channel of c, nil (never ready) if c is.
+func connDone(c *mqttConn) <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.done
+}
+
+// dialMQTT connects to the broker at u, logging in with its user info.
+func dialMQTT(u *url.URL) (*mqttConn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[string]string{"mqtt": "1883", "mqtts": "8883"}[u.Scheme])
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if u.Scheme == "mqtts" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, nil)
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 6)
+	rand.Read(id)
+	flags := byte(0x02) // clean session
+	payload := mqttString(nil, "chat-barcodes-"+hex.EncodeToString(id))
+	if user := u.User; user != nil {
+		flags |= 0x80
+		payload = mqttString(payload, user.Username())
+		if password, ok := user.Password(); ok {
+			flags |= 0x40
+			payload = mqttString(payload, password)
+		}
+	}
+	header := mqttString(nil, "MQTT")
+	header = append(header, 4, flags, byte(mqttKeepAlive/time.Second>>8), byte(mqttKeepAlive/time.Second))
+	c := &mqttConn{conn, make(chan struct{})}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := c.write(0x10, append(header, payload...)); err != nil { // CONNECT
+		conn.Close()
+		return nil, err
+	}
+	var connack [4]byte
+	if _, err := io.ReadFull(conn, connack[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if connack[0] != 0x20 || connack[3] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("%s refused the connection (code %d), check the user and password", u.Host, connack[3])
+	}
+	conn.SetDeadline(time.Time{})
+	// Nothing read after CONNACK matters (PINGRESPs), only the broker
+	// going away.
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(c.done)
+	}()
+	return c, nil
+}
+
+func (c *mqttConn) publish(topic string, payload []byte) error {
+	return c.write(0x30, append(mqttString(nil, topic), payload...)) // PUBLISH, QoS 0
+}
+
+// write writes a packet of type and flags kind with the given body.
+func (c *mqttConn) write(kind byte, body []byte) error {
+	packet := []byte{kind}
+	// The remaining length is a base-128 varint.
+	for n := len(body); ; {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	c.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.Write(append(packet, body...))
+	return err
+}
+
+func (c *mqttConn) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.Conn.Close()
+}
+
+// mqttString appends s to b as an MQTT string: its length, then its bytes.
+func mqttString(b []byte, s string) []byte {
+	return append(append(b, byte(len(s)>>8), byte(len(s))), s...)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// NDEF type name formats, see the NFC Forum NDEF specification.
+const (
+	tnfWellKnown = 0x01
+	tnfMedia     = 0x02
+)
+
+// uriPrefixes are the abbreviations of URI records, by code.
+var uriPrefixes = []string{
+	"", "http://www.", "https://www.", "http://", "https://", "tel:", "mailto:",
+	"ftp://anonymous:anonymous@", "ftp://ftp.", "ftps://", "sftp://", "smb://",
+	"nfs://", "ftp://", "dav://", "news:", "telnet://", "imap:", "rtsp://",
+	"urn:", "pop:", "sip:", "sips:", "tftp:", "btspp://", "btl2cap://",
+	"btgoep://", "tcpobex://", "irdaobex://", "file://", "urn:epc:id:",
+	"urn:epc:tag:", "urn:epc:pat:", "urn:epc:raw:", "urn:epc:", "urn:nfc:",
+}
+
+// uriPayload matches payloads written as URI records rather than text:
+// a scheme and no spaces.
+var uriPayload = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:\S+$`)
+
+// ndefMessage returns the NDEF message of one record that taps as payload
+// does when scanned: a URI record for links, a vCard or calendar media
+// record, or otherwise a text record in lang.
+func ndefMessage(payload, lang string) []byte {
+	switch {
+	case strings.HasPrefix(payload, "BEGIN:VCARD"):
+		return ndefRecord(tnfMedia, "text/vcard", []byte(payload))
+	case strings.HasPrefix(payload, "BEGIN:VCALENDAR"):
+		return ndefRecord(tnfMedia, "text/calendar", []byte(payload))
+	case uriPayload.MatchString(payload):
+		code := 0
+		for i, p := range uriPrefixes {
+			if strings.HasPrefix(payload, p) && len(p) > len(uriPrefixes[code]) {
+				code = i
+			}
+		}
+		return ndefRecord(tnfWellKnown, "U", append([]byte{byte(code)}, payload[len(uriPrefixes[code]):]...))
+	}
+	if lang == "" {
+		lang = "en"
+	}
+	// UTF-8, with the length of the language code.
+	text := append([]byte{byte(len(lang))}, lang...)
+	return ndefRecord(tnfWellKnown, "T", append(text, payload...))
+}
+
+// ndefRecord encodes a message of a single record.
+func ndefRecord(tnf byte, typ string, payload []byte) []byte {
+	header := byte(0x80 | 0x40 | tnf) // message begin and end
+	b := []byte{header, byte(len(typ))}
+	if len(payload) < 256 {
+		b[0] |= 0x10 // short record
+		b = append(b, byte(len(payload)))
+	} else {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	}
+	b = append(b, typ...)
+	return append(b, payload...)
+}
+
+// writeNDEF writes an NDEF message file per cell of l into dir, named by
+// message ID, and returns their paths.
+func writeNDEF(dir string, l *layout) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, c := range l.manifest.Cells {
+		if strings.ContainsAny(c.ID, `/\`) {
+			return paths, fmt.Errorf("message id %q can't name an NDEF file", c.ID)
+		}
+		path := filepath.Join(dir, c.ID+".ndef")
+		if err := os.WriteFile(path, ndefMessage(c.Payload, l.set.Language), 0o644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writeTags runs the writer command template for each NDEF file in turn,
+// with {file} and {id} replaced, waiting for Enter on in before each so a
+// blank tag can be put on the writer, e.g.
+//
+//	nfc-ndef-write {file}
+func writeTags(writer string, paths []string, in io.Reader, out io.Writer) error {
+	r := bufio.NewReader(in)
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".ndef")
+		fmt.Fprintf(out, "Put a tag for %s on the writer and press Enter: ", id)
+		if _, err := r.ReadString('\n'); err != nil {
+			return err
+		}
+		args := strings.Fields(writer)
+		for i := range args {
+			args[i] = strings.NewReplacer("{file}", path, "{id}", id).Replace(args[i])
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout, cmd.Stderr = out, out
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("writing %s: %w", id, err)
+		}
+		fmt.Fprintln(out, "Written:", id)
+	}
+	return nil
+}
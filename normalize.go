@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalForms are the Unicode normalization forms -normalize accepts.
+var normalForms = map[string]norm.Form{
+	"nfc":  norm.NFC,
+	"nfd":  norm.NFD,
+	"nfkc": norm.NFKC,
+	"nfkd": norm.NFKD,
+}
+
+func normalFormNames() string {
+	names := make([]string, 0, len(normalForms))
+	for name := range normalForms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+// normalizeMessages returns msgs with their text in the normalization form
+// named, so text pasted in decomposed from elsewhere encodes the same bytes
+// as text typed in, and a line per message that changed.
+func normalizeMessages(msgs []ChatMsg, name string) ([]ChatMsg, []string, error) {
+	if name == "" {
+		return msgs, nil, nil
+	}
+	form, ok := normalForms[strings.ToLower(name)]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown -normalize %q, want %s", name, normalFormNames())
+	}
+	var changed []string
+	out := make([]ChatMsg, len(msgs))
+	for i, m := range msgs {
+		if text := form.String(m.Code); text != m.Code {
+			changed = append(changed, fmt.Sprintf("%s (%d bytes, was %d)", m.Key(), len(text), len(m.Code)))
+			m.Code = text
+		}
+		out[i] = m
+	}
+	return out, changed, nil
+}
+
+// asciiPunctuation replaces typographic punctuation with the plain ASCII
+// scanners type on any keyboard layout.
+var asciiPunctuation = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "―", "-",
+	"…", "...",
+	" ", " ", " ", " ",
+)
+
+// foldPunctuation returns msgs with the smart quotes, dashes and ellipses
+// of their text made ASCII, leaving their labels as they are.
+func foldPunctuation(msgs []ChatMsg) []ChatMsg {
+	out := make([]ChatMsg, len(msgs))
+	for i, m := range msgs {
+		m.Code = asciiPunctuation.Replace(m.Code)
+		out[i] = m
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// messageNumbers returns -numbers' codes by message key: each message's
+// place in msgs from 1, zero padded to at least three digits, so a message
+// keeps its number on shuffled sheets and bingo cards.
+func messageNumbers(msgs []ChatMsg) map[string]string {
+	width := max(3, len(strconv.Itoa(len(msgs))))
+	numbers := make(map[string]string, len(msgs))
+	for i, m := range msgs {
+		numbers[m.Key()] = fmt.Sprintf("%0*d", width, i+1)
+	}
+	return numbers
+}
+
+// writeNumbersTable writes the numbered cells of l to path as CSV of their
+// numbers, labels and messages, in number order, for looking up a code
+// someone without a scanner reads out.
+func writeNumbersTable(path string, l *layout) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"number", "label", "message"})
+	// Numbers are all the same width, so sort as strings.
+	cells := l.manifest.Cells
+	order := make([]int, len(cells))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return cells[order[i]].Number < cells[order[j]].Number })
+	seen := map[string]bool{}
+	for _, i := range order {
+		c := cells[i]
+		if c.Number == "" || seen[c.Number] {
+			continue
+		}
+		seen[c.Number] = true
+		w.Write([]string{c.Number, c.Label, l.texts[i]})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// scanPayloads returns the payloads the manifest's short codes stand for,
+// see expandNumber: by number, with leading zeros dropped, and on -indirect
+// sheets by message ID, their messages.
+func (m *manifest) scanPayloads() map[string]string {
+	payloads := map[string]string{}
+	for _, c := range m.Cells {
+		payload := c.Payload
+		if m.Indirect {
+			payload = c.Original
+			payloads[c.ID] = payload
+		}
+		if c.Number != "" {
+			payloads[trimNumber(c.Number)] = payload
+		}
+	}
+	return payloads
+}
+
+// expandNumber returns the message msg stands for in payloads, so scanning
+// or typing "042" or "42" sends message 42, and msg itself if it isn't a
+// number or ID there.
+func expandNumber(payloads map[string]string, msg string) string {
+	msg = strings.TrimSpace(msg)
+	if p, ok := payloads[msg]; ok {
+		return p
+	}
+	if p, ok := payloads[trimNumber(msg)]; ok {
+		return p
+	}
+	return msg
+}
+
+func trimNumber(s string) string {
+	if t := strings.TrimLeft(s, "0"); t != "" {
+		return t
+	}
+	return s
+}
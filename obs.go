@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// obsClient sends requests to OBS Studio over obs-websocket (protocol 5),
+// connecting for each one: scans are seconds apart and OBS may be
+// restarted in between.
+type obsClient struct {
+	URL      string // e.g. ws://localhost:4455
+	Password string
+}
+
+// obsMessage is an obs-websocket message: an opcode and its data.
+type obsMessage struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+}
+
+// The obs-websocket opcodes used.
+const (
+	obsHello           = 0
+	obsIdentify        = 1
+	obsIdentified      = 2
+	obsRequest         = 6
+	obsRequestResponse = 7
+)
+
+// Request sends OBS a request such as StartStream or ToggleInputMute, with
+// data its fields, and waits for it to succeed.
+func (c obsClient) Request(requestType string, data map[string]any) error {
+	ws, err := dialWebsocket(c.URL)
+	if err != nil {
+		return fmt.Errorf("obs: %w", err)
+	}
+	defer ws.Close()
+	ws.conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var hello struct {
+		Authentication *struct{ Challenge, Salt string }
+	}
+	if err := ws.receive(obsHello, &hello); err != nil {
+		return err
+	}
+	identify := map[string]any{"rpcVersion": 1}
+	if a := hello.Authentication; a != nil {
+		if c.Password == "" {
+			return errors.New("obs: the websocket server needs a password")
+		}
+		// base64(sha256(base64(sha256(password + salt)) + challenge))
+		secret := sha256.Sum256([]byte(c.Password + a.Salt))
+		auth := sha256.Sum256([]byte(base64.StdEncoding.EncodeToString(secret[:]) + a.Challenge))
+		identify["authentication"] = base64.StdEncoding.EncodeToString(auth[:])
+	}
+	if err := ws.send(obsIdentify, identify); err != nil {
+		return err
+	}
+	if err := ws.receive(obsIdentified, nil); err != nil {
+		return err
+	}
+	if err := ws.send(obsRequest, map[string]any{"requestType": requestType, "requestId": "1", "requestData": data}); err != nil {
+		return err
+	}
+	var resp struct {
+		RequestStatus struct {
+			Result  bool
+			Code    int
+			Comment string
+		}
+	}
+	if err := ws.receive(obsRequestResponse, &resp); err != nil {
+		return err
+	}
+	if s := resp.RequestStatus; !s.Result {
+		return fmt.Errorf("obs: %s: error %d %s", requestType, s.Code, s.Comment)
+	}
+	return nil
+}
+
+func (ws *websocket) send(op int, d any) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	b, err = json.Marshal(obsMessage{op, b})
+	if err != nil {
+		return err
+	}
+	return ws.writeText(b)
+}
+
+// receive reads the next message, which must have opcode op, into d.
+func (ws *websocket) receive(op int, d any) error {
+	b, err := ws.readText()
+	if err != nil {
+		return fmt.Errorf("obs: %w", err)
+	}
+	var msg obsMessage
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return fmt.Errorf("obs: %w", err)
+	}
+	if msg.Op != op {
+		return fmt.Errorf("obs: got opcode %d, want %d (wrong password?)", msg.Op, op)
+	}
+	if d == nil {
+		return nil
+	}
+	return json.Unmarshal(msg.D, d)
+}
+
+// websocket is the client end of a WebSocket connection (RFC 6455), just
+// enough of it for obs-websocket: unfragmented text messages.
+type websocket struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// websocketGUID is appended to the handshake key, see RFC 6455 section 4.2.2.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// dialWebsocket opens a ws:// or wss:// URL.
+func dialWebsocket(rawURL string) (*websocket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[string]string{"ws": "80", "wss": "443"}[u.Scheme])
+	}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = net.DialTimeout("tcp", host, 10*time.Second)
+	case "wss":
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", host, nil)
+	default:
+		return nil, fmt.Errorf("%s is not a ws:// or wss:// URL", rawURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{
+			"Upgrade":                {"websocket"},
+			"Connection":             {"Upgrade"},
+			"Sec-WebSocket-Key":      {key},
+			"Sec-WebSocket-Version":  {"13"},
+			"Sec-WebSocket-Protocol": {"obswebsocket.json"},
+		},
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		conn.Close()
+		return nil, fmt.Errorf("%s: not a websocket server: %s", rawURL, resp.Status)
+	}
+	return &websocket{conn, r}, nil
+}
+
+// The WebSocket frame opcodes used.
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+func (ws *websocket) writeText(b []byte) error {
+	return ws.writeFrame(wsText, b)
+}
+
+// writeFrame writes a final frame, masked as clients must.
+func (ws *websocket) writeFrame(opcode byte, b []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(b); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	frame = append(frame, mask...)
+	for i, c := range b {
+		frame = append(frame, c^mask[i%4])
+	}
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// readText returns the next text message, answering pings on the way.
+func (ws *websocket) readText() ([]byte, error) {
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(ws.r, head[:]); err != nil {
+			return nil, err
+		}
+		if head[0]&0x80 == 0 {
+			return nil, errors.New("fragmented websocket messages aren't supported")
+		}
+		n := uint64(head[1] & 0x7f)
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+				return nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+				return nil, err
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		if n > 1<<20 {
+			return nil, fmt.Errorf("websocket message of %d bytes is too big", n)
+		}
+		var mask [4]byte
+		if head[1]&0x80 != 0 {
+			if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+				return nil, err
+			}
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(ws.r, b); err != nil {
+			return nil, err
+		}
+		for i := range b {
+			b[i] ^= mask[i%4]
+		}
+		switch head[0] & 0x0f {
+		case wsText:
+			return b, nil
+		case wsPing:
+			if err := ws.writeFrame(wsPong, b); err != nil {
+				return nil, err
+			}
+		case wsClose:
+			return nil, errors.New("websocket closed by the server")
+		}
+	}
+}
+
+func (ws *websocket) Close() error {
+	ws.writeFrame(wsClose, nil)
+	return ws.conn.Close()
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// packChanges is a changelog entry of a message pack.
+type packChanges struct {
+	Version string   `json:"version"`
+	Date    string   `json:"date,omitempty"` // YYYY-MM-DD
+	Changes []string `json:"changes,omitempty"`
+}
+
+// runPack manages message pack versions: message files with a name,
+// version, author and changelog, whose version is printed on their sheets
+// so a printout can be traced to its revision.
+//
+//	chat-barcodes pack bump [-part major|minor|patch] [-note text] team.json
+//	chat-barcodes pack compare old.json new.json
+//
+// and shares them as .chatpack bundles, see chatpack.go:
+//
+//	chat-barcodes pack export -o support.chatpack -messages team.json [sheet flags]
+//	chat-barcodes pack import support.chatpack [dir]
+func runPack(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "bump":
+			return runPackBump(args[1:])
+		case "compare":
+			return runPackCompare(args[1:])
+		case "export":
+			return runPackExport(args[1:])
+		case "import":
+			return runPackImport(args[1:])
+		}
+	}
+	return errors.New("usage: pack bump|compare|export|import ...")
+}
+
+// runPackBump increases the version of a message file and records the
+// change in its changelog.
+func runPackBump(args []string) error {
+	fs := flag.NewFlagSet("pack bump", flag.ExitOnError)
+	part := fs.String("part", "patch", "version part to increase: major|minor|patch")
+	var notes stringsFlag
+	fs.Var(&notes, "note", "changelog line, may be repeated")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: pack bump [-part major|minor|patch] [-note text] messages.json")
+	}
+	path := fs.Arg(0)
+	set, err := loadMessages(path)
+	if err != nil {
+		return err
+	}
+	var v semver
+	if set.Version != "" {
+		if v, err = parseSemver(set.Version); err != nil {
+			return err
+		}
+	}
+	if v, err = v.bump(*part); err != nil {
+		return err
+	}
+	old := set.Version
+	set.Version = v.String()
+	set.Changelog = append([]packChanges{{Version: set.Version, Date: time.Now().Format(time.DateOnly), Changes: notes}}, set.Changelog...)
+	b, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	if old == "" {
+		old = "unversioned"
+	}
+	fmt.Printf("%s: %s → %s\n", path, old, set.Version)
+	return nil
+}
+
+// runPackCompare lists the messages added, removed and changed between two
+// revisions of a pack, and fails if messages changed without the version
+// increasing.
+func runPackCompare(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: pack compare old.json new.json")
+	}
+	a, err := loadMessages(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := loadMessages(args[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s → %s %s\n", packName(a), packVersion(a), packName(b), packVersion(b))
+	old := map[string]ChatMsg{}
+	for _, m := range a.Messages {
+		old[m.Key()] = m
+	}
+	changed := false
+	for _, m := range b.Messages {
+		o, ok := old[m.Key()]
+		delete(old, m.Key())
+		switch {
+		case !ok:
+			fmt.Printf("  added:   %s %q\n", m.Key(), m.Label)
+		case !sameMessage(o, m):
+			fmt.Printf("  changed: %s %q\n", m.Key(), m.Label)
+		default:
+			continue
+		}
+		changed = true
+	}
+	for _, m := range a.Messages {
+		if _, ok := old[m.Key()]; ok {
+			fmt.Printf("  removed: %s %q\n", m.Key(), m.Label)
+			changed = true
+		}
+	}
+	changed = changed || a.Title != b.Title
+
+	va, _ := parseSemver(packVersion(a))
+	vb, _ := parseSemver(packVersion(b))
+	for _, c := range b.Changelog {
+		if v, err := parseSemver(c.Version); err == nil && v.compare(va) > 0 {
+			fmt.Printf("  %s %s: %s\n", c.Version, c.Date, strings.Join(c.Changes, "; "))
+		}
+	}
+	if !changed {
+		fmt.Println("  no changes")
+		return nil
+	}
+	if vb.compare(va) <= 0 {
+		return fmt.Errorf("messages changed but version %s is not newer than %s; run pack bump", packVersion(b), packVersion(a))
+	}
+	return nil
+}
+
+func sameMessage(a, b ChatMsg) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+func packName(s *messageSet) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.Title != "" {
+		return s.Title
+	}
+	return "messages"
+}
+
+func packVersion(s *messageSet) string {
+	if s.Version == "" {
+		return "0.0.0"
+	}
+	return s.Version
+}
+
+// revision names the pack revision a sheet is printed from, or is empty for
+// unversioned message sets.
+func (s *messageSet) revision() string {
+	if s.Version == "" {
+		return ""
+	}
+	if s.Name == "" {
+		return "v" + s.Version
+	}
+	return s.Name + " v" + s.Version
+}
+
+// semver is a semantic version, major.minor.patch with an optional
+// pre-release suffix.
+type semver struct {
+	major, minor, patch int
+	pre                 string
+}
+
+func parseSemver(s string) (semver, error) {
+	var v semver
+	core, pre, _ := strings.Cut(strings.TrimPrefix(s, "v"), "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("version %q is not major.minor.patch", s)
+	}
+	for i, dst := range []*int{&v.major, &v.minor, &v.patch} {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("version %q is not major.minor.patch", s)
+		}
+		*dst = n
+	}
+	v.pre = pre
+	return v, nil
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.pre != "" {
+		s += "-" + v.pre
+	}
+	return s
+}
+
+// bump returns the next version, increasing part and dropping any
+// pre-release.
+func (v semver) bump(part string) (semver, error) {
+	switch part {
+	case "major":
+		return semver{major: v.major + 1}, nil
+	case "minor":
+		return semver{major: v.major, minor: v.minor + 1}, nil
+	case "patch":
+		return semver{major: v.major, minor: v.minor, patch: v.patch + 1}, nil
+	}
+	return v, fmt.Errorf("unknown version part %q, want major, minor or patch", part)
+}
+
+// compare returns -1, 0 or 1 as v is older than, the same as or newer than
+// w. Pre-releases are older than their release.
+func (v semver) compare(w semver) int {
+	for _, d := range [...]int{v.major - w.major, v.minor - w.minor, v.patch - w.patch} {
+		if d != 0 {
+			if d < 0 {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.pre == w.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case w.pre == "":
+		return -1
+	}
+	return strings.Compare(v.pre, w.pre)
+}
+
+// stringsFlag is a repeatable string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ", ") }
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
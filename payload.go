@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// payloadMode turns a message into the data encoded in its QR code. opts
+// carries mode specific settings given with -payload-opt key=value.
+type payloadMode func(msg ChatMsg, opts map[string]string) (string, error)
+
+var payloadModes = map[string]payloadMode{
+	"text":          textPayload,
+	"slack":         slackPayload,
+	"trigger":       triggerPayload,
+	"discord":       discordPayload,
+	"teams":         teamsPayload,
+	"googlechat":    googleChatPayload,
+	"zoom":          zoomPayload,
+	"matrix":        matrixPayload,
+	"irc":           ircPayload,
+	"mattermost":    mattermostPayload,
+	"rocketchat":    rocketChatPayload,
+	"mailto":        mailtoPayload,
+	"sms":           smsPayload,
+	"tel":           telPayload,
+	"whatsapp":      whatsappPayload,
+	"telegram":      telegramPayload,
+	"comment":       commentPayload,
+	"action":        actionPayload,
+	"homeassistant": homeAssistantPayload,
+	"token":         tokenPayload,
+	"wifi":          wifiPayload,
+	"vcard":         vcardPayload,
+	"event":         eventPayload,
+	"geo":           geoPayload,
+	"meeting":       meetingPayload,
+}
+
+// extraPayloads produce the small secondary code some modes print in the
+// corner of the cell, and its caption. An empty payload means no extra code.
+var extraPayloads = map[string]struct {
+	Caption string
+	Mode    payloadMode
+}{
+	"meeting": {"Dial in", meetingDialIn},
+}
+
+// mode returns the payload mode and settings for m: its own Type and Fields
+// if set, mode and opts otherwise.
+func (m ChatMsg) mode(mode string, opts map[string]string) (string, map[string]string) {
+	if m.Type == "" {
+		return mode, opts
+	}
+	merged := maps.Clone(opts)
+	maps.Copy(merged, m.Fields)
+	return m.Type, merged
+}
+
+// extraPayload returns the secondary code for m and its caption, if its
+// payload mode has one.
+func (m ChatMsg) extraPayload(mode string, opts map[string]string) (payload, caption string, err error) {
+	mode, opts = m.mode(mode, opts)
+	extra, ok := extraPayloads[mode]
+	if !ok {
+		return "", "", nil
+	}
+	payload, err = extra.Mode(m, opts)
+	return payload, extra.Caption, err
+}
+
+// payload returns the data encoded in m's QR code, using its own Type and
+// Fields if set and mode/opts otherwise. Plain text payloads are validated
+// and escaped for target.
+func (m ChatMsg) payload(mode string, opts map[string]string, target chatTarget) (string, error) {
+	mode, opts = m.mode(mode, opts)
+	fn, err := lookupPayloadMode(mode)
+	if err != nil {
+		return "", err
+	}
+	if mode == "text" {
+		if target.Validate != nil {
+			if err := target.Validate(m.Code); err != nil {
+				return "", err
+			}
+		}
+		m.Code = target.Escape(m.Code)
+	}
+	return fn(m, opts)
+}
+
+// textPayload encodes the message itself, for scanners typing into a chat
+// window.
+func textPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	return msg.Code, nil
+}
+
+// slackPayload encodes a slack:// deep link that opens the channel given by
+// the team and channel options in the Slack app. Slack deep links can't
+// carry message text, so pair these with a trigger code when the message
+// should actually be sent.
+func slackPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	team, channel := opts["team"], opts["channel"]
+	if team == "" || channel == "" {
+		return "", errors.New("slack payloads need -payload-opt team=T… and channel=C…")
+	}
+	q := url.Values{"team": {team}, "id": {channel}}
+	return "slack://channel?" + q.Encode(), nil
+}
+
+// triggerPayload encodes a URL on a bridge started with -listen. Opening it
+// (e.g. with a phone camera) makes the bridge post the message to its
+// webhook. The signature stops anyone from posting arbitrary text through
+// the bridge by editing the URL.
+func triggerPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	base, secret := opts["base"], opts["secret"]
+	if base == "" || secret == "" {
+		return "", errors.New("trigger payloads need -payload-opt base=https://bridge/trigger and secret=…")
+	}
+	q := url.Values{"text": {msg.Code}, "sig": {signText(secret, msg.Code)}}
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + q.Encode(), nil
+}
+
+// discordPayload encodes a bridge code posting the message to the Discord
+// channel named by the channel option. By default the message is referenced
+// by ID, which keeps the code small and lets the wording change after
+// printing as long as the bridge runs with the same -messages; by=text
+// embeds the message instead.
+func discordPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	channel := opts["channel"]
+	if channel == "" {
+		return "", errors.New("discord payloads need -payload-opt channel=<name configured on the bridge>")
+	}
+	switch opts["by"] {
+	case "", "id":
+		return bridgeCode("discord", []string{channel, msg.Key()}, nil), nil
+	case "text":
+		return bridgeCode("discord", []string{channel}, url.Values{"text": {msg.Code}}), nil
+	}
+	return "", fmt.Errorf("discord payloads reference messages by=id or by=text, not %q", opts["by"])
+}
+
+// teamsPayload encodes a Teams chat deep link with the message pre-filled.
+// The users option is a comma separated list of UPNs (emails) to chat with;
+// topic names a group chat. app=true uses the msteams: scheme, skipping the
+// browser hop on phones with the Teams app installed.
+func teamsPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	users := opts["users"]
+	if users == "" {
+		return "", errors.New("teams payloads need -payload-opt users=someone@example.com[,…]")
+	}
+	q := url.Values{"users": {users}, "message": {msg.Code}}
+	if topic := opts["topic"]; topic != "" {
+		q.Set("topicName", topic)
+	}
+	base := "https://teams.microsoft.com/l/chat/0/0"
+	if opts["app"] == "true" {
+		base = "msteams:/l/chat/0/0"
+	}
+	return base + "?" + q.Encode(), nil
+}
+
+// matrixPayload encodes a bridge code posting the message to the room
+// option, a room name configured on the bridge with -matrix-room. With
+// by=link it encodes a matrix.to link to the room (an alias or ID) instead,
+// which opens it in Element but, like Slack, can't pre-fill the message.
+func matrixPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	room := opts["room"]
+	if room == "" {
+		return "", errors.New("matrix payloads need -payload-opt room=…")
+	}
+	switch opts["by"] {
+	case "", "id":
+		return bridgeCode("matrix", []string{room, msg.Key()}, nil), nil
+	case "text":
+		return bridgeCode("matrix", []string{room}, url.Values{"text": {msg.Code}}), nil
+	case "link":
+		return "https://matrix.to/#/" + url.PathEscape(room), nil
+	}
+	return "", fmt.Errorf("matrix payloads are by=id, by=text or by=link, not %q", opts["by"])
+}
+
+// mailtoPayload encodes a mailto: URI composing an email to the to option.
+// The subject and body options are templates (see expandFields) defaulting
+// to the label and the message text.
+func mailtoPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	subject, body := opts["subject"], opts["body"]
+	if subject == "" {
+		subject = "{label}"
+	}
+	if body == "" {
+		body = "{text}"
+	}
+	fields := msg.templateFields()
+	var q []string
+	for _, p := range []struct{ k, v string }{
+		{"subject", expandFields(subject, fields)},
+		{"body", expandFields(body, fields)},
+		{"cc", opts["cc"]},
+	} {
+		if p.v != "" {
+			q = append(q, p.k+"="+uriEscape(p.v))
+		}
+	}
+	var to []string
+	for _, addr := range strings.Split(opts["to"], ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, mailtoAddrEscaper.Replace(url.PathEscape(addr)))
+		}
+	}
+	return "mailto:" + strings.Join(to, ",") + "?" + strings.Join(q, "&"), nil
+}
+
+// mailtoAddrEscaper encodes what url.PathEscape leaves of an address but RFC
+// 6068 doesn't allow before the query.
+var mailtoAddrEscaper = strings.NewReplacer("&", "%26", "=", "%3D")
+
+// uriEscape percent-encodes s for a URI query, using %20 for spaces as RFC
+// 6068 wants; many mail clients show "+" literally.
+func uriEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func lookupPayloadMode(name string) (payloadMode, error) {
+	mode, ok := payloadModes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown payload mode %q (want one of %s)", name, payloadModeNames())
+	}
+	return mode, nil
+}
+
+func payloadModeNames() string {
+	names := make([]string, 0, len(payloadModes))
+	for name := range payloadModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+// keyValueFlag collects repeated -flag key=value arguments.
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("%q is not key=value", s)
+	}
+	f[k] = v
+	return nil
+}
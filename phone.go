@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// phoneNumber strips the visual separators people write numbers with and
+// checks what's left is a dialable global (+…) or local number.
+func phoneNumber(s string) (string, error) {
+	n := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -.()", r) {
+			return -1
+		}
+		return r
+	}, s)
+	if n == "" || n == "+" {
+		return "", errors.New("phone payloads need -payload-opt number=…")
+	}
+	for i, r := range n {
+		if (r < '0' || r > '9') && r != '*' && r != '#' && (r != '+' || i != 0) {
+			return "", fmt.Errorf("%q is not a phone number", s)
+		}
+	}
+	return n, nil
+}
+
+// smsPayload encodes an RFC 5724 sms: URI texting number; the body option
+// is a template defaulting to the message text.
+func smsPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	number, err := phoneNumber(opts["number"])
+	if err != nil {
+		return "", err
+	}
+	body := opts["body"]
+	if body == "" {
+		body = "{text}"
+	}
+	return "sms:" + number + "?body=" + uriEscape(expandFields(body, msg.templateFields())), nil
+}
+
+// telPayload encodes a tel: URI calling number.
+func telPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	number, err := phoneNumber(opts["number"])
+	if err != nil {
+		return "", err
+	}
+	return "tel:" + number, nil
+}
+
+// whatsappPayload encodes a wa.me click-to-chat link with the message
+// pre-filled. number is optional (the sender then picks a chat) and must be
+// in full international form; wa.me wants it without "+" or leading zeros.
+func whatsappPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	var number string
+	if opts["number"] != "" {
+		n, err := phoneNumber(opts["number"])
+		if err != nil {
+			return "", err
+		}
+		number = strings.TrimLeft(strings.TrimPrefix(n, "+"), "0")
+		if strings.ContainsAny(number, "*#") || len(number) < 8 {
+			return "", fmt.Errorf("%q is not an international number WhatsApp can use", opts["number"])
+		}
+	}
+	return "https://wa.me/" + number + "?text=" + uriEscape(msg.Code), nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timePlaceholders are the layouts of the built-in {{name}} placeholders,
+// which take an offset such as {{now+5m}} or {{date+1d}}.
+var timePlaceholders = map[string]string{
+	"now":      "15:04",
+	"time":     "15:04",
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04",
+	"weekday":  "Monday",
+}
+
+// placeholders fills in the {{name}} placeholders messages keep on the
+// sheet: times from the clock when the code is scanned, and values set
+// with -placeholder or printed by a -placeholder-cmd.
+type placeholders struct {
+	values   keyValueFlag
+	commands keyValueFlag
+	now      func() time.Time
+}
+
+// placeholderFlags registers the flags of a command sending scans that
+// fill in placeholders.
+func placeholderFlags(fs *flag.FlagSet) *placeholders {
+	p := &placeholders{values: keyValueFlag{}, commands: keyValueFlag{}, now: time.Now}
+	fs.Var(p.values, "placeholder", "value of a {{name}} placeholder in scanned messages as name=value, may be repeated")
+	fs.Var(p.commands, "placeholder-cmd", "command printing the value of a {{name}} placeholder each time one is scanned, as name=command, e.g. oncall=./who-is-oncall, may be repeated")
+	return p
+}
+
+// resolve returns msg with its placeholders filled in. Unknown ones, and
+// ones whose command fails, are logged and left as they are.
+func (p *placeholders) resolve(msg string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(msg, "{{")
+		if start < 0 {
+			b.WriteString(msg)
+			return b.String()
+		}
+		end := strings.Index(msg[start:], "}}")
+		if end < 0 {
+			b.WriteString(msg)
+			return b.String()
+		}
+		end += start
+		name := msg[start+2 : end]
+		if v, err := p.value(name); err != nil {
+			log.Printf("placeholder {{%s}}: %v", name, err)
+			b.WriteString(msg[:end+2])
+		} else {
+			b.WriteString(msg[:start] + v)
+		}
+		msg = msg[end+2:]
+	}
+}
+
+func (p *placeholders) value(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if v, ok := p.values[name]; ok {
+		return v, nil
+	}
+	if command, ok := p.commands[name]; ok {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return "", fmt.Errorf("no command")
+		}
+		out, err := exec.Command(args[0], args[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", args[0], err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	base, offset := name, ""
+	if i := strings.IndexAny(name, "+-"); i > 0 {
+		base, offset = name[:i], name[i:]
+	}
+	layout, ok := timePlaceholders[base]
+	if !ok {
+		return "", fmt.Errorf("unknown, set it with -placeholder %s=…", name)
+	}
+	t := p.now()
+	if offset != "" {
+		d, err := parseOffset(offset)
+		if err != nil {
+			return "", err
+		}
+		t = t.Add(d)
+	}
+	return t.Format(layout), nil
+}
+
+// parseOffset parses a signed duration such as +5m, -1h30m or +2d.
+func parseOffset(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("bad offset %q, want e.g. +2d", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad offset %q, want e.g. +5m or -1h30m", s)
+	}
+	return d, nil
+}
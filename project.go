@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// project is a project file: several sheets made from one source, such as
+// a wall poster, desk cards and lanyard cards, built together by the
+// project command.
+//
+//	{"flags": ["-messages", "office.json", "-o", "office.png", "-target", "slack"],
+//	 "sheets": [
+//	   {"name": "poster", "flags": ["-columns", "6", "-large-print"]},
+//	   {"name": "desk", "categories": ["status", "thanks"], "flags": ["-tent"]},
+//	   {"name": "lanyard", "messages": ["got-it", "brb-5"], "flags": ["-columns", "2", "-theme", "dark"]}]}
+type project struct {
+	// Flags are generate flags shared by every sheet, paths in them
+	// relative to the directory the command runs in.
+	Flags  []string       `json:"flags,omitempty"`
+	Sheets []projectSheet `json:"sheets"`
+}
+
+// projectSheet is one sheet of a project. It picks its messages and fills
+// in their fields as a team of a -teams file does, the files it writes
+// named with -<name> added, and its own Flags override the project's.
+type projectSheet struct {
+	team
+	Flags []string `json:"flags,omitempty"`
+}
+
+// loadProject reads a project file.
+func loadProject(path string) (*project, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p project
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(p.Sheets) == 0 {
+		return nil, fmt.Errorf("%s: no sheets", path)
+	}
+	seen := map[string]bool{}
+	for _, s := range p.Sheets {
+		if s.Name == "" || strings.ContainsAny(s.Name, `/\`) {
+			return nil, fmt.Errorf("%s: sheet name %q can't name files", path, s.Name)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("%s: sheet %s is listed twice", path, s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return &p, nil
+}
+
+// runProject implements `chat-barcodes project`, building every sheet of a
+// project file:
+//
+//	chat-barcodes project office.project.json [-dpi 600 …]
+//
+// Flags after the file apply to every sheet, over the project's own.
+func runProject(args []string) error {
+	fs := flag.NewFlagSet("project", flag.ExitOnError)
+	only := fs.String("sheets", "", "comma separated names of the sheets to build, all if empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: project [-sheets poster,desk] office.project.json [generate flags…]")
+	}
+	p, err := loadProject(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var names []string
+	if *only != "" {
+		for name := range strings.SplitSeq(*only, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+		for _, name := range names {
+			if !slices.ContainsFunc(p.Sheets, func(s projectSheet) bool { return s.Name == name }) {
+				return fmt.Errorf("%s: no sheet %q", fs.Arg(0), name)
+			}
+		}
+	}
+	for i := range p.Sheets {
+		s := &p.Sheets[i]
+		if names != nil && !slices.Contains(names, s.Name) {
+			continue
+		}
+		fmt.Printf("Building: %s\n", s.Name)
+		if err := generateSheets(slices.Concat(p.Flags, s.Flags, fs.Args()[1:]), "", &s.team); err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protocol buffer wire types, see
+// https://protobuf.dev/programming-guides/encoding/.
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+// protoField is a field read from an encoded protocol buffer message: v is
+// the value of a varint or fixed size field, b that of a length delimited
+// one.
+type protoField struct {
+	num  int
+	wire int
+	v    uint64
+	b    []byte
+}
+
+// readProto calls fn with each field of the encoded message b.
+func readProto(b []byte, fn func(f protoField) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("bad protobuf tag")
+		}
+		b = b[n:]
+		f := protoField{num: int(tag >> 3), wire: int(tag & 7)}
+		switch f.wire {
+		case wireVarint:
+			if f.v, n = binary.Uvarint(b); n <= 0 {
+				return errors.New("bad protobuf varint")
+			}
+		case wireI64:
+			if n = 8; len(b) < n {
+				return errors.New("short protobuf field")
+			}
+			f.v = binary.LittleEndian.Uint64(b)
+		case wireI32:
+			if n = 4; len(b) < n {
+				return errors.New("short protobuf field")
+			}
+			f.v = uint64(binary.LittleEndian.Uint32(b))
+		case wireLen:
+			size, m := binary.Uvarint(b)
+			if m <= 0 || size > uint64(len(b)-m) {
+				return errors.New("bad protobuf length")
+			}
+			f.b, n = b[m:m+int(size)], m+int(size)
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", f.wire)
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f protoField) bool() bool { return f.v != 0 }
+
+func (f protoField) double() float64 { return math.Float64frombits(f.v) }
+
+// mapEntry returns the key and value of a map<string, string> entry.
+func (f protoField) mapEntry() (k, v string, err error) {
+	err = readProto(f.b, func(e protoField) error {
+		switch e.num {
+		case 1:
+			k = string(e.b)
+		case 2:
+			v = string(e.b)
+		}
+		return nil
+	})
+	return k, v, err
+}
+
+func appendProtoVarint(b []byte, num int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendProtoBytes(b []byte, num int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireLen)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
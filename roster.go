@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// rosterColumns are the columns a -roster CSV may have, named on its first
+// row in any order. Only username is required.
+var rosterColumns = []string{"username", "name", "role", "team", "mention", "icon"}
+
+// loadRoster reads a team directory CSV as a message set of @mentions, one
+// per person: the username as the payload, with the name as its label and
+// the role as its description. The team column is the person's category
+// and icon a photo or bundled icon. mention, if set, is typed instead of
+// "@username", for chats that mention by ID such as Discord's <@123…>.
+func loadRoster(path string) (*messageSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no header row", path)
+	}
+	col := map[string]int{}
+	for i, name := range rows[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(rosterColumns, name) {
+			return nil, fmt.Errorf("%s: unknown column %q (want %s)", path, name, strings.Join(rosterColumns, ", "))
+		}
+		col[name] = i
+	}
+	if _, ok := col["username"]; !ok {
+		return nil, fmt.Errorf("%s: no username column", path)
+	}
+	set := &messageSet{Title: "Team Directory – Scan to Mention Someone", dir: filepath.Dir(path)}
+	for n, row := range rows[1:] {
+		field := func(name string) string {
+			if i, ok := col[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		username := strings.TrimPrefix(field("username"), "@")
+		if username == "" {
+			return nil, fmt.Errorf("%s:%d: no username", path, n+2)
+		}
+		if strings.ContainsAny(username, " \t") {
+			return nil, fmt.Errorf("%s:%d: username %q has a space in it", path, n+2, username)
+		}
+		msg := ChatMsg{
+			ID:          username,
+			Code:        "@" + username,
+			Label:       field("name"),
+			Description: field("role"),
+			Category:    field("team"),
+			Icon:        field("icon"),
+		}
+		if mention := field("mention"); mention != "" {
+			msg.Code = mention
+		}
+		if msg.Label == "" {
+			msg.Label = msg.Code
+		}
+		set.Messages = append(set.Messages, msg)
+	}
+	if len(set.Messages) == 0 {
+		return nil, fmt.Errorf("%s: nobody in the roster", path)
+	}
+	if err := set.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return set, nil
+}
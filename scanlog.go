@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// scanEvent is one scan as -scan-log writes it and -mqtt publishes it:
+// what was scanned, the message it sent, and the ID and label of its cell
+// in the -manifest, if known.
+type scanEvent struct {
+	Time    time.Time `json:"time"`
+	Scan    string    `json:"scan"`
+	Payload string    `json:"payload"`
+	ID      string    `json:"id,omitempty"`
+	Label   string    `json:"label"`
+}
+
+func newScanEvent(labels scanLabels, scan, msg string) scanEvent {
+	e := scanEvent{Time: time.Now().UTC().Truncate(time.Second), Scan: scan, Payload: msg, Label: msg}
+	if c, ok := labels.cell(scan, msg); ok {
+		e.ID, e.Label = c.ID, c.Label
+	}
+	return e
+}
+
+// key identifies the message e sent in reports: its ID if known.
+func (e scanEvent) key() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return e.Label
+}
+
+// scanLog appends every scan to a file as a line of JSON, for report.
+type scanLog struct {
+	f      *os.File
+	labels scanLabels
+}
+
+// scanLogFlags registers the -scan-log flag of a command reading scans,
+// and returns a function opening its log with the sheet's manifest, or nil
+// without -scan-log.
+func scanLogFlags(fs *flag.FlagSet) func(m *manifest) (*scanLog, error) {
+	path := fs.String("scan-log", "", "append every scan to this file as JSON lines, for the report command")
+	return func(m *manifest) (*scanLog, error) {
+		if *path == "" {
+			return nil, nil
+		}
+		f, err := os.OpenFile(*path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return &scanLog{f, newScanLabels(m)}, nil
+	}
+}
+
+// relabel looks labels up in m from now on.
+func (l *scanLog) relabel(m *manifest) {
+	if l == nil {
+		return
+	}
+	l.labels = newScanLabels(m)
+}
+
+// record logs a scan sending msg. It does nothing on a nil log, so commands
+// can call it whether or not -scan-log is on. Failing to log is reported
+// but doesn't stop the scan being sent.
+func (l *scanLog) record(scan, msg string) {
+	if l == nil {
+		return
+	}
+	b, _ := json.Marshal(newScanEvent(l.labels, scan, msg))
+	if _, err := l.f.Write(append(b, '\n')); err != nil {
+		log.Printf("scan log: %v", err)
+	}
+}
+
+// readScanLogs reads the events of -scan-log files, skipping lines that
+// aren't events, e.g. one cut short by a crash.
+func readScanLogs(paths []string) ([]scanEvent, error) {
+	var events []scanEvent
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		sc := bufio.NewScanner(f)
+		for n := 1; sc.Scan(); n++ {
+			var e scanEvent
+			if err := json.Unmarshal(sc.Bytes(), &e); err != nil || e.Time.IsZero() {
+				log.Printf("report: %s:%d: skipping %q", path, n, sc.Text())
+				continue
+			}
+			events = append(events, e)
+		}
+		f.Close()
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return events, nil
+}
+
+// codeUsage is how one message was used in a report.
+type codeUsage struct {
+	ID, Label string
+	Scans     int
+	Last      time.Time
+}
+
+// runReport implements `chat-barcodes report`, summing up -scan-log files:
+// how often each code was scanned and when, the busiest hours, and with
+// the sheet's -manifest the codes nobody scanned, so dead messages can be
+// pruned and popular ones given bigger cells.
+//
+//	chat-barcodes report -manifest sheet.json -since 720h scans.jsonl
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	manifestPath := fs.String("manifest", "", "manifest of the sheet, to list the codes never scanned")
+	since := fs.Duration("since", 0, "only count scans from this long ago, e.g. 720h for 30 days; 0 for all")
+	top := fs.Int("top", 3, "suggest giving this many of the most scanned codes bigger cells")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: report [-manifest sheet.json] scans.jsonl…")
+	}
+	events, err := readScanLogs(fs.Args())
+	if err != nil {
+		return err
+	}
+	var m *manifest
+	if *manifestPath != "" {
+		if m, err = loadManifest(*manifestPath); err != nil {
+			return err
+		}
+	}
+	if *since > 0 {
+		cutoff := time.Now().Add(-*since)
+		kept := events[:0]
+		for _, e := range events {
+			if !e.Time.Before(cutoff) {
+				kept = append(kept, e)
+			}
+		}
+		events = kept
+	}
+	writeReport(os.Stdout, events, m, *top)
+	return nil
+}
+
+// writeReport writes the report of events on the sheet of m, which may be
+// nil, to w.
+func writeReport(w io.Writer, events []scanEvent, m *manifest, top int) {
+	if len(events) == 0 {
+		fmt.Fprintln(w, "No scans")
+	}
+	usage := map[string]*codeUsage{}
+	var hours [24]int
+	for _, e := range events {
+		u := usage[e.key()]
+		if u == nil {
+			u = &codeUsage{ID: e.ID, Label: e.Label}
+			usage[e.key()] = u
+		}
+		u.Scans++
+		u.Last = maxTime(u.Last, e.Time)
+		hours[e.Time.Local().Hour()]++
+	}
+	used := make([]*codeUsage, 0, len(usage))
+	for _, u := range usage {
+		used = append(used, u)
+	}
+	sort.Slice(used, func(i, j int) bool {
+		if used[i].Scans != used[j].Scans {
+			return used[i].Scans > used[j].Scans
+		}
+		return used[i].Label < used[j].Label
+	})
+
+	if len(events) > 0 {
+		first, last := events[0].Time, events[0].Time
+		for _, e := range events {
+			first, last = minTime(first, e.Time), maxTime(last, e.Time)
+		}
+		fmt.Fprintf(w, "Scans: %d of %d codes, %s to %s\n\n", len(events), len(used), first.Local().Format(time.DateOnly), last.Local().Format(time.DateOnly))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "Scans\tLast scanned\t\tCode")
+		for _, u := range used {
+			fmt.Fprintf(tw, "%d\t%s\t\t%s\n", u.Scans, u.Last.Local().Format("2006-01-02 15:04"), u.Label)
+		}
+		tw.Flush()
+
+		busiest := make([]int, 24)
+		for h := range busiest {
+			busiest[h] = h
+		}
+		sort.SliceStable(busiest, func(i, j int) bool { return hours[busiest[i]] > hours[busiest[j]] })
+		var peaks []string
+		for _, h := range busiest[:3] {
+			if hours[h] > 0 {
+				peaks = append(peaks, fmt.Sprintf("%02d:00 (%d)", h, hours[h]))
+			}
+		}
+		fmt.Fprintf(w, "\nBusiest hours: %s\n", strings.Join(peaks, ", "))
+	}
+
+	if m != nil {
+		var unused []string
+		for _, c := range m.Cells {
+			if usage[c.ID] == nil && usage[c.Label] == nil {
+				unused = append(unused, fmt.Sprintf("%s (%s)", c.Label, c.ID))
+			}
+		}
+		if len(unused) > 0 {
+			fmt.Fprintf(w, "\nNever scanned, consider pruning:\n  %s\n", strings.Join(unused, "\n  "))
+		}
+	}
+	// Scans of codes not on a sheet, such as typed text, can't be promoted.
+	var popular []string
+	for _, u := range used {
+		if len(popular) < top && u.ID != "" {
+			popular = append(popular, fmt.Sprintf("%s (%s): %d", u.Label, u.ID, u.Scans))
+		}
+	}
+	if len(popular) > 0 {
+		fmt.Fprintf(w, "\nMost scanned, consider \"size\": 2:\n  %s\n", strings.Join(popular, "\n  "))
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// readMessages reads newline-terminated scans from r and calls send for
+// every complete message, composing fragments along the way. open, if set,
+// returns the message of each scan before it's composed, so signed and
+// sealed fragments are checked one by one, or false to skip the scan. send
+// is given the scan a message came from, or the message itself if it was
+// composed from several.
+func readMessages(r io.Reader, stripAIMIDs bool, open func(scan string) (string, bool), send func(scan, msg string) error) error {
+	var c composer
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		scan := strings.TrimRight(sc.Text(), "\r")
+		if stripAIMIDs {
+			scan = stripAIM(scan)
+		}
+		if scan == "" {
+			continue
+		}
+		msg := scan
+		if open != nil {
+			var ok bool
+			if msg, ok = open(scan); !ok {
+				continue
+			}
+		}
+		lone := len(c.parts) == 0
+		msg, ok := c.Add(msg)
+		if !ok {
+			continue
+		}
+		if !lone {
+			scan = msg
+		}
+		if err := send(scan, msg); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
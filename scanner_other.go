@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import "io"
+
+// openScanner opens the serial port a scanner is attached to.
+func openScanner(device string, baud int) (io.ReadCloser, error) {
+	return openSerial(device, baud)
+}
+
+// watchScanner opens the serial port a scanner is attached to; keyboard
+// wedge scanners can only be watched on Linux.
+func watchScanner(device string, baud int) (io.ReadCloser, error) {
+	return openSerial(device, baud)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// scriptMessage is a message of a -script, with the words it's sent for.
+type scriptMessage struct {
+	ID, Number, Label, Text string
+}
+
+// scriptMessages returns the messages of l's cells once each, in sheet
+// order, ending in the text a scan of their code would send: the -target
+// and -payload text, before any signing or sealing.
+func scriptMessages(l *layout) []scriptMessage {
+	var msgs []scriptMessage
+	seen := map[string]bool{}
+	for i, c := range l.manifest.Cells {
+		if seen[c.ID] {
+			continue
+		}
+		seen[c.ID] = true
+		msgs = append(msgs, scriptMessage{ID: c.ID, Number: c.Number, Label: c.Label, Text: l.texts[i]})
+	}
+	return msgs
+}
+
+// writeScript writes the messages of l to path as a script sending them
+// without a scanner, picked by its extension: an AutoHotkey v2 script for
+// Windows (.ahk) or a shell script typing with xdotool or ydotool (.sh).
+// It returns the number of messages written.
+func writeScript(path string, l *layout) (int, error) {
+	msgs := scriptMessages(l)
+	var b strings.Builder
+	mode := os.FileMode(0o644)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".ahk":
+		writeAHK(&b, l.opts.Title, msgs)
+	case ".sh":
+		writeShellScript(&b, filepath.Base(path), l.opts.Title, msgs)
+		mode = 0o755
+	default:
+		return 0, fmt.Errorf("-script %s: unknown extension %q, want .ahk or .sh", path, ext)
+	}
+	return len(msgs), os.WriteFile(path, []byte(b.String()), mode)
+}
+
+// ahkString quotes s as an AutoHotkey v2 string.
+var ahkString = strings.NewReplacer("`", "``", `"`, "`\"", "\r", "`r", "\n", "`n", "\t", "`t")
+
+// writeAHK writes an AutoHotkey v2 script with a hotstring per message ID
+// and -numbers number, so typing one, or scanning it off an -indirect sheet,
+// followed by Enter replaces it with the message and sends it, and hotkeys
+// Ctrl+Alt+1 to 9 for the first nine messages.
+func writeAHK(b *strings.Builder, title string, msgs []scriptMessage) {
+	fmt.Fprintf(b, "; %s\n", oneLine(title))
+	b.WriteString("; Written by chat-barcodes -script. Typing a message's ID or number\n")
+	b.WriteString("; and then Enter, Space or Tab replaces it with the message.\n")
+	b.WriteString("#Requires AutoHotkey v2.0\n#SingleInstance Force\n\n")
+	for _, m := range msgs {
+		text := ahkString.Replace(m.Text)
+		fmt.Fprintf(b, "; %s\n", oneLine(m.Label))
+		fmt.Fprintf(b, "Hotstring(\":T:%s\", \"%s\")\n", ahkString.Replace(m.ID), text)
+		if m.Number != "" {
+			fmt.Fprintf(b, "Hotstring(\":T:%s\", \"%s\")\n", m.Number, text)
+		}
+	}
+	for i, m := range msgs[:min(9, len(msgs))] {
+		fmt.Fprintf(b, "\n^!%d::SendText \"%s\" ; %s", i+1, ahkString.Replace(m.Text), oneLine(m.Label))
+	}
+	b.WriteString("\n")
+}
+
+// oneLine joins the lines of s for a comment.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// shellQuote quotes s as a single quoted shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeShellScript writes a POSIX shell script typing the message named by
+// its argument, an ID or -numbers number, into the focused window, for
+// binding to desktop hotkeys: ydotool under Wayland, xdotool otherwise.
+func writeShellScript(b *strings.Builder, name, title string, msgs []scriptMessage) {
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(b, "# %s\n", oneLine(title))
+	b.WriteString("# Written by chat-barcodes -script. Types the message with the ID or\n")
+	b.WriteString("# number given, then Enter unless CHAT_ENTER=0, e.g. bound to a hotkey:\n")
+	if len(msgs) > 0 {
+		fmt.Fprintf(b, "#\n#   ./%s %s\n", name, msgs[0].ID)
+	}
+	b.WriteString("\ncase \"$1\" in\n")
+	for _, m := range msgs {
+		patterns := []string{shellQuote(m.ID)}
+		if m.Number != "" {
+			patterns = append(patterns, m.Number)
+			if t := trimNumber(m.Number); t != m.Number {
+				patterns = append(patterns, t)
+			}
+		}
+		fmt.Fprintf(b, "%s) msg=%s ;; # %s\n", strings.Join(patterns, "|"), shellQuote(m.Text), oneLine(m.Label))
+	}
+	b.WriteString("*)\n\techo \"usage: $0 ID|NUMBER, one of:\" >&2\n")
+	for _, m := range msgs {
+		id := m.ID
+		if m.Number != "" {
+			id = m.Number + " " + id
+		}
+		fmt.Fprintf(b, "\techo %s >&2\n", shellQuote("  "+id))
+	}
+	b.WriteString("\texit 2 ;;\nesac\n\n")
+	b.WriteString(`if [ -n "$WAYLAND_DISPLAY" ]; then
+	ydotool type -- "$msg"
+	[ "${CHAT_ENTER:-1}" = 0 ] || ydotool key 28:1 28:0
+else
+	xdotool type --clearmodifiers -- "$msg"
+	[ "${CHAT_ENTER:-1}" = 0 ] || xdotool key --clearmodifiers Return
+fi
+`)
+}
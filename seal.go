@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Sealed payloads are encrypted to an X25519 key with -encrypt-to, so a
+// photo of the sheet gives away nothing the labels don't: scanning one
+// yields sealedPrefix and the base64 of a one-off public key and the
+// AES-GCM sealed message, which only the typer or bridge holding the
+// secret key (see runKeygen) can open.
+const (
+	sealedPrefix = "CBX1:"
+	publicPrefix = "cbx-public-"
+	secretPrefix = "CBX-SECRET-"
+	sealInfo     = "chat-barcodes sealed payload"
+)
+
+var keyEncoding = base64.RawURLEncoding
+
+// parsePublicKey parses a key printed by runKeygen.
+func parsePublicKey(s string) (*ecdh.PublicKey, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), publicPrefix)
+	b, err := keyEncoding.DecodeString(rest)
+	if !ok || err != nil {
+		return nil, fmt.Errorf("%q isn't a chat-barcodes public key (%s…)", s, publicPrefix)
+	}
+	return ecdh.X25519().NewPublicKey(b)
+}
+
+// loadSecretKey reads the secret key from a file written by runKeygen,
+// ignoring its comment lines.
+func loadSecretKey(path string) (*ecdh.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := bufio.NewScanner(bytes.NewReader(b))
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if !strings.HasPrefix(line, secretPrefix) {
+			continue
+		}
+		raw, err := keyEncoding.DecodeString(strings.TrimPrefix(line, secretPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return ecdh.X25519().NewPrivateKey(raw)
+	}
+	return nil, fmt.Errorf("%s: no %s… key", path, secretPrefix)
+}
+
+func encodePublicKey(k *ecdh.PublicKey) string {
+	return publicPrefix + keyEncoding.EncodeToString(k.Bytes())
+}
+
+// sealKey derives the AES key of a payload sealed with the one-off key
+// ephemeral to recipient from their shared secret.
+func sealKey(shared []byte, ephemeral, recipient *ecdh.PublicKey) ([]byte, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+	return hkdf.Key(sha256.New, shared, salt, sealInfo, 32)
+}
+
+// sealText encrypts text to recipient. Every call uses a new one-off key,
+// so the same message seals differently each time.
+func sealText(recipient *ecdh.PublicKey, text string) (string, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", err
+	}
+	key, err := sealKey(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	// The key is never used twice, so neither is the zero nonce.
+	nonce := make([]byte, aead.NonceSize())
+	sealed := aead.Seal(ephemeral.PublicKey().Bytes(), nonce, []byte(text), nil)
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// openText returns the message sealed in payload with key's public key,
+// and whether payload was sealed at all; unsealed payloads are returned
+// as they are.
+func openText(key *ecdh.PrivateKey, payload string) (string, bool, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(payload), sealedPrefix)
+	if !ok {
+		return payload, false, nil
+	}
+	b, err := base64.RawStdEncoding.DecodeString(rest)
+	if err != nil || len(b) < 32 {
+		return "", true, errors.New("malformed sealed payload")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(b[:32])
+	if err != nil {
+		return "", true, err
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return "", true, err
+	}
+	k, err := sealKey(shared, ephemeral, key.PublicKey())
+	if err != nil {
+		return "", true, err
+	}
+	aead, err := newGCM(k)
+	if err != nil {
+		return "", true, err
+	}
+	text, err := aead.Open(nil, make([]byte, aead.NonceSize()), b[32:], nil)
+	if err != nil {
+		return "", true, errors.New("sealed payload isn't for this key or was damaged")
+	}
+	return string(text), true, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// openScan returns the message of a scan, opening it with key if it was
+// sealed; sealed scans fail without one rather than send their cipher text.
+func openScan(key *ecdh.PrivateKey, msg string) (string, error) {
+	if key == nil {
+		if strings.HasPrefix(strings.TrimSpace(msg), sealedPrefix) {
+			return "", errors.New("sealed payload, start with -decrypt-key to open it")
+		}
+		return msg, nil
+	}
+	text, _, err := openText(key, msg)
+	return text, err
+}
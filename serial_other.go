@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"io"
+	"os"
+	"runtime"
+)
+
+var defaultSerialDevice = map[string]string{
+	"windows": `\\.\COM3`,
+	"darwin":  "/dev/tty.usbmodem1",
+}[runtime.GOOS]
+
+// openSerial opens device as a plain file. Line settings are left to the OS;
+// set the speed beforehand with `mode` (Windows) or `stty` (macOS, BSD).
+func openSerial(device string, baud int) (io.ReadWriteCloser, error) {
+	return os.OpenFile(device, os.O_RDWR, 0)
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// runServe renders the configured sheet over HTTP, rebuilding it on every
+// request so that edits to the message file show up without restarting.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "address to serve the sheet on")
+	var config sheetConfig
+	config.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cleanup, err := config.usePack(fs)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	// Fail at startup rather than on the first request.
+	if _, err := config.build(); err != nil {
+		return err
+	}
+
+	log.Printf("serve: serving the editor, /sheet.png, /sheet.pdf, /cell/{id}.png, /generate, gRPC and /metrics on %s", *listen)
+	s := &server{config: &config, cache: renderCache{max: maxCacheBytes}}
+	srv := &http.Server{Addr: *listen, Handler: s.handler(), Protocols: new(http.Protocols)}
+	// gRPC clients talk HTTP/2 without TLS.
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	return srv.ListenAndServe()
+}
+
+// server renders the sheets of serve, keeping recent ones.
+type server struct {
+	config  *sheetConfig
+	cache   renderCache
+	metrics serveMetrics
+}
+
+// handler serves:
+//
+//	GET /sheet.png          the sheet, ?page=N for a page of a longer one
+//	GET /sheet.pdf          every page as a PDF
+//	GET /cell/{id}.png      a single cell, by message ID or label
+//	POST /generate          the sheet of a posted message set, see generate
+//	SheetService.Generate   the same over gRPC, see grpcHandler
+//	GET /                   a message set editor, see editorPage
+//	GET /metrics            Prometheus metrics, see serveMetrics
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	build := func(w http.ResponseWriter) *layout {
+		l, err := s.config.build()
+		if err != nil {
+			log.Printf("serve: %v", err)
+			s.metrics.fail("build")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil
+		}
+		return l
+	}
+	mux.HandleFunc("GET /sheet.png", func(w http.ResponseWriter, r *http.Request) {
+		l := build(w)
+		if l == nil {
+			return
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			var err error
+			if page, err = strconv.Atoi(p); err != nil {
+				http.Error(w, "bad page", http.StatusBadRequest)
+				return
+			}
+		}
+		s.writePage(w, "sheet.png", s.cacheKey(l.set, "png", strconv.Itoa(page)), l, page)
+	})
+	mux.HandleFunc("GET /sheet.pdf", func(w http.ResponseWriter, r *http.Request) {
+		if l := build(w); l != nil {
+			s.writePDF(w, "sheet.pdf", s.cacheKey(l.set, "pdf"), l)
+		}
+	})
+	mux.HandleFunc("POST /generate", s.generate)
+	mux.Handle("POST "+grpcGenerate, grpcHandler(s))
+	mux.Handle("GET /metrics", &s.metrics)
+	handleEditor(s.config, mux)
+	mux.HandleFunc("GET /cell/{file}", func(w http.ResponseWriter, r *http.Request) {
+		name, ok := strings.CutSuffix(r.PathValue("file"), ".png")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		l := build(w)
+		if l == nil {
+			return
+		}
+		for i, msg := range l.msgs {
+			if msg.Key() != name && !strings.EqualFold(msg.Label, name) {
+				continue
+			}
+			s.respond(w, "cell", s.cacheKey(l.set, "cell", msg.Key()), func() (rendered, error) {
+				width, height := l.opts.CellSize(l.cells, i)
+				img, err := sheet.RenderCell(l.opts, l.cells[i], width, height)
+				if err != nil {
+					return rendered{}, err
+				}
+				return encodePNG(img)
+			})
+			return
+		}
+		http.NotFound(w, r)
+	})
+	return mux
+}
+
+// rendered is a rendered response.
+type rendered struct {
+	contentType string
+	body        []byte
+}
+
+// respond writes the response cached under key, or else renders, caches and
+// writes it, recording the render in the metrics for endpoint.
+func (s *server) respond(w http.ResponseWriter, endpoint, key string, render func() (rendered, error)) {
+	r, ok := s.cache.get(key)
+	s.metrics.cached(ok)
+	if !ok {
+		start := time.Now()
+		var err error
+		if r, err = render(); err != nil {
+			s.metrics.fail(endpoint)
+			log.Printf("serve: %s: %v", endpoint, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.metrics.rendered(endpoint, time.Since(start))
+		s.cache.add(key, r)
+	}
+	w.Header().Set("Content-Type", r.contentType)
+	w.Write(r.body)
+}
+
+// writePage writes page (from 1) of the sheet of l as a PNG.
+func (s *server) writePage(w http.ResponseWriter, endpoint, key string, l *layout, page int) {
+	pages := len(sheet.PageStarts(l.opts, l.cells))
+	if page < 1 || page > pages {
+		http.Error(w, fmt.Sprintf("page %d of %d", page, pages), http.StatusNotFound)
+		return
+	}
+	s.respond(w, endpoint, key, func() (rendered, error) {
+		img, err := sheet.RenderPage(l.opts, l.cells, page-1)
+		if err != nil {
+			return rendered{}, err
+		}
+		return encodePNG(img)
+	})
+}
+
+// writePDF writes every page of the sheet of l as a PDF.
+func (s *server) writePDF(w http.ResponseWriter, endpoint, key string, l *layout) {
+	s.respond(w, endpoint, key, func() (rendered, error) {
+		var buf bytes.Buffer
+		if err := sheet.WritePDF(&buf, l.opts, l.cells); err != nil {
+			return rendered{}, err
+		}
+		return rendered{"application/pdf", buf.Bytes()}, nil
+	})
+}
+
+func encodePNG(img image.Image) (rendered, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return rendered{}, err
+	}
+	return rendered{"image/png", buf.Bytes()}, nil
+}
+
+// cacheKey identifies a response rendered from v, a message set and any
+// request options, and parts saying what was rendered. Responses also change
+// with the logo and background files; icon images are only reread when the
+// message set changes.
+func (s *server) cacheKey(v any, parts ...string) string {
+	h := sha256.New()
+	if err := json.NewEncoder(h).Encode(v); err != nil {
+		return "" // not cached
+	}
+	for _, p := range append(parts, fileStamp(s.config.logo), fileStamp(s.config.background)) {
+		io.WriteString(h, p+"\x00")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fileStamp changes whenever the file at path does.
+func fileStamp(path string) string {
+	if path == "" {
+		return ""
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprint(fi.ModTime().UnixNano(), fi.Size())
+}
+
+// maxCacheBytes limits the rendered responses serve keeps.
+const maxCacheBytes = 64 << 20
+
+// renderCache keeps rendered responses up to max bytes, dropping the oldest
+// first.
+type renderCache struct {
+	mu      sync.Mutex
+	max     int
+	size    int
+	entries map[string]rendered
+	order   []string
+}
+
+func (c *renderCache) get(key string) (rendered, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries[key]
+	return r, ok && key != ""
+}
+
+func (c *renderCache) add(key string, r rendered) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok || key == "" || len(r.body) > c.max {
+		return
+	}
+	if c.entries == nil {
+		c.entries = map[string]rendered{}
+	}
+	for c.size+len(r.body) > c.max {
+		c.size -= len(c.entries[c.order[0]].body)
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = r
+	c.order = append(c.order, key)
+	c.size += len(r.body)
+}
+
+// maxGenerateBody limits the message sets POST /generate accepts.
+const maxGenerateBody = 1 << 20
+
+// Limits on the sheets POST /generate and gRPC Generate render, so that
+// one request can't take the server's memory: a page is allocated whole.
+const (
+	minGenerateDPI, maxGenerateDPI = 72, 600
+	maxGenerateColumns             = 12
+	maxGeneratePageRows            = 20
+	maxGenerateMessages            = 500
+	maxGeneratePagePixels          = 40_000_000 // A4 at 600 dpi is 35 million
+	// maxGenerateSheetPixels limits all the pages together, which a PDF
+	// holds at once: 22 A4 pages at 300 dpi, or 5 at 600.
+	maxGenerateSheetPixels = 200_000_000
+)
+
+// generateRequest is the body of POST /generate: a message set as in a
+// -messages file, with optional "options".
+type generateRequest struct {
+	messageSet
+	Options generateOptions `json:"options"`
+}
+
+// generateOptions are the sheet settings a request may change; unset ones
+// keep the server's flags. Files (fonts, logo, background) can only be set
+// on the server.
+type generateOptions struct {
+	Format         string            `json:"format,omitempty"` // png (default) or pdf
+	Page           int               `json:"page,omitempty"`   // of a PNG, from 1
+	Target         string            `json:"target,omitempty"`
+	Payload        string            `json:"payload,omitempty"`
+	PayloadOpts    map[string]string `json:"payload_opts,omitempty"`
+	Theme          string            `json:"theme,omitempty"`
+	InvertCodes    bool              `json:"invert_codes,omitempty"`
+	Watermark      string            `json:"watermark,omitempty"`
+	DPI            float64           `json:"dpi,omitempty"`
+	Columns        int               `json:"columns,omitempty"`
+	CellStyle      string            `json:"cell_style,omitempty"`
+	PageRows       int               `json:"page_rows,omitempty"`
+	PageNumbers    bool              `json:"page_numbers,omitempty"`
+	KeepCategories bool              `json:"keep_categories,omitempty"`
+	Fingerprint    bool              `json:"fingerprint,omitempty"`
+	LargePrint     bool              `json:"large_print,omitempty"`
+	CategoryColors bool              `json:"category_colors,omitempty"`
+	AIMSafe        bool              `json:"aim_safe,omitempty"`
+	Lang           string            `json:"lang,omitempty"`
+	Variant        string            `json:"variant,omitempty"` // short or long
+	Prefix         string            `json:"prefix,omitempty"`
+	Suffix         string            `json:"suffix,omitempty"`
+}
+
+// check rejects options asking for more than the limits above.
+func (o generateOptions) check() error {
+	switch {
+	case math.IsNaN(o.DPI) || o.DPI != 0 && (o.DPI < minGenerateDPI || o.DPI > maxGenerateDPI):
+		return fmt.Errorf("dpi %g is out of range, want %d to %d", o.DPI, minGenerateDPI, maxGenerateDPI)
+	case o.Columns < 0 || o.Columns > maxGenerateColumns:
+		return fmt.Errorf("columns %d is out of range, want 1 to %d", o.Columns, maxGenerateColumns)
+	case o.PageRows < 0 || o.PageRows > maxGeneratePageRows:
+		return fmt.Errorf("page_rows %d is out of range, want 1 to %d", o.PageRows, maxGeneratePageRows)
+	case o.Page < 0:
+		return fmt.Errorf("page %d is out of range", o.Page)
+	}
+	return nil
+}
+
+// apply returns config with the options set, for rendering a posted set
+// rather than the built-in fragment or emoji sheets.
+func (o generateOptions) apply(config sheetConfig) sheetConfig {
+	config.fragments, config.emoji = false, false
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&config.targetName, o.Target)
+	set(&config.payloadName, o.Payload)
+	set(&config.themeName, o.Theme)
+	set(&config.watermark, o.Watermark)
+	set(&config.language, o.Lang)
+	set(&config.cellStyle, o.CellStyle)
+	set(&config.variant, o.Variant)
+	set(&config.prefix, o.Prefix)
+	set(&config.suffix, o.Suffix)
+	if o.PayloadOpts != nil {
+		config.payloadOpts = keyValueFlag(o.PayloadOpts)
+	}
+	if o.DPI > 0 {
+		config.dpi = o.DPI
+	}
+	if o.Columns > 0 {
+		config.columns = o.Columns
+	}
+	if o.PageRows > 0 {
+		config.pageRows = o.PageRows
+	}
+	config.pageNumbers = config.pageNumbers || o.PageNumbers
+	config.keepCategories = config.keepCategories || o.KeepCategories
+	config.invertCodes = config.invertCodes || o.InvertCodes
+	config.fingerprint = config.fingerprint || o.Fingerprint
+	config.largePrint = config.largePrint || o.LargePrint
+	config.categoryColors = config.categoryColors || o.CategoryColors
+	config.aimSafe = config.aimSafe || o.AIMSafe
+	return config
+}
+
+// generate lays out the sheet of a request received over HTTP or gRPC.
+func (c *sheetConfig) generate(req *generateRequest) (*layout, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	if len(req.Messages) > maxGenerateMessages {
+		return nil, fmt.Errorf("%d messages is too many, want at most %d", len(req.Messages), maxGenerateMessages)
+	}
+	if err := req.Options.check(); err != nil {
+		return nil, err
+	}
+	req.posted = true
+	config := req.Options.apply(*c)
+	l, err := config.layout(&req.messageSet)
+	if err != nil {
+		return nil, err
+	}
+	w, h := l.opts.PagePixels()
+	if w*h > maxGeneratePagePixels {
+		return nil, fmt.Errorf("%d×%d pixel pages are too big, want at most %d million pixels; lower the dpi", w, h, maxGeneratePagePixels/1_000_000)
+	}
+	if pages := len(sheet.PageStarts(l.opts, l.cells)); pages*w*h > maxGenerateSheetPixels {
+		return nil, fmt.Errorf("%d pages of %d×%d pixels are too big, want at most %d million pixels in all; lower the dpi or fit more on a page", pages, w, h, maxGenerateSheetPixels/1_000_000)
+	}
+	return l, nil
+}
+
+// generate renders the sheet of a posted generateRequest, as a PNG or PDF,
+// for tools that make their own sheets. Messages may only use the bundled
+// icons.
+func (s *server) generate(w http.ResponseWriter, r *http.Request) {
+	var req generateRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGenerateBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := s.cacheKey(req, "generate")
+	l, err := s.config.generate(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch req.Options.Format {
+	case "", "png":
+		s.writePage(w, "generate", key, l, max(req.Options.Page, 1))
+	case "pdf":
+		s.writePDF(w, "generate", key, l)
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q, want png or pdf", req.Options.Format), http.StatusBadRequest)
+	}
+}
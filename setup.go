@@ -0,0 +1,142 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// bundledSetupProfiles are the profiles -model can name, one file per model
+// transcribed from its programming guide. Code 128 programming barcodes start
+// with FNC3, written \u00f3 in the files.
+//
+//go:embed setup/*.json
+var bundledSetupProfiles embed.FS
+
+// setupProfile lists the configuration barcodes for one scanner model, in
+// the order they should be scanned.
+type setupProfile struct {
+	Model string      `json:"model"`
+	Codes []setupCode `json:"codes"`
+}
+
+// setupCode is a single programming barcode as printed in the vendor's
+// configuration guide.
+type setupCode struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Symbology   string `json:"symbology"` // defaults to code128, which most guides use
+	Payload     string `json:"payload"`
+}
+
+// runSetup implements `chat-barcodes setup`, which renders a scanner's
+// configuration barcodes (Enter suffix, keyboard layout, beeper, ...) onto a
+// single page that can be scanned top to bottom when deploying a scanner.
+//
+// Programming barcodes are vendor and often firmware specific, so they come
+// from a bundled profile named by -model, or for other scanners from a
+// profile file transcribed from the model's programming guide, rather than
+// being guessed at here.
+func runSetup(args []string) error {
+	fs := flag.NewFlagSet("setup", flag.ExitOnError)
+	model := fs.String("model", "", "bundled scanner profile: "+strings.Join(setupModels(), ", "))
+	profilePath := fs.String("profile", "", "JSON scanner profile listing the configuration barcodes, for models not bundled")
+	out := fs.String("o", "scanner-setup.png", "output PNG")
+	var fonts fontFlags
+	fonts.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var profile *setupProfile
+	var err error
+	switch {
+	case *model != "" && *profilePath != "":
+		return errors.New("-model and -profile can't be used together")
+	case *model != "":
+		profile, err = bundledSetupProfile(*model)
+	case *profilePath != "":
+		profile, err = loadSetupProfile(*profilePath)
+	default:
+		return errors.New("-model or -profile is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	cells := make([]sheet.Cell, len(profile.Codes))
+	for i, code := range profile.Codes {
+		symbology := code.Symbology
+		if symbology == "" {
+			symbology = sheet.Code128
+		}
+		cells[i] = sheet.Cell{
+			Payload:     code.Payload,
+			Label:       fmt.Sprintf("%d. %s", i+1, code.Label),
+			Description: code.Description,
+			Symbology:   symbology,
+		}
+	}
+
+	opts := sheet.DefaultOptions()
+	opts.Title = profile.Model + " – scan top to bottom"
+	opts.Footer = ""
+	opts.Columns = 1
+	opts.Rows = 8
+	opts.Fonts = fonts.Fonts()
+	if _, err := sheet.SavePNG(*out, opts, cells); err != nil {
+		return err
+	}
+
+	fmt.Println("Saved:", *out)
+	return nil
+}
+
+// setupModels returns the names -model accepts.
+func setupModels() []string {
+	entries, _ := fs.ReadDir(bundledSetupProfiles, "setup")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bundledSetupProfile returns the bundled profile for model, e.g.
+// honeywell-voyager-1450g.
+func bundledSetupProfile(model string) (*setupProfile, error) {
+	b, err := fs.ReadFile(bundledSetupProfiles, path.Join("setup", model+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("no bundled profile for %q, want one of %s or -profile", model, strings.Join(setupModels(), ", "))
+	}
+	return parseSetupProfile(model, b)
+}
+
+func loadSetupProfile(path string) (*setupProfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseSetupProfile(path, b)
+}
+
+func parseSetupProfile(path string, b []byte) (*setupProfile, error) {
+	var p setupProfile
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(p.Codes) == 0 {
+		return nil, fmt.Errorf("%s: profile has no codes", path)
+	}
+	return &p, nil
+}
@@ -0,0 +1,121 @@
+package sheet
+
+import "unicode"
+
+// Arabic shaping: x/image draws one glyph per rune with no OpenType shaping,
+// so Arabic letters are replaced by their contextual presentation forms
+// (Unicode FE70–FEFF, FB50–FDFF) before drawing. This covers the Arabic and
+// common Persian/Urdu letters and the lam-alef ligatures, which is enough to
+// make labels legible with fonts that include the presentation forms (Noto
+// Sans Arabic, Tahoma, ...), but isn't proper shaping.
+
+// arabicForms are a letter's isolated, final, initial and medial forms.
+// Right-joining letters have no initial or medial forms.
+type arabicForms [4]rune
+
+var arabicLetters = map[rune]arabicForms{}
+
+func init() {
+	// Letters U+0622–U+064A in order of their contiguous presentation forms
+	// starting at U+FE81, with whether they are dual joining.
+	letters := []struct {
+		r    rune
+		dual bool
+	}{
+		{0x0622, false}, {0x0623, false}, {0x0624, false}, {0x0625, false},
+		{0x0626, true}, {0x0627, false}, {0x0628, true}, {0x0629, false},
+		{0x062A, true}, {0x062B, true}, {0x062C, true}, {0x062D, true},
+		{0x062E, true}, {0x062F, false}, {0x0630, false}, {0x0631, false},
+		{0x0632, false}, {0x0633, true}, {0x0634, true}, {0x0635, true},
+		{0x0636, true}, {0x0637, true}, {0x0638, true}, {0x0639, true},
+		{0x063A, true}, {0x0641, true}, {0x0642, true}, {0x0643, true},
+		{0x0644, true}, {0x0645, true}, {0x0646, true}, {0x0647, true},
+		{0x0648, false}, {0x0649, false}, {0x064A, true},
+	}
+	form := rune(0xFE81)
+	for _, l := range letters {
+		if l.dual {
+			arabicLetters[l.r] = arabicForms{form, form + 1, form + 2, form + 3}
+			form += 4
+		} else {
+			arabicLetters[l.r] = arabicForms{form, form + 1, 0, 0}
+			form += 2
+		}
+	}
+	arabicLetters[0x0621] = arabicForms{0xFE80, 0, 0, 0} // hamza doesn't join
+
+	// Persian and Urdu letters from the Presentation Forms-A block.
+	arabicLetters[0x067E] = arabicForms{0xFB56, 0xFB57, 0xFB58, 0xFB59} // peh
+	arabicLetters[0x0686] = arabicForms{0xFB7A, 0xFB7B, 0xFB7C, 0xFB7D} // tcheh
+	arabicLetters[0x0698] = arabicForms{0xFB8A, 0xFB8B, 0, 0}           // jeh
+	arabicLetters[0x06A9] = arabicForms{0xFB8E, 0xFB8F, 0xFB90, 0xFB91} // keheh
+	arabicLetters[0x06AF] = arabicForms{0xFB92, 0xFB93, 0xFB94, 0xFB95} // gaf
+	arabicLetters[0x06CC] = arabicForms{0xFBFC, 0xFBFD, 0xFBFE, 0xFBFF} // farsi yeh
+}
+
+const (
+	arabicLam     = 0x0644
+	arabicTatweel = 0x0640
+)
+
+// lamAlef are the isolated forms of lam followed by each alef; the final
+// form is the next code point.
+var lamAlef = map[rune]rune{0x0622: 0xFEF5, 0x0623: 0xFEF7, 0x0625: 0xFEF9, 0x0627: 0xFEFB}
+
+// joinsNext reports whether r connects to the letter after it.
+func joinsNext(r rune) bool {
+	return r == arabicTatweel || arabicLetters[r][2] != 0
+}
+
+// joinsPrev reports whether r connects to the letter before it.
+func joinsPrev(r rune) bool {
+	return r == arabicTatweel || arabicLetters[r][1] != 0
+}
+
+// shapeArabic replaces the Arabic letters in s, in logical order, with
+// their contextual forms.
+func shapeArabic(s string) string {
+	in := []rune(s)
+	out := make([]rune, 0, len(in))
+	// neighbour returns the closest letter from i in steps of dir, skipping
+	// vowel marks, which don't affect joining.
+	neighbour := func(i, dir int) rune {
+		for i += dir; i >= 0 && i < len(in); i += dir {
+			if !unicode.Is(unicode.Mn, in[i]) {
+				return in[i]
+			}
+		}
+		return 0
+	}
+	for i := 0; i < len(in); i++ {
+		r := in[i]
+		forms, ok := arabicLetters[r]
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+		afterPrev := joinsNext(neighbour(i, -1))
+		if r == arabicLam && i+1 < len(in) {
+			if lig, ok := lamAlef[in[i+1]]; ok {
+				if afterPrev {
+					lig++
+				}
+				out = append(out, lig)
+				i++
+				continue
+			}
+		}
+		beforeNext := joinsNext(r) && joinsPrev(neighbour(i, 1))
+		switch {
+		case afterPrev && beforeNext:
+			out = append(out, forms[3])
+		case afterPrev && forms[1] != 0:
+			out = append(out, forms[1])
+		case beforeNext:
+			out = append(out, forms[2])
+		default:
+			out = append(out, forms[0])
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,75 @@
+package sheet
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/draw"
+)
+
+// Backdrops are faded towards the page background so that even their
+// darkest parts stay light enough for scanners to treat as quiet zone.
+const (
+	backdropContrast  = 0.15
+	watermarkContrast = 0.12
+)
+
+// drawBackdrop draws the background image and watermark of opts, if any.
+func drawBackdrop(dc *gg.Context, opts Options) {
+	bg := opts.Theme.Background
+	if opts.Background != nil {
+		dc.DrawImage(fade(cover(opts.Background, dc.Width(), dc.Height()), bg, backdropContrast), 0, 0)
+	}
+	if opts.Watermark != "" {
+		w, h := float64(dc.Width()), float64(dc.Height())
+		// Run corner to corner across 70% of the diagonal.
+		diagonal := math.Hypot(w, h)
+		dc.SetFontFace(opts.Fonts.title(100))
+		tw, _ := dc.MeasureString(opts.Watermark)
+		dc.SetFontFace(opts.Fonts.title(100 * diagonal * 0.7 / tw))
+		dc.SetColor(blend(opts.Theme.Text, bg, watermarkContrast))
+		dc.Push()
+		dc.RotateAbout(-math.Atan2(h, w), w/2, h/2)
+		drawString(dc, opts.Watermark, w/2, h/2, 0.5, 0.5)
+		dc.Pop()
+	}
+}
+
+// cover scales img to fill a w x h page, cropping whatever sticks out.
+func cover(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	scale := math.Max(float64(w)/float64(b.Dx()), float64(h)/float64(b.Dy()))
+	sw, sh := int(float64(b.Dx())*scale), int(float64(b.Dy())*scale)
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	dst := image.Rect((w-sw)/2, (h-sh)/2, (w-sw)/2+sw, (h-sh)/2+sh)
+	draw.CatmullRom.Scale(out, dst, img, b, draw.Src, nil)
+	return out
+}
+
+// fade blends every pixel of img towards bg, keeping contrast of its
+// difference from bg.
+func fade(img image.Image, bg color.Color, contrast float64) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.Set(x, y, blend(img.At(x, y), bg, contrast))
+		}
+	}
+	return out
+}
+
+// blend returns c moved towards bg, keeping contrast of the difference.
+// Transparent parts of c show bg.
+func blend(c, bg color.Color, contrast float64) color.Color {
+	r, g, b, a := c.RGBA()
+	br, bgG, bb, _ := bg.RGBA()
+	mix := func(v, under uint32) uint8 {
+		// Composite premultiplied v over the background first.
+		v += under * (0xffff - a) / 0xffff
+		return uint8((float64(under) + (float64(v)-float64(under))*contrast) / 0x101)
+	}
+	return color.RGBA{R: mix(r, br), G: mix(g, bgG), B: mix(b, bb), A: 255}
+}
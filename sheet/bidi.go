@@ -0,0 +1,153 @@
+package sheet
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// Right-to-left text: gg draws runes left to right, so Hebrew and Arabic
+// text is reordered into display order (and Arabic shaped, see shapeArabic)
+// one line at a time before being drawn.
+
+// isRTL reports whether r is a strong right-to-left character.
+func isRTL(r rune) bool {
+	p, _ := bidi.LookupRune(r)
+	return p.Class() == bidi.R || p.Class() == bidi.AL
+}
+
+// hasRTL reports whether s contains right-to-left text.
+func hasRTL(s string) bool {
+	return strings.IndexFunc(s, isRTL) >= 0
+}
+
+// hasLTR reports whether s contains strong left-to-right characters.
+func hasLTR(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		p, _ := bidi.LookupRune(r)
+		return p.Class() == bidi.L
+	}) >= 0
+}
+
+// baseDirection returns the paragraph direction of s, that of its first
+// strong character.
+func baseDirection(s string) bidi.Direction {
+	for _, r := range s {
+		p, _ := bidi.LookupRune(r)
+		switch p.Class() {
+		case bidi.L:
+			return bidi.LeftToRight
+		case bidi.R, bidi.AL:
+			return bidi.RightToLeft
+		}
+	}
+	return bidi.LeftToRight
+}
+
+// visual returns one line of text of a paragraph in direction dir in the
+// order it is displayed in.
+func visual(line string, dir bidi.Direction) string {
+	if !hasRTL(line) {
+		return line
+	}
+	line = shapeArabic(line)
+
+	var p bidi.Paragraph
+	var err error
+	if dir == bidi.RightToLeft {
+		_, err = p.SetString(line, bidi.DefaultDirection(bidi.RightToLeft))
+	} else {
+		// A leading left-to-right mark stops the line being detected as
+		// right to left on its own.
+		_, err = p.SetString("‎" + line)
+	}
+	if err != nil {
+		return line
+	}
+	o, err := p.Order()
+	if err != nil {
+		return line
+	}
+
+	// The ordering only tells us each run's direction, which is enough to
+	// reverse the right-to-left levels (UAX #9 rule L2) for plain text
+	// without explicit embeddings: in a right-to-left paragraph all runs
+	// are reversed; in a left-to-right one, right-to-left runs together with
+	// the numbers between and after them.
+	runs := make([]string, o.NumRuns())
+	reversed := make([]bool, len(runs))
+	for i := range runs {
+		run := o.Run(i)
+		runs[i] = run.String()
+		if run.Direction() == bidi.RightToLeft {
+			runs[i] = reverseRunes(runs[i])
+			reversed[i] = true
+		} else if dir == bidi.RightToLeft || i > 0 && reversed[i-1] && !hasLTR(runs[i]) {
+			reversed[i] = true
+		}
+	}
+	for i := 0; i < len(runs); {
+		if !reversed[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runs) && reversed[j] {
+			j++
+		}
+		for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+			runs[a], runs[b] = runs[b], runs[a]
+		}
+		i = j
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Bidi_Control, r) {
+			return -1
+		}
+		return r
+	}, strings.Join(runs, ""))
+}
+
+// reverseRunes reverses s, keeping combining marks after the character they
+// belong to and mirroring brackets.
+func reverseRunes(s string) string {
+	var clusters []string
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) && len(clusters) > 0 {
+			clusters[len(clusters)-1] += string(r)
+			continue
+		}
+		clusters = append(clusters, bidi.ReverseString(string(r)))
+	}
+	var b strings.Builder
+	for i := len(clusters) - 1; i >= 0; i-- {
+		b.WriteString(clusters[i])
+	}
+	return b.String()
+}
+
+// drawString is DrawStringAnchored for text that may be right to left.
+func drawString(dc *gg.Context, s string, x, y, ax, ay float64) {
+	dc.DrawStringAnchored(visual(s, baseDirection(s)), x, y, ax, ay)
+}
+
+// drawWrapped is DrawStringWrapped for text that may be right to left.
+// Right-to-left paragraphs are wrapped in logical order, then each line
+// reordered and right aligned.
+func drawWrapped(dc *gg.Context, s string, x, y, ax, ay, width, lineSpacing float64, align gg.Align) {
+	if !hasRTL(s) {
+		dc.DrawStringWrapped(s, x, y, ax, ay, width, lineSpacing, align)
+		return
+	}
+	dir := baseDirection(s)
+	if dir == bidi.RightToLeft {
+		align = gg.AlignRight
+	}
+	lines := dc.WordWrap(s, width)
+	for i, line := range lines {
+		lines[i] = visual(line, dir)
+	}
+	dc.DrawStringWrapped(strings.Join(lines, "\n"), x, y, ax, ay, width, lineSpacing, align)
+}
@@ -0,0 +1,247 @@
+package sheet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/fogleman/gg"
+)
+
+// Cache keeps rendered cells and pages between renders as PNG data, so
+// that regenerating a sheet only draws the cells whose content or style
+// changed, and only encodes the pages they are on. Keys are hex digests of
+// everything drawn.
+type Cache interface {
+	Get(key string) []byte // nil if missing
+	Put(key string, png []byte) error
+}
+
+// DirCache is a Cache storing PNG files in a directory, created on first
+// use.
+type DirCache string
+
+func (d DirCache) path(key string) string {
+	return filepath.Join(string(d), key+".png")
+}
+
+func (d DirCache) Get(key string) []byte {
+	b, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func (d DirCache) Put(key string, png []byte) error {
+	if err := os.MkdirAll(string(d), 0o755); err != nil {
+		return err
+	}
+	// Write and rename, so another run never reads half a file.
+	f, err := os.CreateTemp(string(d), "put-*.png")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(png); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), d.path(key))
+}
+
+// savePage writes page of pages to path, copied from opts.Cache if it was
+// rendered the same way before.
+func savePage(path string, opts Options, pages [][]Cell, page int) error {
+	if opts.Cache == nil {
+		img, err := renderPage(opts, pages, page)
+		if err != nil {
+			return err
+		}
+		return gg.SavePNG(path, img)
+	}
+	key := pageKey(opts, pages, page)
+	if b := opts.Cache.Get(key); b != nil {
+		return os.WriteFile(path, b, 0o644)
+	}
+	img, err := renderPage(opts, pages, page)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return opts.Cache.Put(key, buf.Bytes())
+}
+
+// tilePad is the room left around a cached cell for its boundary, which is
+// stroked across the cell's edges.
+const tilePad = 4
+
+// drawCached draws a cell like drawCell, from opts.Cache if it was drawn the
+// same way before. Cells are cached transparent, with the fraction of a
+// pixel they are offset by, so they come out as if drawn in place.
+func drawCached(dc *gg.Context, opts Options, cell Cell, x, y, cellWidth, cellHeight float64) error {
+	ox, oy := math.Floor(x), math.Floor(y)
+	key := cellKey(opts, placedCell{cell, x, y, cellWidth, cellHeight})
+	if b := opts.Cache.Get(key); b != nil {
+		if img, err := png.Decode(bytes.NewReader(b)); err == nil {
+			over(dc, img, int(ox)-tilePad, int(oy)-tilePad)
+			return nil
+		}
+	}
+	fx, fy := x-ox, y-oy
+	tile := gg.NewContext(int(math.Ceil(fx+cellWidth))+2*tilePad, int(math.Ceil(fy+cellHeight))+2*tilePad)
+	if err := drawCell(tile, opts, cell, tilePad+fx, tilePad+fy, cellWidth, cellHeight); err != nil {
+		return err
+	}
+	over(dc, tile.Image(), int(ox)-tilePad, int(oy)-tilePad)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, tile.Image()); err != nil {
+		return err
+	}
+	return opts.Cache.Put(key, buf.Bytes())
+}
+
+// over draws img onto dc with its top left corner at (x, y). Unlike
+// DrawImage, which resamples, it copies the pixels.
+func over(dc *gg.Context, img image.Image, x, y int) {
+	b := img.Bounds()
+	draw.Draw(dc.Image().(*image.RGBA), image.Rect(x, y, x+b.Dx(), y+b.Dy()), img, b.Min, draw.Over)
+}
+
+// cacheFormat changes whenever cells or pages are drawn differently, so
+// that older cached ones aren't used.
+const cacheFormat = 1
+
+// cellKey returns the cache key of a cell as placed on a sheet with opts.
+// Only the fraction of a pixel it is offset by matters.
+func cellKey(opts Options, p placedCell) string {
+	cell := p.cell
+	var continued string
+	if cell.continued {
+		continued = opts.Strings.continued(cell.Category)
+	}
+	return digest(struct {
+		Format                          int
+		Payload, Label, Description     string
+		Symbology, Category, Extra      string
+		ExtraCaption, Mark, Number      string
+		Continued                       string
+		Icon                            string
+		Accent, MarkColor               []uint32
+		Background, Text, Border, Plate []uint32
+		BorderWidth                     float64
+		InvertCodes                     bool
+		Fonts                           Fonts
+		CellStyle                       string
+		DescriptionColumns              int
+		DescriptionLineSpacing          float64
+		DescriptionAlign                string
+		DescriptionMaxLines             int
+		Hyphenate                       bool
+		Language                        string
+		IntegerModules, PixelSnap       bool
+		Stock                           *Stock
+		TextScale, IconSize, X, Y, W, H float64
+	}{
+		Format:  cacheFormat,
+		Payload: cell.Payload, Label: cell.Label, Description: cell.Description,
+		Symbology: cell.Symbology, Category: cell.Category, Extra: cell.Extra,
+		ExtraCaption: cell.ExtraCaption, Mark: cell.Mark, Number: cell.Number,
+		Continued: continued,
+		Icon:      imageDigest(cell.Icon),
+		Accent:    rgba(cell.Accent), MarkColor: rgba(cell.MarkColor),
+		Background: rgba(opts.Theme.Background), Text: rgba(opts.Theme.Text),
+		Border: rgba(opts.Theme.Border), Plate: rgba(opts.Theme.Plate),
+		BorderWidth:        opts.Theme.BorderWidth,
+		InvertCodes:        opts.Theme.InvertCodes,
+		Fonts:              opts.Fonts,
+		CellStyle:          opts.CellStyle,
+		DescriptionColumns: opts.DescriptionColumns, DescriptionLineSpacing: opts.DescriptionLineSpacing,
+		DescriptionAlign: opts.DescriptionAlign, DescriptionMaxLines: opts.DescriptionMaxLines,
+		Hyphenate: opts.Hyphenate, Language: opts.Language,
+		IntegerModules: opts.IntegerModules, PixelSnap: opts.PixelSnap,
+		Stock:     opts.Stock,
+		TextScale: opts.TextScale, IconSize: float64(opts.IconSize()),
+		X: p.x - math.Floor(p.x), Y: p.y - math.Floor(p.y), W: p.width, H: p.height,
+	})
+}
+
+// pageKey returns the cache key of page of pages: its cells, where they
+// are and everything else on the page.
+func pageKey(opts Options, pages [][]Cell, page int) string {
+	var cells []string
+	for _, p := range placeCells(opts, pages, page) {
+		cells = append(cells, cellKey(opts, p), digest([]float64{math.Floor(p.x), math.Floor(p.y)}))
+	}
+	chrome := opts
+	chrome.Logo, chrome.Background, chrome.Cache = nil, nil, nil
+	chrome.Theme.Background, chrome.Theme.Text, chrome.Theme.Border, chrome.Theme.Plate = nil, nil, nil, nil
+	return digest(struct {
+		Format                          int
+		Options                         Options
+		Logo, Backdrop                  string
+		Background, Text, Border, Plate []uint32
+		PageNumber                      string
+		Cells                           []string
+	}{
+		Format:     cacheFormat,
+		Options:    chrome,
+		Logo:       imageDigest(opts.Logo),
+		Backdrop:   imageDigest(opts.Background),
+		Background: rgba(opts.Theme.Background), Text: rgba(opts.Theme.Text),
+		Border: rgba(opts.Theme.Border), Plate: rgba(opts.Theme.Plate),
+		PageNumber: pageNumber(opts, pages, page),
+		Cells:      cells,
+	})
+}
+
+// digest returns the hex SHA-256 of v as JSON.
+func digest(v any) string {
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// rgba returns the components of c, nil if unset.
+func rgba(c color.Color) []uint32 {
+	if c == nil {
+		return nil
+	}
+	r, g, b, a := c.RGBA()
+	return []uint32{r, g, b, a}
+}
+
+// imageDigest returns a digest of img's pixels, empty if nil.
+func imageDigest(img image.Image) string {
+	if img == nil {
+		return ""
+	}
+	h := sha256.New()
+	bounds := img.Bounds()
+	json.NewEncoder(h).Encode([]int{bounds.Dx(), bounds.Dy()})
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			h.Write([]byte{byte(r >> 8), byte(g >> 8), byte(b >> 8), byte(a >> 8)})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,153 @@
+package sheet
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/fogleman/gg"
+)
+
+// CalibrationCode is a code on the calibration page, see RenderCalibration.
+type CalibrationCode struct {
+	Payload   string
+	Symbology string
+	Level     string  // QR error correction level, L, M, Q or H
+	ModuleMM  float64 // module (or narrowest bar) width as printed
+}
+
+// calibrationModules are the QR module widths tried on the calibration page
+// and the bar widths of its Code 128, in millimetres, rounded to whole
+// pixels at the page's resolution.
+var (
+	calibrationModules = []float64{0.1, 0.15, 0.2, 0.25, 0.3, 0.4, 0.5, 0.6, 0.8}
+	calibrationBars    = []float64{0.17, 0.25, 0.35, 0.5}
+)
+
+// CalibrationCodes returns the codes on the calibration page at opts'
+// resolution: QR codes at every module width and error correction level,
+// then Code 128 at a few bar widths, each payload naming its code
+// so a scan shows which one was read. Codes are payloads like the built-in
+// messages', so their versions are like a sheet's.
+func CalibrationCodes(opts Options) []CalibrationCode {
+	var codes []CalibrationCode
+	for _, mm := range pixelWidths(calibrationModules, opts.DPI) {
+		for _, level := range []string{"L", "M", "Q", "H"} {
+			codes = append(codes, CalibrationCode{
+				Payload:   fmt.Sprintf("Calibration QR %.2f mm level %s: the quick brown fox jumps over the lazy dog.", mm, level),
+				Symbology: QR, Level: level, ModuleMM: mm,
+			})
+		}
+	}
+	for _, mm := range pixelWidths(calibrationBars, opts.DPI) {
+		codes = append(codes, CalibrationCode{Payload: fmt.Sprintf("CAL-128-%.2fMM", mm), Symbology: Code128, ModuleMM: mm})
+	}
+	return codes
+}
+
+// pixelWidths returns widths in millimetres rounded to whole pixels at
+// dpi, leaving out repeats.
+func pixelWidths(widths []float64, dpi float64) []float64 {
+	var out []float64
+	seen := map[int]bool{}
+	for _, mm := range widths {
+		px := max(1, int(math.Round(mm*dpi/25.4)))
+		if !seen[px] {
+			seen[px] = true
+			out = append(out, float64(px)*25.4/dpi)
+		}
+	}
+	return out
+}
+
+// encode renders the code with its modules exactly ModuleMM wide at dpi.
+func (c CalibrationCode) encode(dpi float64) (image.Image, error) {
+	px := int(math.Round(c.ModuleMM * dpi / 25.4))
+	var raw barcode.Barcode
+	var err error
+	if c.Symbology == Code128 {
+		raw, err = code128.Encode(c.Payload)
+	} else {
+		level := map[string]qr.ErrorCorrectionLevel{"L": qr.L, "M": qr.M, "Q": qr.Q, "H": qr.H}[c.Level]
+		raw, err = qr.Encode(c.Payload, level, qr.Auto)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encoding %q: %v", c.Payload, err)
+	}
+	b := raw.Bounds()
+	height := b.Dy() * px
+	if c.Symbology == Code128 {
+		// Tall enough to aim at, as on a sheet.
+		height = int(8 * dpi / 25.4)
+	}
+	return barcode.Scale(raw, b.Dx()*px, height)
+}
+
+// RenderCalibration renders a test page of CalibrationCodes, QR codes in a
+// row per module width with a column per error correction level and Code
+// 128 under them, each labelled with its size, for finding the smallest
+// codes a scanner reads before laying out a sheet. Codes are dark on light
+// whatever the theme.
+func RenderCalibration(opts Options) (image.Image, error) {
+	renderMu.Lock()
+	defer renderMu.Unlock()
+	if err := opts.Fonts.load(); err != nil {
+		return nil, err
+	}
+	width := int(opts.WidthInches * opts.DPI)
+	height := int(opts.HeightInches * opts.DPI)
+	ts := opts.textScale()
+	margin := opts.Margin
+
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+	dc.SetRGB(0, 0, 0)
+	dc.SetFontFace(opts.Fonts.title(24 * ts))
+	drawString(dc, "Scanner calibration", float64(width)/2, margin/2, 0.5, 0.5)
+	dc.SetFontFace(opts.Fonts.description(12 * ts))
+	drawString(dc, "Scan each code: the smallest that reads every time is the smallest module width your scanner can use.", float64(width)/2, margin/2+30*ts, 0.5, 0.5)
+
+	codes := CalibrationCodes(opts)
+	columns := 4
+	colWidth := (float64(width) - 2*margin) / float64(columns)
+	y := margin + 20*ts
+	for i := 0; i < len(codes); {
+		// A row of QR codes of one module width, or a Code 128.
+		row := codes[i : i+1]
+		if codes[i].Symbology == QR {
+			row = codes[i : i+columns]
+		}
+		i += len(row)
+		imgs := make([]image.Image, len(row))
+		rowHeight := 0
+		for j, c := range row {
+			img, err := c.encode(opts.DPI)
+			if err != nil {
+				return nil, err
+			}
+			imgs[j] = img
+			rowHeight = max(rowHeight, img.Bounds().Dy())
+		}
+		for j, c := range row {
+			x := margin + colWidth*float64(j)
+			cx := x + colWidth/2
+			w := float64(imgs[j].Bounds().Dx())
+			if c.Symbology == Code128 {
+				cx = float64(width) / 2
+			}
+			dc.DrawImage(imgs[j], int(cx-w/2), int(y))
+			label := fmt.Sprintf("QR %.2f mm, level %s", c.ModuleMM, c.Level)
+			if c.Symbology == Code128 {
+				label = fmt.Sprintf("Code 128, %.2f mm bars", c.ModuleMM)
+			}
+			dc.SetFontFace(opts.Fonts.label(11 * ts))
+			drawString(dc, label, cx, y+float64(rowHeight)+16*ts, 0.5, 0)
+		}
+		y += float64(rowHeight) + 36*ts
+	}
+	return dc.Image(), nil
+}
@@ -0,0 +1,67 @@
+package sheet
+
+import (
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// crispFace draws a face's glyphs without anti-aliasing, every pixel of
+// them ink or paper, if aliased, and starting on whole pixels if whole.
+// Measurements are the face's, so text lays out as it would anti-aliased.
+type crispFace struct {
+	font.Face
+	aliased, whole bool
+}
+
+func (f crispFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	if f.whole {
+		dot = fixed.P(dot.X.Round(), dot.Y.Round())
+	}
+	dr, mask, maskp, advance, ok := f.Face.Glyph(dot, r)
+	if !ok || !f.aliased || mask == nil {
+		return dr, mask, maskp, advance, ok
+	}
+	// The face's mask is only good until its next glyph, so threshold
+	// into a copy.
+	out := image.NewAlpha(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	for y := range dr.Dy() {
+		for x := range dr.Dx() {
+			if _, _, _, a := mask.At(maskp.X+x, maskp.Y+y).RGBA(); a >= 0x8000 {
+				out.Pix[y*out.Stride+x] = 0xff
+			}
+		}
+	}
+	return dr, out, image.Point{}, advance, true
+}
+
+// snapped returns the rectangle x, y, width, height on whole pixels if
+// PixelSnap is set, each edge rounded to the nearest.
+func (o Options) snapped(x, y, width, height float64) (float64, float64, float64, float64) {
+	if !o.PixelSnap {
+		return x, y, width, height
+	}
+	left, top := math.Round(x), math.Round(y)
+	return left, top, math.Round(x+width) - left, math.Round(y+height) - top
+}
+
+// strokeRectangle outlines the rectangle x, y, width, height with lines
+// lineWidth wide. With PixelSnap lines are a whole number of pixels wide,
+// at least one, and fill whole pixels rather than straddling them, the
+// extra pixel of odd widths falling above and left of each edge so cells
+// side by side share the pixels of their borders.
+func strokeRectangle(dc *gg.Context, opts Options, x, y, width, height, lineWidth float64) {
+	if opts.PixelSnap {
+		lineWidth = max(1, math.Round(lineWidth))
+		x, y, width, height = opts.snapped(x, y, width, height)
+		if int(lineWidth)%2 == 1 {
+			x, y = x-0.5, y-0.5
+		}
+	}
+	dc.SetLineWidth(lineWidth)
+	dc.DrawRectangle(x, y, width, height)
+	dc.Stroke()
+}
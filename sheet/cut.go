@@ -0,0 +1,140 @@
+package sheet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cut is the outline of a cell for a cutting machine, in millimetres from
+// the top left corner of its page.
+type Cut struct {
+	X, Y, Width, Height float64
+	Round               bool // a circle Width across, for a sticker of Options.Stock
+	Cell                int  // index of the cell in the sheet's cells
+}
+
+// CutLines returns the outlines of the cells on each page SavePNG would
+// write, where they are drawn: their grid cells, or their stickers.
+func CutLines(opts Options, cells []Cell) [][]Cut {
+	pages := paginate(opts, cells)
+	mm := 25.4 / opts.DPI
+	cuts := make([][]Cut, len(pages))
+	first := 0
+	for i := range pages {
+		for j, p := range placeCells(opts, pages, i) {
+			cuts[i] = append(cuts[i], Cut{
+				X: p.x * mm, Y: p.y * mm, Width: p.width * mm, Height: p.height * mm,
+				Round: opts.Stock != nil, Cell: first + j,
+			})
+		}
+		first += len(pages[i])
+	}
+	return cuts
+}
+
+// SaveCutLines writes the CutLines of cells to path, as SVG or DXF by its
+// extension, for importing into the software of cutting machines to cut
+// out printed cards and stickers. Pages are named as by SavePNG. It
+// returns the files written.
+func SaveCutLines(path string, opts Options, cells []Cell) ([]string, error) {
+	ext := filepath.Ext(path)
+	var write func(io.Writer, Options, []Cut) error
+	switch strings.ToLower(ext) {
+	case ".svg":
+		write = writeCutSVG
+	case ".dxf":
+		write = writeCutDXF
+	default:
+		return nil, fmt.Errorf("cut lines %s: want a .svg or .dxf file", path)
+	}
+	pages := CutLines(opts, cells)
+	var paths []string
+	for i, cuts := range pages {
+		name := path
+		if len(pages) > 1 {
+			name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), i+1, ext)
+		}
+		f, err := os.Create(name)
+		if err != nil {
+			return paths, err
+		}
+		w := bufio.NewWriter(f)
+		err = write(w, opts, cuts)
+		if err == nil {
+			err = w.Flush()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, name)
+	}
+	return paths, nil
+}
+
+// writeCutSVG writes cuts as hairline red outlines on an SVG the size of
+// the page, which cutter software reads at its real size.
+func writeCutSVG(w io.Writer, opts Options, cuts []Cut) error {
+	width, height := opts.WidthInches*25.4, opts.HeightInches*25.4
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%.3fmm" height="%.3fmm" viewBox="0 0 %.3f %.3f">`+"\n", width, height, width, height)
+	fmt.Fprintln(w, `<g fill="none" stroke="#ff0000" stroke-width="0.1">`)
+	for _, c := range cuts {
+		if c.Round {
+			fmt.Fprintf(w, `<circle id="cell-%d" cx="%.3f" cy="%.3f" r="%.3f"/>`+"\n", c.Cell+1, c.X+c.Width/2, c.Y+c.Height/2, c.Width/2)
+			continue
+		}
+		fmt.Fprintf(w, `<rect id="cell-%d" x="%.3f" y="%.3f" width="%.3f" height="%.3f"/>`+"\n", c.Cell+1, c.X, c.Y, c.Width, c.Height)
+	}
+	_, err := fmt.Fprintln(w, "</g>\n</svg>")
+	return err
+}
+
+// writeCutDXF writes cuts as an AutoCAD R12 DXF in millimetres, circles
+// and closed polylines, with y up from the bottom of the page as DXF has
+// it.
+func writeCutDXF(w io.Writer, opts Options, cuts []Cut) error {
+	height := opts.HeightInches * 25.4
+	group := func(code int, value any) {
+		if f, ok := value.(float64); ok {
+			value = fmt.Sprintf("%.3f", f)
+		}
+		fmt.Fprintf(w, "%d\n%v\n", code, value)
+	}
+	group(0, "SECTION")
+	group(2, "HEADER")
+	group(9, "$INSUNITS")
+	group(70, 4) // millimetres
+	group(0, "ENDSEC")
+	group(0, "SECTION")
+	group(2, "ENTITIES")
+	for _, c := range cuts {
+		if c.Round {
+			group(0, "CIRCLE")
+			group(8, "CUT")
+			group(10, c.X+c.Width/2)
+			group(20, height-c.Y-c.Height/2)
+			group(40, c.Width/2)
+			continue
+		}
+		group(0, "POLYLINE")
+		group(8, "CUT")
+		group(66, 1)
+		group(70, 1) // closed
+		for _, p := range [][2]float64{{c.X, c.Y}, {c.X + c.Width, c.Y}, {c.X + c.Width, c.Y + c.Height}, {c.X, c.Y + c.Height}} {
+			group(0, "VERTEX")
+			group(8, "CUT")
+			group(10, p[0])
+			group(20, height-p[1])
+		}
+		group(0, "SEQEND")
+	}
+	group(0, "ENDSEC")
+	group(0, "EOF")
+	return nil
+}
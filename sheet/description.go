@@ -0,0 +1,136 @@
+package sheet
+
+import (
+	"math"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// Description alignments understood by Options.DescriptionAlign.
+const (
+	DescriptionLeft    = "left"
+	DescriptionCenter  = "center"
+	DescriptionRight   = "right"
+	DescriptionJustify = "justify" // flush with both edges, but for the last line of a paragraph
+)
+
+func (o Options) descriptionSpacing() float64 {
+	if o.DescriptionLineSpacing > 0 {
+		return o.DescriptionLineSpacing
+	}
+	return 1.3
+}
+
+// descriptionLine is a wrapped line of a description, last if it ends its
+// paragraph.
+type descriptionLine struct {
+	text string
+	last bool
+}
+
+// descriptionColumns wraps s into the columns of a description width wide,
+// filling each before the next, and returns them with their width. Short
+// descriptions use fewer columns, and ones past DescriptionMaxLines lines a
+// column end in an ellipsis.
+func descriptionColumns(dc *gg.Context, opts Options, s string, width float64) ([][]descriptionLine, float64) {
+	n := max(1, opts.DescriptionColumns)
+	gap := 8 * opts.textScale()
+	colWidth := (width - gap*float64(n-1)) / float64(n)
+	var lines []descriptionLine
+	for para := range strings.SplitSeq(s, "\n") {
+		wrapped := dc.WordWrap(para, colWidth)
+		if opts.Hyphenate {
+			wrapped = hyphenatedWrap(dc, para, colWidth, opts.Language)
+		}
+		for i, line := range wrapped {
+			lines = append(lines, descriptionLine{line, i == len(wrapped)-1})
+		}
+	}
+	if limit := opts.DescriptionMaxLines * n; limit > 0 && len(lines) > limit {
+		lines = lines[:limit]
+		lines[limit-1] = descriptionLine{ellipsize(dc, lines[limit-1].text, colWidth), true}
+	}
+	perColumn := max(1, int(math.Ceil(float64(len(lines))/float64(n))))
+	var cols [][]descriptionLine
+	for len(lines) > 0 {
+		k := min(perColumn, len(lines))
+		cols = append(cols, lines[:k])
+		lines = lines[k:]
+	}
+	return cols, colWidth
+}
+
+// descriptionHeight returns the height of s drawn by drawDescription.
+func descriptionHeight(dc *gg.Context, opts Options, s string, width float64) float64 {
+	cols, _ := descriptionColumns(dc, opts, s, width)
+	if len(cols) == 0 {
+		return 0
+	}
+	spacing := opts.descriptionSpacing()
+	return (float64(len(cols[0]))*spacing - (spacing - 1)) * dc.FontHeight()
+}
+
+// drawDescription draws s in a box width wide with its top left at (x, y),
+// in the columns, line spacing and alignment of opts, or align if opts
+// doesn't pick one. Right-to-left text isn't justified.
+func drawDescription(dc *gg.Context, opts Options, s string, x, y, width float64, align gg.Align) {
+	if s == "" {
+		return
+	}
+	justify := false
+	switch opts.DescriptionAlign {
+	case DescriptionLeft:
+		align = gg.AlignLeft
+	case DescriptionCenter:
+		align = gg.AlignCenter
+	case DescriptionRight:
+		align = gg.AlignRight
+	case DescriptionJustify:
+		align, justify = gg.AlignLeft, !hasRTL(s)
+	}
+	spacing := opts.descriptionSpacing()
+	cols, colWidth := descriptionColumns(dc, opts, s, width)
+	gap := 8 * opts.textScale()
+	for i, col := range cols {
+		cx := x + float64(i)*(colWidth+gap)
+		if !justify {
+			text := make([]string, len(col))
+			for j, line := range col {
+				text[j] = line.text
+			}
+			drawWrapped(dc, strings.Join(text, "\n"), cx, y, 0, 0, colWidth, spacing, align)
+			continue
+		}
+		for j, line := range col {
+			drawJustified(dc, line, cx, y+float64(j)*dc.FontHeight()*spacing, colWidth)
+		}
+	}
+}
+
+// drawJustified draws line with its words spread to fill width, hanging
+// from y, unless it ends its paragraph or is a single word.
+func drawJustified(dc *gg.Context, line descriptionLine, x, y, width float64) {
+	words := strings.Fields(line.text)
+	if line.last || len(words) < 2 {
+		drawString(dc, line.text, x, y, 0, 1)
+		return
+	}
+	total := 0.0
+	for _, w := range words {
+		tw, _ := dc.MeasureString(w)
+		total += tw
+	}
+	space := (width - total) / float64(len(words)-1)
+	// Lines far short of the width, e.g. before a long word, look better
+	// left as they are than with gaping spaces.
+	if sw, _ := dc.MeasureString(" "); space > 4*sw {
+		drawString(dc, line.text, x, y, 0, 1)
+		return
+	}
+	for _, w := range words {
+		drawString(dc, w, x, y, 0, 1)
+		tw, _ := dc.MeasureString(w)
+		x += tw + space
+	}
+}
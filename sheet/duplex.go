@@ -0,0 +1,74 @@
+package sheet
+
+import "fmt"
+
+// Duplex printing, see Options.Duplex: pages flipped over their long edge,
+// as most printers do, or their short edge.
+const (
+	DuplexLong  = "long"
+	DuplexShort = "short"
+)
+
+// mirror moves the slots of a back page's cells to the other side of its
+// grid of rows rows, so each is behind the cell in the same place on the
+// front once the paper is turned over.
+func mirror(opts Options, slots []slot, rows int) {
+	for i, s := range slots {
+		if opts.Duplex == DuplexShort {
+			slots[i].row = rows - s.row - s.span
+		} else {
+			slots[i].col = opts.Columns - s.col - s.span
+		}
+	}
+}
+
+// DuplexOrder returns the order to print cells in with Options.Duplex, as
+// indices into cells: the first page of front cells, the first page of
+// Back cells to print on the other side of it, and so on; and the number
+// of rows both sides of every page have, so that their grids line up. It
+// fails if the two sides take different numbers of pages.
+func DuplexOrder(opts Options, cells []Cell) ([]int, int, error) {
+	var sides [2][]int
+	for i, c := range cells {
+		if c.Back {
+			sides[1] = append(sides[1], i)
+		} else {
+			sides[0] = append(sides[0], i)
+		}
+	}
+	var pages [2][][]int
+	for side, indices := range sides {
+		cs := make([]Cell, len(indices))
+		for j, i := range indices {
+			cs[j] = cells[i]
+		}
+		starts := PageStarts(opts, cs)
+		for k, start := range starts {
+			end := len(indices)
+			if k+1 < len(starts) {
+				end = starts[k+1]
+			}
+			pages[side] = append(pages[side], indices[start:end])
+		}
+	}
+	if len(sides[0]) == 0 || len(sides[1]) == 0 {
+		return nil, 0, fmt.Errorf("duplex printing needs cells for both the front and the back")
+	}
+	if len(pages[0]) != len(pages[1]) {
+		return nil, 0, fmt.Errorf("the front takes %d pages and the back %d; duplex printing needs as many of each", len(pages[0]), len(pages[1]))
+	}
+	var order []int
+	rows := opts.Rows
+	for k := range pages[0] {
+		for side := range pages {
+			page := make([]Cell, len(pages[side][k]))
+			for j, i := range pages[side][k] {
+				page[j] = cells[i]
+			}
+			_, r := place(opts, page)
+			rows = max(rows, r)
+			order = append(order, pages[side][k]...)
+		}
+	}
+	return order, rows, nil
+}
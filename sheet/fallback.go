@@ -0,0 +1,73 @@
+package sheet
+
+import (
+	"image"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// fallbackFace draws each rune with the first of its fonts that has a glyph
+// for it, so that e.g. emoji missing from the text font come from an emoji
+// font. Runes no font has are drawn with the first font (usually as a
+// missing-glyph box).
+//
+// Glyphs are only substituted one rune at a time: there is no shaping, so
+// ZWJ sequences such as 👩‍💻 come out as their separate emoji.
+type fallbackFace struct {
+	fonts []*opentype.Font
+	faces []font.Face
+	buf   sfnt.Buffer
+}
+
+// pick returns the face to draw r with, or nil if r is an invisible
+// formatting character (variation selector, zero width joiner) no font has.
+func (f *fallbackFace) pick(r rune) font.Face {
+	for i, fnt := range f.fonts {
+		if x, err := fnt.GlyphIndex(&f.buf, r); err == nil && x != 0 {
+			return f.faces[i]
+		}
+	}
+	if unicode.Is(unicode.Variation_Selector, r) || unicode.In(r, unicode.Join_Control) {
+		return nil
+	}
+	return f.faces[0]
+}
+
+func (f *fallbackFace) Close() error { return nil }
+
+func (f *fallbackFace) Metrics() font.Metrics { return f.faces[0].Metrics() }
+
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if face := f.pick(r0); face != nil && face == f.pick(r1) {
+		return face.Kern(r0, r1)
+	}
+	return 0
+}
+
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	face := f.pick(r)
+	if face == nil {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+	return face.Glyph(dot, r)
+}
+
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	face := f.pick(r)
+	if face == nil {
+		return fixed.Rectangle26_6{}, 0, false
+	}
+	return face.GlyphBounds(r)
+}
+
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	face := f.pick(r)
+	if face == nil {
+		return 0, false
+	}
+	return face.GlyphAdvance(r)
+}
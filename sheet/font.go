@@ -0,0 +1,259 @@
+package sheet
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Fonts are the TrueType/OpenType font files used for each kind of text on
+// the sheet. Empty paths use the embedded Go Regular. Collections (.ttc)
+// use their first font, or the one numbered N with a path#N suffix.
+type Fonts struct {
+	Title       string // title, subtitle
+	Label       string // cell labels
+	Description string // descriptions, captions and the footer
+
+	// Fallbacks are tried in order for characters missing from the fonts
+	// above, e.g. CJK or Thai fonts. See FallbackFonts.
+	Fallbacks []string
+
+	// Emoji is a monochrome emoji font emoji missing from the fonts above
+	// are drawn with, none if empty. See EmojiFont.
+	Emoji string
+
+	// Aliased draws text without anti-aliasing, for thermal printers that
+	// print grey edges as ragged dots. WholePixels starts each glyph on a
+	// whole pixel rather than wherever it falls between two.
+	Aliased     bool
+	WholePixels bool
+}
+
+func (f Fonts) title(size float64) font.Face       { return f.face(f.chain(f.Title), size) }
+func (f Fonts) label(size float64) font.Face       { return f.face(f.chain(f.Label), size) }
+func (f Fonts) description(size float64) font.Face { return f.face(f.chain(f.Description), size) }
+
+// face is face, drawing Aliased or on WholePixels if set.
+func (f Fonts) face(chain []string, size float64) font.Face {
+	if !f.Aliased && !f.WholePixels {
+		return face(chain, size)
+	}
+	return crispFace{face(chain, size), f.Aliased, f.WholePixels}
+}
+
+// chain returns the fonts text set in the font at path is drawn with, in
+// order of preference.
+func (f Fonts) chain(path string) []string {
+	chain := append([]string{path}, f.Fallbacks...)
+	if f.Emoji != "" {
+		chain = append(chain, f.Emoji)
+	}
+	return chain
+}
+
+// load parses every font so that bad paths are reported before rendering
+// starts.
+func (f Fonts) load() error {
+	for _, path := range append([]string{f.Title, f.Label, f.Description}, f.Fallbacks...) {
+		if _, err := parseFont(path); err != nil {
+			return err
+		}
+	}
+	if f.Emoji != "" {
+		fnt, err := parseFont(f.Emoji)
+		if err != nil {
+			return err
+		}
+		if !hasEmojiOutlines(fnt) {
+			return fmt.Errorf("%s has no emoji outlines; color bitmap emoji fonts can't be drawn, use a monochrome one such as Noto Emoji", f.Emoji)
+		}
+	}
+	return nil
+}
+
+// fallbackFontGlobs are where FallbackFonts looks for fonts, one per group
+// of scripts Go Regular doesn't cover (it has Latin, Greek and Cyrillic).
+var fallbackFontGlobs = [][]string{
+	{ // Chinese, Japanese, Korean
+		"/usr/share/fonts/*/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/*/*/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/*/*/NotoSansCJK*-Regular.otf",
+		"/usr/share/fonts/*/*/DroidSansFallbackFull.ttf",
+		"/System/Library/Fonts/PingFang.ttc",
+		"/System/Library/Fonts/Hiragino Sans GB.ttc",
+		`C:\Windows\Fonts\msyh.ttc`,
+		`C:\Windows\Fonts\msgothic.ttc`,
+	},
+	{ // Korean, if the CJK font above lacks Hangul
+		"/usr/share/fonts/*/*/NotoSansKR-Regular.otf",
+		"/System/Library/Fonts/AppleSDGothicNeo.ttc",
+		`C:\Windows\Fonts\malgun.ttf`,
+	},
+	{ // Thai
+		"/usr/share/fonts/*/*/NotoSansThai-Regular.ttf",
+		"/usr/share/fonts/*/*/NotoSansThai*.ttf",
+		"/System/Library/Fonts/Supplemental/Thonburi.ttc",
+		`C:\Windows\Fonts\leelawui.ttf`,
+	},
+	{ // Arabic
+		"/usr/share/fonts/*/*/NotoSansArabic-Regular.ttf",
+		"/usr/share/fonts/*/*/NotoNaskhArabic-Regular.ttf",
+		`C:\Windows\Fonts\tahoma.ttf`,
+	},
+	{ // Hebrew
+		"/usr/share/fonts/*/*/NotoSansHebrew-Regular.ttf",
+		`C:\Windows\Fonts\arial.ttf`,
+	},
+	{ // Devanagari
+		"/usr/share/fonts/*/*/NotoSansDevanagari-Regular.ttf",
+		`C:\Windows\Fonts\Nirmala.ttf`,
+	},
+	{ // everything else Noto Sans covers (Vietnamese, extended Latin, ...)
+		"/usr/share/fonts/*/*/NotoSans-Regular.ttf",
+		"/usr/share/fonts/*/*/DejaVuSans.ttf",
+	},
+}
+
+// FallbackFonts returns the installed fonts found from fallbackFontGlobs,
+// at most one per script group.
+func FallbackFonts() []string {
+	var found []string
+	for _, globs := range fallbackFontGlobs {
+		if path := firstFont(globs, nil); path != "" {
+			found = append(found, path)
+		}
+	}
+	return found
+}
+
+// firstFont returns the first font matching one of globs that parses and
+// that ok, if not nil, accepts.
+func firstFont(globs []string, ok func(*opentype.Font) bool) string {
+	for _, pattern := range globs {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			if fnt, err := parseFont(path); err == nil && (ok == nil || ok(fnt)) {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// emojiFontGlobs are where EmojiFont looks for monochrome emoji fonts.
+// Color emoji fonts (Noto Color Emoji, Apple Color Emoji) store bitmaps
+// rather than outlines and can't be drawn.
+var emojiFontGlobs = []string{
+	"/usr/share/fonts/*/NotoEmoji*.ttf",
+	"/usr/share/fonts/*/*/NotoEmoji*.ttf",
+	"/usr/share/fonts/*/Symbola*.ttf",
+	"/usr/share/fonts/*/*/Symbola*.ttf",
+	"/usr/local/share/fonts/NotoEmoji*.ttf",
+	filepath.Join(os.Getenv("HOME"), ".local/share/fonts/NotoEmoji*.ttf"),
+	`C:\Windows\Fonts\seguiemj.ttf`,
+	`C:\Windows\Fonts\seguisym.ttf`,
+}
+
+// EmojiFont returns the first installed monochrome emoji font, or "" if
+// there is none.
+func EmojiFont() string {
+	return firstFont(emojiFontGlobs, hasEmojiOutlines)
+}
+
+// hasEmojiOutlines reports whether fnt has an outline glyph for 😀.
+func hasEmojiOutlines(fnt *opentype.Font) bool {
+	var buf sfnt.Buffer
+	x, err := fnt.GlyphIndex(&buf, '😀')
+	if err != nil || x == 0 {
+		return false
+	}
+	segments, err := fnt.LoadGlyph(&buf, x, fixed.I(16), nil)
+	return err == nil && len(segments) > 0
+}
+
+// parsed fonts by path, "" being Go Regular.
+var parsedFonts = map[string]*opentype.Font{}
+
+func parseFont(path string) (*opentype.Font, error) {
+	if fnt, ok := parsedFonts[path]; ok {
+		return fnt, nil
+	}
+	data, index := goregular.TTF, 0
+	if path != "" {
+		file := path
+		if i := strings.LastIndexByte(path, '#'); i >= 0 {
+			if n, err := strconv.Atoi(path[i+1:]); err == nil {
+				file, index = path[:i], n
+			}
+		}
+		var err error
+		if data, err = os.ReadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	c, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing font %s: %w", path, err)
+	}
+	if index < 0 || index >= c.NumFonts() {
+		return nil, fmt.Errorf("font %s: collection has %d fonts", path, c.NumFonts())
+	}
+	fnt, err := c.Font(index)
+	if err != nil {
+		return nil, fmt.Errorf("parsing font %s: %w", path, err)
+	}
+	parsedFonts[path] = fnt
+	return fnt, nil
+}
+
+type faceKey struct {
+	chain string
+	size  float64
+}
+
+// font cache so we only create each face once per size.
+var fontCache = map[faceKey]font.Face{}
+
+// face returns a font.Face at the given size drawing with the fonts in
+// chain, see fallbackFace. The fonts must have been loaded already, see
+// Fonts.load.
+func face(chain []string, size float64) font.Face {
+	key := faceKey{strings.Join(chain, "\x00"), size}
+	if face, ok := fontCache[key]; ok {
+		return face
+	}
+
+	fb := &fallbackFace{}
+	for _, path := range chain {
+		fnt, err := parseFont(path)
+		if err != nil {
+			log.Fatalf("failed to parse font: %v", err)
+		}
+		face, err := opentype.NewFace(fnt, &opentype.FaceOptions{
+			Size:    size,
+			DPI:     72,
+			Hinting: font.HintingFull,
+		})
+		if err != nil {
+			log.Fatalf("failed to create font face (%s, size=%.1f): %v", path, size, err)
+		}
+		fb.fonts = append(fb.fonts, fnt)
+		fb.faces = append(fb.faces, face)
+	}
+
+	var face font.Face = fb
+	if len(fb.faces) == 1 {
+		face = fb.faces[0]
+	}
+	fontCache[key] = face
+	return face
+}
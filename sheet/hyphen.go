@@ -0,0 +1,140 @@
+package sheet
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/fogleman/gg"
+)
+
+// unspacedLanguages are written without spaces between words, so lines
+// may break between any two characters, without a hyphen.
+var unspacedLanguages = map[string]bool{"ja": true, "zh": true, "th": true, "lo": true, "km": true, "my": true}
+
+// hyphenatedWrap wraps s to lines at most width wide like gg's WordWrap,
+// but a word that doesn't fit is broken and hyphenated where it can be in
+// lang, see breakPoints, rather than left to overflow its cell.
+func hyphenatedWrap(dc *gg.Context, s string, width float64, lang string) []string {
+	base, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	unspaced := unspacedLanguages[base]
+	fits := func(s string) bool {
+		w, _ := dc.MeasureString(s)
+		return w <= width
+	}
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for word != "" {
+			joined := word
+			if line != "" {
+				joined = line + " " + word
+			}
+			if fits(joined) {
+				line, word = joined, ""
+				break
+			}
+			// Take as much of the word as fits on this line, or on a
+			// line of its own.
+			head, rest := splitToFit(fits, line, word, unspaced)
+			if head == "" && line == "" {
+				// Not even the shortest piece fits: let it overflow
+				// rather than loop.
+				head, rest = word, ""
+			}
+			if head != "" {
+				if line != "" {
+					head = line + " " + head
+				}
+				lines, line, word = append(lines, head), "", rest
+				continue
+			}
+			lines, line = append(lines, line), ""
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitToFit returns the longest start of word, hyphenated, that fits
+// after line, and the rest of the word, or "" and word if no piece does.
+func splitToFit(fits func(string) bool, line, word string, unspaced bool) (head, rest string) {
+	r := []rune(word)
+	points := breakPoints(r, unspaced)
+	for i := len(points) - 1; i >= 0; i-- {
+		at := points[i]
+		head = string(r[:at])
+		if !unspaced && r[at-1] != '-' {
+			head += "-"
+		}
+		candidate := head
+		if line != "" {
+			candidate = line + " " + head
+		}
+		if fits(candidate) {
+			return head, string(r[at:])
+		}
+	}
+	return "", word
+}
+
+// breakPoints returns where word may be broken, as rune offsets: anywhere
+// in unspaced languages; otherwise after its own hyphens and between
+// syllables, guessed from its vowels (be-fore a consonant between vowels,
+// between two consonants between vowels), keeping two letters either side.
+func breakPoints(word []rune, unspaced bool) []int {
+	var points []int
+	for i := 1; i < len(word); i++ {
+		if unspaced {
+			points = append(points, i)
+			continue
+		}
+		if word[i-1] == '-' {
+			points = append(points, i)
+			continue
+		}
+		if i < 2 || i > len(word)-2 || !letters(word[i-2:i+2]) {
+			continue
+		}
+		a, b, c := isVowel(word[i-1]), isVowel(word[i]), i+1 < len(word) && isVowel(word[i+1])
+		switch {
+		case a && !b && c: // vo-wel
+			points = append(points, i)
+		case !a && !b && c && i >= 2 && isVowel(word[i-2]): // syl-la-ble
+			points = append(points, i)
+		}
+	}
+	return points
+}
+
+func letters(rs []rune) bool {
+	for _, r := range rs {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVowel(r rune) bool {
+	return strings.ContainsRune("aeiouyàáâãäåæèéêëìíîïòóôõöøùúûüýÿœаеёиоуыэюяіїєαεηιουω", unicode.ToLower(r))
+}
+
+// ellipsize returns line with an ellipsis, shortened by words (or
+// characters, for a single long word) until it fits width.
+func ellipsize(dc *gg.Context, line string, width float64) string {
+	const ellipsis = "…"
+	line = strings.TrimRight(line, "-")
+	for {
+		if w, _ := dc.MeasureString(line + ellipsis); w <= width || line == "" {
+			return line + ellipsis
+		}
+		if i := strings.LastIndexByte(strings.TrimRight(line, " "), ' '); i > 0 {
+			line = strings.TrimRight(line[:i], " ,;:")
+		} else {
+			r := []rune(line)
+			line = string(r[:len(r)-1])
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package sheet
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/draw"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// IconSize returns the height in pixels of the icons drawn beside labels,
+// e.g. to rasterise SVG icons at.
+func (o Options) IconSize() int {
+	return int(12 * o.textScale())
+}
+
+// drawIconLabel draws label like the plain label, with its baseline at y
+// and x a fraction ax of the way across it, with icon before it: to the
+// left, or to the right of right-to-left labels.
+func drawIconLabel(dc *gg.Context, opts Options, icon image.Image, label string, text color.Color, x, y, ax float64) {
+	size := opts.IconSize()
+	img := scaleIcon(icon, size, text)
+	w := float64(img.Bounds().Dx())
+	gap := float64(size) / 3
+	lw, _ := dc.MeasureString(label)
+	left := x - ax*(w+gap+lw)
+	// Centre the icon on the lower case letters rather than the baseline.
+	top := int(y) - size + size/6
+	if baseDirection(label) == bidi.RightToLeft {
+		dc.DrawImage(img, int(left+lw+gap), top)
+		drawString(dc, label, left+lw/2, y, 0.5, 0)
+		return
+	}
+	dc.DrawImage(img, int(left), top)
+	drawString(dc, label, left+w+gap+lw/2, y, 0.5, 0)
+}
+
+// scaleIcon scales icon to height pixels high. Black icons, such as the
+// bundled ones, are drawn in the label colour instead so they stay visible
+// on dark themes and match category accents.
+func scaleIcon(icon image.Image, height int, text color.Color) image.Image {
+	b := icon.Bounds()
+	w := max(1, b.Dx()*height/b.Dy())
+	scaled := image.NewNRGBA(image.Rect(0, 0, w, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), icon, b, draw.Over, nil)
+	if !monochrome(scaled) {
+		return scaled
+	}
+	c := color.NRGBAModel.Convert(text).(color.NRGBA)
+	for i := 0; i < len(scaled.Pix); i += 4 {
+		a := scaled.Pix[i+3]
+		copy(scaled.Pix[i:i+4], []uint8{c.R, c.G, c.B, uint8(int(a) * int(c.A) / 255)})
+	}
+	return scaled
+}
+
+// monochrome reports whether every visible pixel of img is black.
+func monochrome(img *image.NRGBA) bool {
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] > 0 && (img.Pix[i] > 32 || img.Pix[i+1] > 32 || img.Pix[i+2] > 32) {
+			return false
+		}
+	}
+	return true
+}
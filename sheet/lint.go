@@ -0,0 +1,76 @@
+package sheet
+
+import (
+	"fmt"
+	"image"
+)
+
+// Thresholds Lint warns at.
+const (
+	minModuleMM    = 0.4 // smallest module phones read at arm's length
+	minModulePx    = 3   // fewer pixels per module blur on most printers
+	lintContrast   = 0.7 // symbol contrast grade A in ISO/IEC 15415
+	lintQuietZone  = 1   // modules; the standard asks for 4 but scanners cope with 1
+	maxLintVersion = 9   // larger versions are dense for their size
+)
+
+// CodeLint is the scannability of one code on a sheet.
+type CodeLint struct {
+	Cell    int // index into the cells, -1 for the footer
+	Payload string
+
+	ModulePx  float64
+	ModuleMM  float64
+	Contrast  float64 // 0 to 1
+	QuietZone float64 // modules of light space around the code, up to 4
+	Version   int
+	Level     byte // error correction level, L, M, Q or H
+
+	Warnings []string
+}
+
+// Lint measures every QR code on the pages SavePNG wrote to paths and warns
+// about ones that are likely hard to scan: small modules, low contrast,
+// dense codes for long payloads and missing quiet zones. Cells with linear
+// codes aren't checked.
+func Lint(paths []string, opts Options, cells []Cell) ([]CodeLint, error) {
+	var lints []CodeLint
+	err := eachCode(paths, opts, cells, func(path string, page *image.Gray, r image.Rectangle, i int, payload string) {
+		l := CodeLint{Cell: i, Payload: payload}
+		code, err := findCode(page, r, payload)
+		if err != nil {
+			l.Warnings = append(l.Warnings, err.Error())
+			lints = append(lints, l)
+			return
+		}
+		l.ModulePx = code.module
+		l.ModuleMM = code.module / opts.DPI * 25.4
+		l.Contrast = code.contrast
+		l.QuietZone = quiet(page, code, 4)
+		l.Version, l.Level = code.version, code.level
+		warn := func(format string, args ...any) {
+			l.Warnings = append(l.Warnings, fmt.Sprintf(format, args...))
+		}
+		if l.ModuleMM < minModuleMM {
+			warn("modules only %.2f mm at %g DPI, likely unscannable at arm's length", l.ModuleMM, opts.DPI)
+		}
+		if l.ModulePx < minModulePx {
+			warn("only %.1f pixels per module, likely to blur when printed", l.ModulePx)
+		}
+		if code.corrected > 0 {
+			warn("%d damaged codewords, something is drawn over the code", code.corrected)
+		}
+		if l.Contrast < lintContrast {
+			warn("contrast only %.0f%%, some scanners need %.0f%%", l.Contrast*100, lintContrast*100)
+		}
+		if l.Version > maxLintVersion {
+			warn("%d byte payload needs a dense version %d code (%d modules across) at EC level %c, shorten it or give codes more room",
+				len(payload), l.Version, l.Version*4+17, l.Level)
+		}
+		if l.QuietZone < lintQuietZone {
+			warn("quiet zone only %.1f modules, most scanners want at least %d", l.QuietZone, lintQuietZone)
+		}
+		lints = append(lints, l)
+	})
+	return lints, err
+}
@@ -0,0 +1,59 @@
+package sheet
+
+import (
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/draw"
+)
+
+// Logo alignments understood by Options.LogoAlign.
+const (
+	LogoLeft  = "left"
+	LogoRight = "right"
+	LogoTitle = "title" // immediately before the title
+)
+
+// LogoSize returns the height in pixels the logo is drawn at, e.g. to
+// rasterise an SVG logo at. Logos are kept within the top margin.
+func (o Options) LogoSize() int {
+	if o.LogoHeight > 0 {
+		return int(math.Min(o.LogoHeight, o.Margin))
+	}
+	return int(o.Margin * 0.6)
+}
+
+// logoSpace returns the width the logo takes up beside the title, 0 without
+// one.
+func logoSpace(opts Options) float64 {
+	if opts.Logo == nil {
+		return 0
+	}
+	b := opts.Logo.Bounds()
+	return float64(b.Dx())*float64(opts.LogoSize())/float64(b.Dy()) + opts.Margin/4
+}
+
+// drawLogo scales the logo to Options.LogoSize and draws it centred on the
+// title line, titleWidth being the width the title was drawn at.
+func drawLogo(dc *gg.Context, opts Options, titleWidth float64) {
+	b := opts.Logo.Bounds()
+	h := opts.LogoSize()
+	w := int(math.Round(float64(b.Dx()) * float64(h) / float64(b.Dy())))
+	if w == 0 || h == 0 {
+		return
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), opts.Logo, b, draw.Over, nil)
+
+	var x float64
+	switch opts.LogoAlign {
+	case LogoRight:
+		x = float64(dc.Width()) - opts.Margin - float64(w)
+	case LogoTitle:
+		x = float64(dc.Width())/2 - titleWidth/2 - opts.Margin/4 - float64(w)
+	default:
+		x = opts.Margin
+	}
+	dc.DrawImage(scaled, int(x), int(opts.Margin/2)-h/2)
+}
@@ -0,0 +1,194 @@
+package sheet
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// WritePDF renders cells and writes them to w as a PDF, a page of the
+// options' paper size for each page SavePNG would write, with bookmarks
+// for its pages and the categories on them.
+func WritePDF(w io.Writer, opts Options, cells []Cell) error {
+	pages, err := RenderPages(opts, cells)
+	if err != nil {
+		return err
+	}
+	return writePDF(w, pages, opts.WidthInches*72, opts.paperHeight()*72, pdfOutline(opts, cells))
+}
+
+// pdfBookmark is an entry of a PDF's outline, going to Top points up page
+// Page, with the entries under it.
+type pdfBookmark struct {
+	Title     string
+	Page      int
+	Top       float64
+	Bookmarks []pdfBookmark
+}
+
+// pdfOutline returns the bookmarks of a PDF of cells: a bookmark for each
+// page, with one for each category starting on the page going to its first
+// cell, or just the categories' if the sheet is one page.
+func pdfOutline(opts Options, cells []Cell) []pdfBookmark {
+	pages := paginate(opts, cells)
+	height := opts.paperHeight() * 72
+	// The front of a tent is the bottom half of the paper.
+	front := (opts.paperHeight() - opts.HeightInches) * opts.DPI
+	var outline []pdfBookmark
+	for i := range pages {
+		page := pdfBookmark{
+			Title: Expand(opts.Strings.Page, "Page {page} of {pages}", "page", strconv.Itoa(i+1), "pages", strconv.Itoa(len(pages))),
+			Page:  i, Top: height,
+		}
+		placed := placeCells(opts, pages, i)
+		for j, p := range placed {
+			if p.cell.Category == "" || j > 0 && p.cell.Category == placed[j-1].cell.Category {
+				continue
+			}
+			title := p.cell.Category
+			if p.cell.continued {
+				title = opts.Strings.continued(title)
+			}
+			page.Bookmarks = append(page.Bookmarks, pdfBookmark{Title: title, Page: i, Top: height - (front+p.y)*72/opts.DPI})
+		}
+		outline = append(outline, page)
+	}
+	if len(outline) == 1 {
+		return outline[0].Bookmarks
+	}
+	return outline
+}
+
+// pdfWriter writes the numbered objects of a PDF file, recording their
+// offsets for the cross-reference table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int // by object number - 1
+}
+
+// object writes object n with the given dictionary, followed by stream if
+// it isn't nil.
+func (p *pdfWriter) object(n int, dict string, stream []byte) {
+	for len(p.offsets) < n {
+		p.offsets = append(p.offsets, 0)
+	}
+	p.offsets[n-1] = p.buf.Len()
+	if stream == nil {
+		fmt.Fprintf(&p.buf, "%d 0 obj\n%s\nendobj\n", n, dict)
+		return
+	}
+	fmt.Fprintf(&p.buf, "%d 0 obj\n<<%s /Length %d>>\nstream\n", n, dict, len(stream))
+	p.buf.Write(stream)
+	p.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// writePDF writes pages as full page images on width x height point pages,
+// with outline as its bookmarks. Images are stored losslessly, Flate
+// compressed.
+func writePDF(w io.Writer, pages []image.Image, width, height float64, outline []pdfBookmark) error {
+	var p pdfWriter
+	p.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1 and 2 are the catalog and page tree, then each page is a
+	// page, its content and its image, then the outline.
+	kids := ""
+	for i := range pages {
+		kids += fmt.Sprintf("%d 0 R ", 3+3*i)
+	}
+	catalog := "<</Type /Catalog /Pages 2 0 R>>"
+	if len(outline) > 0 {
+		root := 3 + 3*len(pages)
+		catalog = fmt.Sprintf("<</Type /Catalog /Pages 2 0 R /Outlines %d 0 R /PageMode /UseOutlines>>", root)
+		next := root + 1
+		first, last, count := p.bookmarks(outline, root, &next)
+		p.object(root, fmt.Sprintf("<</Type /Outlines /First %d 0 R /Last %d 0 R /Count %d>>", first, last, count), nil)
+	}
+	p.object(1, catalog, nil)
+	p.object(2, fmt.Sprintf("<</Type /Pages /Kids [%s] /Count %d>>", kids, len(pages)), nil)
+	for i, img := range pages {
+		page, content, xobj := 3+3*i, 4+3*i, 5+3*i
+		p.object(page, fmt.Sprintf("<</Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources <</XObject <</Im0 %d 0 R>>>> /Contents %d 0 R>>",
+			width, height, xobj, content), nil)
+		p.object(content, "", fmt.Appendf(nil, "q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q", width, height))
+		rgb, err := flateRGB(img)
+		if err != nil {
+			return err
+		}
+		b := img.Bounds()
+		p.object(xobj, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode",
+			b.Dx(), b.Dy()), rgb)
+	}
+	xref := p.buf.Len()
+	fmt.Fprintf(&p.buf, "xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, off := range p.offsets {
+		fmt.Fprintf(&p.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&p.buf, "trailer\n<</Size %d /Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref)
+	_, err := p.buf.WriteTo(w)
+	return err
+}
+
+// bookmarks writes the outline entries bookmarks under the entry parent,
+// numbering objects from *next, and returns the first and last's object
+// numbers and how many entries there are in all.
+func (p *pdfWriter) bookmarks(bookmarks []pdfBookmark, parent int, next *int) (first, last, count int) {
+	objs := make([]int, len(bookmarks))
+	for i := range bookmarks {
+		objs[i] = *next
+		*next++
+	}
+	for i, b := range bookmarks {
+		dict := fmt.Sprintf("<</Title %s /Parent %d 0 R /Dest [%d 0 R /XYZ 0 %.2f null]", pdfText(b.Title), parent, 3+3*b.Page, b.Top)
+		if i > 0 {
+			dict += fmt.Sprintf(" /Prev %d 0 R", objs[i-1])
+		}
+		if i+1 < len(objs) {
+			dict += fmt.Sprintf(" /Next %d 0 R", objs[i+1])
+		}
+		count++
+		if len(b.Bookmarks) > 0 {
+			f, l, n := p.bookmarks(b.Bookmarks, objs[i], next)
+			dict += fmt.Sprintf(" /First %d 0 R /Last %d 0 R /Count %d", f, l, n)
+			count += n
+		}
+		p.object(objs[i], dict+">>", nil)
+	}
+	return objs[0], objs[len(objs)-1], count
+}
+
+// pdfText returns s as a PDF text string, UTF-16 so any script shows.
+func pdfText(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// flateRGB returns the zlib compressed RGB samples of img.
+func flateRGB(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	b := img.Bounds()
+	row := make([]byte, 3*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			i := 3 * (x - b.Min.X)
+			row[i], row[i+1], row[i+2] = byte(r>>8), byte(g>>8), byte(bl>>8)
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,148 @@
+package sheet
+
+// slot is where a cell is drawn in the grid of its page: the column and row
+// of its top left corner and the number of columns and rows it spans.
+type slot struct {
+	col, row, span int
+}
+
+// placer places cells in reading order, each in the first free space it
+// fits, so larger cells push their neighbours along.
+type placer struct {
+	cols, maxRows int  // maxRows 0 for no limit
+	stickers      bool // one cell per sticker, see Options.Stock
+	used          map[[2]int]bool
+	rows          int // rows used so far
+}
+
+func newPlacer(opts Options) *placer {
+	return &placer{cols: opts.Columns, maxRows: opts.PageRows, stickers: opts.Stock != nil, used: map[[2]int]bool{}}
+}
+
+// span returns the columns and rows a cell spans, at most what a page has.
+func (p *placer) span(c Cell) int {
+	if p.stickers {
+		return 1
+	}
+	span := min(max(c.Span, 1), p.cols)
+	if p.maxRows > 0 {
+		span = min(span, p.maxRows)
+	}
+	return span
+}
+
+// place takes the first free space for c, or reports that it doesn't fit.
+func (p *placer) place(c Cell) (slot, bool) {
+	span := p.span(c)
+	for row := 0; p.maxRows == 0 || row+span <= p.maxRows; row++ {
+		for col := 0; col+span <= p.cols; col++ {
+			if p.free(col, row, span) {
+				for y := row; y < row+span; y++ {
+					for x := col; x < col+span; x++ {
+						p.used[[2]int{x, y}] = true
+					}
+				}
+				p.rows = max(p.rows, row+span)
+				return slot{col, row, span}, true
+			}
+		}
+	}
+	return slot{}, false
+}
+
+func (p *placer) free(col, row, span int) bool {
+	for y := row; y < row+span; y++ {
+		for x := col; x < col+span; x++ {
+			if p.used[[2]int{x, y}] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fits reports whether cells could all be placed next, without placing
+// them.
+func (p *placer) fits(cells []Cell) bool {
+	q := *p
+	q.used = make(map[[2]int]bool, len(p.used))
+	for k := range p.used {
+		q.used[k] = true
+	}
+	for _, c := range cells {
+		if _, ok := q.place(c); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// place returns the slots of the cells of a page and the number of rows of
+// its grid.
+func place(opts Options, cells []Cell) ([]slot, int) {
+	p := newPlacer(opts) // pages are split so that every cell fits
+	slots := make([]slot, len(cells))
+	for i, c := range cells {
+		slots[i], _ = p.place(c)
+	}
+	rows := max(p.rows, opts.Rows)
+	if opts.Duplex != "" && len(cells) > 0 && cells[0].Back {
+		mirror(opts, slots, rows)
+	}
+	return slots, rows
+}
+
+// PageStarts returns the index of the first cell on each page of a sheet,
+// see Options.PageRows, Options.KeepCategories and Options.Duplex.
+func PageStarts(opts Options, cells []Cell) []int {
+	starts := []int{0}
+	if opts.PerPage() == 0 && opts.Duplex == "" {
+		return starts
+	}
+	p := newPlacer(opts)
+	for i, cell := range cells {
+		newPage := false
+		if opts.Duplex != "" && i > 0 && cell.Back != cells[i-1].Back {
+			newPage = true
+		} else if opts.KeepCategories && i > 0 && cell.Category != cells[i-1].Category {
+			run := cells[i : i+categoryRun(cells[i:])]
+			newPage = !p.fits(run) && newPlacer(opts).fits(run)
+		}
+		if newPage {
+			starts, p = append(starts, i), newPlacer(opts)
+		}
+		if _, ok := p.place(cell); !ok {
+			starts, p = append(starts, i), newPlacer(opts)
+			p.place(cell)
+		}
+	}
+	return starts
+}
+
+// categoryRun returns the number of cells at the start of cells in the
+// first one's category.
+func categoryRun(cells []Cell) int {
+	n := 1
+	for n < len(cells) && cells[n].Category == cells[0].Category {
+		n++
+	}
+	return n
+}
+
+// Position is where a cell is printed: its page, from 0, and the column and
+// row of its top left corner there.
+type Position struct {
+	Page, Column, Row int
+}
+
+// Positions returns the position of each cell on the sheet.
+func Positions(opts Options, cells []Cell) []Position {
+	var positions []Position
+	for i, page := range paginate(opts, cells) {
+		slots, _ := place(opts, page)
+		for _, s := range slots {
+			positions = append(positions, Position{i, s.col, s.row})
+		}
+	}
+	return positions
+}
@@ -0,0 +1,163 @@
+package sheet
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// PrintTestSquare is the side of the square on the printer test page, in
+// millimetres.
+const PrintTestSquare = 50
+
+// RenderPrintTest renders a printer test page: millimetre and inch rulers,
+// a PrintTestSquare mm square, grey patches from white to black, and
+// stripes a few pixels wide. Printed at actual size the square and rulers
+// measure what they say; printed with "fit to page" they come out small,
+// and so would a sheet's QR codes.
+func RenderPrintTest(opts Options) (image.Image, error) {
+	renderMu.Lock()
+	defer renderMu.Unlock()
+	if err := opts.Fonts.load(); err != nil {
+		return nil, err
+	}
+	width := int(opts.WidthInches * opts.DPI)
+	height := int(opts.HeightInches * opts.DPI)
+	ts := opts.textScale()
+	margin := opts.Margin
+	mm := opts.DPI / 25.4
+
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+	dc.SetRGB(0, 0, 0)
+	dc.SetFontFace(opts.Fonts.title(24 * ts))
+	drawString(dc, "Printer test", float64(width)/2, margin/2, 0.5, 0.5)
+	dc.SetFontFace(opts.Fonts.description(12 * ts))
+	drawWrapped(dc, "Print at actual size (100%, not \"fit to page\") on the printer and paper the sheet will use, then measure. "+
+		"If the square or rulers come out short, the printer is scaling the page down and would shrink QR codes with it.",
+		float64(width)/2, margin/2+24*ts, 0.5, 0, float64(width)-2*margin, 1.3, gg.AlignCenter)
+
+	// Rulers: millimetres across the top and down the left, inches across
+	// the top under them.
+	left, top := margin+10*mm, margin+50*ts
+	across := float64(width) - margin - left
+	ruler(dc, opts, left, top, math.Floor(across/mm/10)*10*mm, mm, 10, false, func(i int) string { return fmt.Sprint(i / 10) }, "cm")
+	ruler(dc, opts, left, top+14*mm, math.Floor(across/opts.DPI)*opts.DPI, opts.DPI/8, 8, false, func(i int) string { return fmt.Sprint(i / 8) }, "in")
+	down := float64(height) - margin - top
+	ruler(dc, opts, margin, top, math.Floor(down/mm/10)*10*mm, mm, 10, true, func(i int) string { return fmt.Sprint(i / 10) }, "cm")
+
+	// The square, with its size printed inside and out.
+	side := PrintTestSquare * mm
+	x, y := (float64(width)-side)/2, top+34*mm
+	dc.SetLineWidth(max(1, 0.2*mm))
+	dc.DrawRectangle(x, y, side, side)
+	dc.Stroke()
+	dc.SetFontFace(opts.Fonts.title(18 * ts))
+	drawString(dc, fmt.Sprintf("%d mm", PrintTestSquare), x+side/2, y+side/2, 0.5, 0.5)
+	dc.SetFontFace(opts.Fonts.description(11 * ts))
+	drawWrapped(dc, fmt.Sprintf("Each side should measure %d mm. One measuring 47 mm was printed at 94%%: turn off \"fit to page\" or \"shrink oversized pages\" and print again.",
+		PrintTestSquare), x+side/2, y+side+8*mm, 0.5, 0, float64(width)-2*margin-20*mm, 1.3, gg.AlignCenter)
+
+	// Grey patches from white to black.
+	y += side + 30*mm
+	patch := 14 * mm
+	steps := 11
+	x = (float64(width) - float64(steps)*patch) / 2
+	dc.SetFontFace(opts.Fonts.label(12 * ts))
+	drawString(dc, "Contrast: every patch should be a different shade", float64(width)/2, y-4*mm, 0.5, 0)
+	for i := range steps {
+		grey := 1 - float64(i)/float64(steps-1)
+		dc.SetRGB(grey, grey, grey)
+		dc.DrawRectangle(x+float64(i)*patch, y, patch, patch)
+		dc.Fill()
+		dc.SetRGB(0, 0, 0)
+		dc.SetFontFace(opts.Fonts.label(9 * ts))
+		drawString(dc, fmt.Sprintf("%d%%", i*10), x+(float64(i)+0.5)*patch, y+patch+2*mm, 0.5, 1)
+	}
+	dc.SetLineWidth(1)
+	dc.DrawRectangle(x, y, float64(steps)*patch, patch)
+	dc.Stroke()
+
+	// Stripes of whole pixels, across and down: ones that blur into grey
+	// are finer than the printer prints, and so are QR modules that thin.
+	y += patch + 16*mm
+	widths := []int{1, 2, 3, 4, 6}
+	gap := 6 * mm
+	x = (float64(width) - float64(len(widths))*(patch+gap) + gap) / 2
+	dc.SetFontFace(opts.Fonts.label(12 * ts))
+	drawString(dc, "Sharpness: stripes that blur together are too fine for QR modules", float64(width)/2, y-4*mm, 0.5, 0)
+	for i, w := range widths {
+		px := x + float64(i)*(patch+gap)
+		stripes(dc, math.Round(px), math.Round(y), math.Round(patch), float64(w), false)
+		stripes(dc, math.Round(px), math.Round(y+patch+2*mm), math.Round(patch), float64(w), true)
+		dc.SetFontFace(opts.Fonts.label(9 * ts))
+		drawString(dc, fmt.Sprintf("%.2f mm", float64(w)/mm), px+patch/2, y+2*patch+4*mm, 0.5, 1)
+	}
+	return dc.Image(), nil
+}
+
+// ruler draws a ruler length pixels long from (x, y), across or down, with
+// a tick every step pixels, a long numbered one every major ticks and a
+// middle one half way between.
+func ruler(dc *gg.Context, opts Options, x, y, length, step float64, major int, vertical bool, label func(i int) string, unit string) {
+	ts := opts.textScale()
+	tick := opts.DPI / 25.4 * 2
+	dc.SetRGB(0, 0, 0)
+	dc.SetLineWidth(max(1, opts.DPI/300))
+	line := func(along, from, to float64) {
+		if vertical {
+			dc.DrawLine(x+from, y+along, x+to, y+along)
+		} else {
+			dc.DrawLine(x+along, y+from, x+along, y+to)
+		}
+		dc.Stroke()
+	}
+	if vertical {
+		dc.DrawLine(x, y, x, y+length)
+	} else {
+		dc.DrawLine(x, y, x+length, y)
+	}
+	dc.Stroke()
+	dc.SetFontFace(opts.Fonts.label(8 * ts))
+	n := int(math.Round(length / step))
+	for i := 0; i <= n; i++ {
+		along := float64(i) * step
+		size := tick
+		switch {
+		case i%major == 0:
+			size = tick * 2.5
+			s := label(i)
+			if i == 0 {
+				s = "0 " + unit
+			}
+			if vertical {
+				drawString(dc, s, x+size+1*ts, y+along, 0, 0.5)
+			} else {
+				drawString(dc, s, x+along, y+size+1*ts, 0.5, 1)
+			}
+		case major%2 == 0 && i%(major/2) == 0:
+			size = tick * 1.6
+		}
+		line(along, 0, size)
+	}
+}
+
+// stripes fills a size pixel square at (x, y) with black and white stripes
+// each w pixels wide, running down the square or across it.
+func stripes(dc *gg.Context, x, y, size, w float64, across bool) {
+	dc.SetRGB(1, 1, 1)
+	dc.DrawRectangle(x, y, size, size)
+	dc.Fill()
+	dc.SetRGB(0, 0, 0)
+	for at := 0.0; at < size; at += 2 * w {
+		if across {
+			dc.DrawRectangle(x, y+at, size, min(w, size-at))
+		} else {
+			dc.DrawRectangle(x+at, y, min(w, size-at), size)
+		}
+		dc.Fill()
+	}
+}
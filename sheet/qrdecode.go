@@ -0,0 +1,689 @@
+package sheet
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// A small QR decoder for checking rendered sheets. It only handles the
+// upright, unskewed codes the sheet draws, not photographs.
+
+// bitmap is a region of a greyscale page split into dark and light pixels,
+// with dark and light swapped for inverted codes.
+type bitmap struct {
+	gray   *image.Gray
+	r      image.Rectangle
+	invert bool
+}
+
+func (b bitmap) at(x, y int) bool {
+	if !(image.Point{x, y}).In(b.r) {
+		return false
+	}
+	return (b.gray.GrayAt(x, y).Y < 128) != b.invert
+}
+
+// finder is the centre of a finder pattern and its module size.
+type finder struct{ x, y, module float64 }
+
+// finderRatio reports whether the run lengths are in the 1:1:3:1:1 ratio of
+// a finder pattern.
+func finderRatio(l [5]int) bool {
+	total := 0
+	for _, n := range l {
+		total += n
+	}
+	if total < 7 {
+		return false
+	}
+	m := float64(total) / 7
+	for i, want := range [5]float64{1, 1, 3, 1, 1} {
+		if math.Abs(float64(l[i])-want*m) >= want*m/2 {
+			return false
+		}
+	}
+	return true
+}
+
+// findFinders returns the finder patterns in b, scanning rows for the
+// 1:1:3:1:1 ratio and confirming it down the middle column.
+func (b bitmap) findFinders() []finder {
+	var found []finder
+	for y := b.r.Min.Y; y < b.r.Max.Y; y++ {
+		// Lengths of alternating runs along the row, starting with a dark one.
+		var runs, starts []int
+		for x := b.r.Min.X; x < b.r.Max.X; x++ {
+			dark := b.at(x, y)
+			if len(runs)%2 == 0 && dark || len(runs)%2 == 1 && !dark {
+				runs, starts = append(runs, 0), append(starts, x)
+			}
+			if len(runs) > 0 {
+				runs[len(runs)-1]++
+			}
+		}
+	runs:
+		for i := 0; i+5 <= len(runs); i += 2 {
+			h := [5]int(runs[i : i+5])
+			if !finderRatio(h) {
+				continue
+			}
+			cx := starts[i+2] + h[2]/2
+			v, top, ok := b.column(cx, y)
+			if !ok || !finderRatio(v) {
+				continue
+			}
+			f := finder{
+				x:      float64(starts[i+2]) + float64(h[2])/2,
+				y:      float64(top) + float64(v[2])/2,
+				module: float64(h[0]+h[1]+h[2]+h[3]+h[4]+v[0]+v[1]+v[2]+v[3]+v[4]) / 14,
+			}
+			for _, g := range found {
+				if math.Abs(g.x-f.x) < g.module && math.Abs(g.y-f.y) < g.module {
+					continue runs
+				}
+			}
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// column returns the lengths of the dark, light, dark, light and dark runs
+// down column x centred on the dark run through y, and the top of that run.
+func (b bitmap) column(x, y int) (l [5]int, top int, ok bool) {
+	if !b.at(x, y) {
+		return l, 0, false
+	}
+	yy := y
+	for ; yy >= b.r.Min.Y && b.at(x, yy); yy-- {
+		l[2]++
+	}
+	top = yy + 1
+	for ; yy >= b.r.Min.Y && !b.at(x, yy); yy-- {
+		l[1]++
+	}
+	for ; yy >= b.r.Min.Y && b.at(x, yy); yy-- {
+		l[0]++
+	}
+	yy = y + 1
+	for ; yy < b.r.Max.Y && b.at(x, yy); yy++ {
+		l[2]++
+	}
+	for ; yy < b.r.Max.Y && !b.at(x, yy); yy++ {
+		l[3]++
+	}
+	for ; yy < b.r.Max.Y && b.at(x, yy); yy++ {
+		l[4]++
+	}
+	return l, top, true
+}
+
+// decodedQR is a code found on the page.
+type decodedQR struct {
+	text      string
+	bounds    image.Rectangle
+	module    float64
+	contrast  float64 // lightest dark module to darkest light module, 0 to 1
+	corrected int     // codewords repaired by error correction
+	invert    bool    // light on dark
+	version   int
+	level     byte // L, M, Q or H
+}
+
+// decodeAll returns the codes in b, trying every upright arrangement of
+// three finder patterns.
+func (b bitmap) decodeAll() []decodedQR {
+	finders := b.findFinders()
+	var codes []decodedQR
+	for _, tl := range finders {
+		for _, tr := range finders {
+			if tr.x <= tl.x || math.Abs(tr.y-tl.y) >= tl.module {
+				continue
+			}
+			for _, bl := range finders {
+				if bl.y <= tl.y || math.Abs(bl.x-tl.x) >= tl.module {
+					continue
+				}
+				if math.Abs((tr.x-tl.x)-(bl.y-tl.y)) >= 2*tl.module {
+					continue
+				}
+				if code, err := b.decode(tl, tr, bl); err == nil {
+					codes = append(codes, code)
+				}
+			}
+		}
+	}
+	return codes
+}
+
+// decode samples and decodes the code with the given finder patterns.
+func (b bitmap) decode(tl, tr, bl finder) (decodedQR, error) {
+	module := (tl.module + tr.module + bl.module) / 3
+	dim := int(math.Round((tr.x-tl.x+bl.y-tl.y)/2/module)) + 7
+	switch dim % 4 {
+	case 0:
+		dim++
+	case 2:
+		dim--
+	case 3:
+		return decodedQR{}, errors.New("qr: bad size")
+	}
+	if dim < 21 || dim > 177 {
+		return decodedQR{}, errors.New("qr: bad size")
+	}
+	mx, my := (tr.x-tl.x)/float64(dim-7), (bl.y-tl.y)/float64(dim-7)
+
+	m := make([][]bool, dim)
+	lightest, darkest := 0, 255
+	for r := range m {
+		m[r] = make([]bool, dim)
+		for c := range m[r] {
+			x := int(math.Floor(tl.x + (float64(c)-3)*mx))
+			y := int(math.Floor(tl.y + (float64(r)-3)*my))
+			m[r][c] = b.at(x, y)
+			g := int(b.gray.GrayAt(x, y).Y)
+			if b.invert {
+				g = 255 - g
+			}
+			if m[r][c] {
+				lightest = max(lightest, g)
+			} else {
+				darkest = min(darkest, g)
+			}
+		}
+	}
+	text, level, corrected, err := decodeMatrix(m)
+	if err != nil {
+		return decodedQR{}, err
+	}
+	x0, y0 := tl.x-3.5*mx, tl.y-3.5*my
+	return decodedQR{
+		text:      text,
+		bounds:    image.Rect(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x0+float64(dim)*mx)), int(math.Round(y0+float64(dim)*my))),
+		module:    (mx + my) / 2,
+		contrast:  float64(darkest-lightest) / 255,
+		corrected: corrected,
+		invert:    b.invert,
+		version:   (dim - 17) / 4,
+		level:     "LMQH"[level],
+	}, nil
+}
+
+// Error correction blocks by level (L, M, Q, H) and version, from ISO/IEC
+// 18004 table 9.
+var (
+	eccPerBlock = [4][41]int{
+		{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+		{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	}
+	eccBlocks = [4][41]int{
+		{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+		{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+		{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+		{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+	}
+)
+
+// alignmentPositions returns the row and column centres of a version's
+// alignment patterns.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	pos := make([]int, n)
+	pos[0] = 6
+	for i, p := n-1, version*4+10; i >= 1; i, p = i-1, p-step {
+		pos[i] = p
+	}
+	return pos
+}
+
+// rawCodewords returns the number of data and error correction codewords of
+// a version.
+func rawCodewords(version int) int {
+	bits := (16*version+128)*version + 64
+	if version >= 2 {
+		n := version/7 + 2
+		bits -= (25*n-10)*n - 55
+		if version >= 7 {
+			bits -= 36
+		}
+	}
+	return bits / 8
+}
+
+// functionModules marks the modules of a version that don't hold data.
+func functionModules(version int) [][]bool {
+	dim := version*4 + 17
+	f := make([][]bool, dim)
+	for r := range f {
+		f[r] = make([]bool, dim)
+	}
+	rect := func(r0, c0, h, w int) {
+		for r := r0; r < r0+h; r++ {
+			for c := c0; c < c0+w; c++ {
+				f[r][c] = true
+			}
+		}
+	}
+	// Finders with separators and format information, timing patterns.
+	rect(0, 0, 9, 9)
+	rect(0, dim-8, 9, 8)
+	rect(dim-8, 0, 8, 9)
+	rect(6, 0, 1, dim)
+	rect(0, 6, dim, 1)
+	pos := alignmentPositions(version)
+	for i, r := range pos {
+		for j, c := range pos {
+			if i == 0 && j == 0 || i == 0 && j == len(pos)-1 || i == len(pos)-1 && j == 0 {
+				continue
+			}
+			rect(r-2, c-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		rect(0, dim-11, 6, 3)
+		rect(dim-11, 0, 3, 6)
+	}
+	return f
+}
+
+// formatCode returns the masked 15 bit format information for 5 bits of
+// level and mask.
+func formatCode(data int) int {
+	rem := data << 10
+	for i := 14; i >= 10; i-- {
+		if rem&(1<<i) != 0 {
+			rem ^= 0x537 << (i - 10)
+		}
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// readFormat returns the error correction level (0 to 3 for L to H) and
+// mask of m from the closer of its two copies of the format information.
+func readFormat(m [][]bool) (level, mask int, err error) {
+	dim := len(m)
+	var copies [2]int
+	bit := func(i int, dark bool) {
+		copies[i] <<= 1
+		if dark {
+			copies[i] |= 1
+		}
+	}
+	for c := 0; c <= 5; c++ {
+		bit(0, m[8][c])
+	}
+	bit(0, m[8][7])
+	bit(0, m[8][8])
+	bit(0, m[7][8])
+	for r := 5; r >= 0; r-- {
+		bit(0, m[r][8])
+	}
+	for r := dim - 1; r >= dim-7; r-- {
+		bit(1, m[r][8])
+	}
+	for c := dim - 8; c < dim; c++ {
+		bit(1, m[8][c])
+	}
+	best, bestDist := 0, 16
+	for data := 0; data < 32; data++ {
+		code := formatCode(data)
+		for _, read := range copies {
+			if d := popcount(code ^ read); d < bestDist {
+				best, bestDist = data, d
+			}
+		}
+	}
+	if bestDist > 3 {
+		return 0, 0, errors.New("qr: unreadable format information")
+	}
+	// Level bits are 01 for L, 00 for M, 11 for Q and 10 for H.
+	return [4]int{1, 0, 3, 2}[best>>3], best & 7, nil
+}
+
+func popcount(v int) int {
+	n := 0
+	for ; v != 0; v &= v - 1 {
+		n++
+	}
+	return n
+}
+
+var dataMasks = [8]func(r, c int) bool{
+	func(r, c int) bool { return (r+c)%2 == 0 },
+	func(r, c int) bool { return r%2 == 0 },
+	func(r, c int) bool { return c%3 == 0 },
+	func(r, c int) bool { return (r+c)%3 == 0 },
+	func(r, c int) bool { return (r/2+c/3)%2 == 0 },
+	func(r, c int) bool { return r*c%2+r*c%3 == 0 },
+	func(r, c int) bool { return (r*c%2+r*c%3)%2 == 0 },
+	func(r, c int) bool { return ((r+c)%2+r*c%3)%2 == 0 },
+}
+
+// decodeMatrix decodes a sampled code, returning its text, error correction
+// level (0 to 3 for L to H) and the number of codewords error correction
+// repaired.
+func decodeMatrix(m [][]bool) (text string, level, corrected int, err error) {
+	dim := len(m)
+	version := (dim - 17) / 4
+	level, mask, err := readFormat(m)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	// Read the codewords in the two column wide zigzag from the bottom
+	// right, skipping the vertical timing pattern.
+	fn := functionModules(version)
+	var codewords []byte
+	var cur byte
+	n := 0
+	up := true
+	for right := dim - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for v := 0; v < dim; v++ {
+			r := v
+			if up {
+				r = dim - 1 - v
+			}
+			for c := right; c > right-2; c-- {
+				if fn[r][c] {
+					continue
+				}
+				cur <<= 1
+				if m[r][c] != dataMasks[mask](r, c) {
+					cur |= 1
+				}
+				if n++; n%8 == 0 {
+					codewords = append(codewords, cur)
+					cur = 0
+				}
+			}
+		}
+		up = !up
+	}
+	raw := rawCodewords(version)
+	if len(codewords) < raw {
+		return "", 0, 0, errors.New("qr: too few codewords")
+	}
+
+	// De-interleave the blocks, the later ones having a data codeword more
+	// than the earlier.
+	numBlocks, ecc := eccBlocks[level][version], eccPerBlock[level][version]
+	short := numBlocks - raw%numBlocks
+	shortData := raw/numBlocks - ecc
+	blocks := make([][]byte, numBlocks)
+	dataLen := func(b int) int {
+		if b >= short {
+			return shortData + 1
+		}
+		return shortData
+	}
+	for b := range blocks {
+		blocks[b] = make([]byte, dataLen(b)+ecc)
+	}
+	k := 0
+	for i := 0; i <= shortData; i++ {
+		for b := range blocks {
+			if i < dataLen(b) {
+				blocks[b][i] = codewords[k]
+				k++
+			}
+		}
+	}
+	for i := 0; i < ecc; i++ {
+		for b := range blocks {
+			blocks[b][dataLen(b)+i] = codewords[k]
+			k++
+		}
+	}
+
+	var data []byte
+	for b, block := range blocks {
+		n, err := rsCorrect(block, ecc)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		corrected += n
+		data = append(data, block[:dataLen(b)]...)
+	}
+	text, err = parseSegments(data, version)
+	return text, level, corrected, err
+}
+
+// GF(256) arithmetic with QR's polynomial x^8 + x^4 + x^3 + x^2 + 1.
+var gfExp, gfLog = func() (exp [510]byte, log [256]int) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = byte(x), byte(x)
+		log[x] = i
+		if x <<= 1; x >= 256 {
+			x ^= 0x11d
+		}
+	}
+	return
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+255-gfLog[b]]
+}
+
+// gfPow returns α^e.
+func gfPow(e int) byte {
+	return gfExp[(e%255+255)%255]
+}
+
+// rsCorrect repairs a Reed-Solomon block, first codeword highest degree,
+// with nsym error correction codewords in place and returns the number of
+// codewords repaired.
+func rsCorrect(block []byte, nsym int) (int, error) {
+	synd := make([]byte, nsym)
+	clean := true
+	for i := range synd {
+		// Evaluate the block at α^i by Horner's rule.
+		var v byte
+		for _, c := range block {
+			v = gfMul(v, gfPow(i)) ^ c
+		}
+		synd[i] = v
+		clean = clean && v == 0
+	}
+	if clean {
+		return 0, nil
+	}
+
+	// Berlekamp-Massey finds the error locator, lowest degree first.
+	loc, prev := []byte{1}, []byte{1}
+	errs, shift := 0, 1
+	last := byte(1)
+	for k := 0; k < nsym; k++ {
+		d := synd[k]
+		for i := 1; i <= errs; i++ {
+			d ^= gfMul(loc[i], synd[k-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+		t := append([]byte(nil), loc...)
+		for len(loc) < len(prev)+shift {
+			loc = append(loc, 0)
+		}
+		coef := gfDiv(d, last)
+		for i, p := range prev {
+			loc[i+shift] ^= gfMul(coef, p)
+		}
+		if 2*errs <= k {
+			errs, prev, last, shift = k+1-errs, t, d, 1
+		} else {
+			shift++
+		}
+	}
+	if 2*errs > nsym {
+		return 0, errors.New("qr: too many errors")
+	}
+	eval := func(p []byte, x byte) byte {
+		var v byte
+		for i := len(p) - 1; i >= 0; i-- {
+			v = gfMul(v, x) ^ p[i]
+		}
+		return v
+	}
+
+	// Error evaluator omega = synd * loc mod x^nsym, and the formal
+	// derivative of the locator.
+	omega := make([]byte, nsym)
+	for i := range omega {
+		for j := 0; j <= i && j < len(loc); j++ {
+			omega[i] ^= gfMul(loc[j], synd[i-j])
+		}
+	}
+	deriv := make([]byte, len(loc))
+	for i := 1; i < len(loc); i += 2 {
+		deriv[i-1] = loc[i]
+	}
+
+	// Chien search for the roots, then Forney for the magnitudes.
+	found := 0
+	for idx := range block {
+		p := len(block) - 1 - idx
+		xinv := gfPow(-p)
+		if eval(loc, xinv) != 0 {
+			continue
+		}
+		den := eval(deriv, xinv)
+		if den == 0 {
+			return 0, errors.New("qr: uncorrectable errors")
+		}
+		block[idx] ^= gfMul(gfPow(p), gfDiv(eval(omega, xinv), den))
+		found++
+	}
+	if found != errs {
+		return 0, errors.New("qr: uncorrectable errors")
+	}
+	return errs, nil
+}
+
+// bitReader reads big-endian bit fields.
+type bitReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *bitReader) left() int { return len(r.b)*8 - r.pos }
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for ; n > 0; n-- {
+		v = v<<1 | int(r.b[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
+
+const qrAlphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// parseSegments decodes the numeric, alphanumeric and byte segments of a
+// code's data. Byte segments are taken as UTF-8, as the sheet writes them.
+func parseSegments(data []byte, version int) (string, error) {
+	group := 0
+	if version >= 27 {
+		group = 2
+	} else if version >= 10 {
+		group = 1
+	}
+	r := &bitReader{b: data}
+	var out []byte
+	short := errors.New("qr: truncated data")
+	for r.left() >= 4 {
+		mode := r.read(4)
+		var countBits int
+		switch mode {
+		case 0:
+			return string(out), nil
+		case 1:
+			countBits = [3]int{10, 12, 14}[group]
+		case 2:
+			countBits = [3]int{9, 11, 13}[group]
+		case 4:
+			countBits = [3]int{8, 16, 16}[group]
+		case 7: // ECI designator, 1 to 3 bytes
+			if r.left() < 8 {
+				return "", short
+			}
+			first := r.read(8)
+			extra := 0
+			if first&0x80 != 0 {
+				extra = 1
+				if first&0xc0 == 0xc0 {
+					extra = 2
+				}
+			}
+			if r.left() < extra*8 {
+				return "", short
+			}
+			r.read(extra * 8)
+			continue
+		default:
+			return "", fmt.Errorf("qr: unsupported mode %d", mode)
+		}
+		if r.left() < countBits {
+			return "", short
+		}
+		count := r.read(countBits)
+		switch mode {
+		case 1:
+			for ; count > 0; count -= 3 {
+				digits, bits := min(count, 3), [4]int{0, 4, 7, 10}[min(count, 3)]
+				if r.left() < bits {
+					return "", short
+				}
+				out = fmt.Appendf(out, "%0*d", digits, r.read(bits))
+			}
+		case 2:
+			for ; count > 0; count -= 2 {
+				if count == 1 {
+					if r.left() < 6 {
+						return "", short
+					}
+					out = append(out, qrAlphanumeric[r.read(6)%45])
+					break
+				}
+				if r.left() < 11 {
+					return "", short
+				}
+				v := r.read(11)
+				if v >= 45*45 {
+					return "", errors.New("qr: bad alphanumeric data")
+				}
+				out = append(out, qrAlphanumeric[v/45], qrAlphanumeric[v%45])
+			}
+		case 4:
+			if r.left() < count*8 {
+				return "", short
+			}
+			for ; count > 0; count-- {
+				out = append(out, byte(r.read(8)))
+			}
+		}
+	}
+	return string(out), nil
+}
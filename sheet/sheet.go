@@ -0,0 +1,821 @@
+// Package sheet lays out barcodes in a grid on a printable page, each with a
+// short label and a longer description underneath.
+package sheet
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/fogleman/gg"
+)
+
+// Symbologies understood by Cell.Symbology.
+const (
+	QR      = "qr"
+	Code128 = "code128"
+)
+
+// Cell styles understood by Options.CellStyle.
+const (
+	CellStacked    = "stacked"    // label and description under the code
+	CellHorizontal = "horizontal" // stacked to the right of the code
+	CellCompact    = "compact"    // only the label, under the largest code that fits
+)
+
+// Cell is one barcode on the sheet.
+type Cell struct {
+	Payload     string // exact data encoded in the barcode
+	Label       string // short label under the barcode, Payload if empty
+	Description string // longer explanation under the label
+	Symbology   string // QR if empty
+
+	// Icon is a small picture drawn before the label, see Options.IconSize.
+	Icon image.Image
+
+	// Span makes the cell Span columns wide and rows high, for a bigger
+	// code; 1 if zero. Other cells flow around it.
+	Span int
+
+	// Back prints the cell on the back of the paper with Options.Duplex.
+	Back bool
+
+	// Category is shown in the corner of the cell in its Accent colour,
+	// which also colours the cell boundary and label.
+	Category string
+	Accent   color.Color
+
+	// Extra is an optional small QR code drawn in the corner of the cell,
+	// e.g. dial-in numbers next to a meeting link, captioned ExtraCaption.
+	Extra        string
+	ExtraCaption string
+
+	// Mark is a short note such as "new", drawn as a badge in the bottom
+	// right corner of the cell, which is outlined in MarkColor.
+	Mark      string
+	MarkColor color.Color
+
+	// Number is a short code such as "042" drawn in the bottom left
+	// corner, for asking someone with a scanner to send the message.
+	Number string
+
+	continued bool // first of its category on a page, continuing from the last
+}
+
+// label returns the text under the cell's barcode.
+func (c Cell) label() string {
+	if c.Label == "" {
+		return c.Payload
+	}
+	return c.Label
+}
+
+// Options describes the page a sheet is rendered onto.
+type Options struct {
+	Title    string
+	Subtitle string // smaller line under the title, omitted if empty
+	Footer   string // URL printed and encoded at the bottom of the page, omitted if empty
+	Columns  int
+	Rows     int // minimum number of rows; more are added to fit every cell
+	PageRows int // rows per page, cells continuing on further pages; 0 for a single page
+
+	// FooterLinks are more codes beside the footer's, e.g. a feedback
+	// form and the docs, and Disclaimer a line of small print under them.
+	FooterLinks []FooterLink
+	Disclaimer  string
+
+	// DigitalCopy is the URL of the sheet's hosted copy, encoded small in
+	// the bottom right corner so a printed copy leads back to the latest.
+	DigitalCopy string
+
+	// PageNumbers numbers the pages of multi-page sheets, naming the
+	// categories on each, and marks categories continued from the page
+	// before. KeepCategories starts a new page rather than break a category
+	// that fits on one.
+	PageNumbers    bool
+	KeepCategories bool
+
+	DPI          float64
+	WidthInches  float64
+	HeightInches float64
+	Margin       float64 // in pixels
+
+	// TextScale enlarges all text and the space around it, 1 if zero.
+	TextScale float64
+
+	// Bare leaves off the title and subtitle, for pages cut into strips or
+	// stickers.
+	Bare bool
+
+	// CellStyle lays out the code and text of each cell: CellStacked if
+	// empty, CellHorizontal, which reads better in wide cells, or
+	// CellCompact.
+	CellStyle string
+
+	// DescriptionColumns sets descriptions in this many columns side by
+	// side, one if zero, DescriptionLineSpacing their lines apart (1.3 if
+	// zero) and DescriptionAlign aligns them: DescriptionLeft,
+	// DescriptionCenter, DescriptionRight or DescriptionJustify, or as the
+	// cell style does if empty.
+	DescriptionColumns     int
+	DescriptionLineSpacing float64
+	DescriptionAlign       string
+	// DescriptionMaxLines cuts descriptions short with an ellipsis past
+	// this many lines a column, if not zero. Hyphenate breaks and
+	// hyphenates words too long for their line, Language being the
+	// language of the messages, e.g. "de".
+	DescriptionMaxLines int
+	Hyphenate           bool
+	Language            string
+
+	// IntegerModules sizes QR codes to a whole number of pixels a module,
+	// the nearest to the room they have, rather than the most that fit
+	// with the rest left as padding, so codes the same size on paper come
+	// out the same size in pixels and line up with their cells.
+	IntegerModules bool
+	// PixelSnap puts cells, their borders and marks on whole pixels, with
+	// lines at least a pixel wide, so they print solid rather than grey
+	// at their edges.
+	PixelSnap bool
+
+	Fonts Fonts
+	Theme Theme
+
+	// Background is drawn faded behind the grid, scaled to cover the page.
+	Background image.Image
+	// Watermark is faded text drawn diagonally across the page.
+	Watermark string
+
+	// Logo is drawn on the title line, LogoHeight pixels high (60% of the
+	// margin if zero, at most the margin), at LogoAlign: LogoLeft if empty, LogoRight or
+	// LogoTitle.
+	Logo       image.Image
+	LogoAlign  string
+	LogoHeight float64
+	// Fingerprint is printed small in the bottom left corner, so a printed
+	// sheet can be matched to the set it was made from, after Revision, the
+	// pack version it was made from.
+	Fingerprint string
+	Revision    string
+
+	// Strings translate the words printed around the cells.
+	Strings Strings
+
+	// Cache, if set, keeps rendered cells and pages for the next render.
+	Cache Cache
+
+	// Stock, if set, places each cell on a round sticker of label stock
+	// instead of in the grid, see WithStock.
+	Stock *Stock
+
+	// Tent prints each page as a table tent folded in half: the page,
+	// HeightInches high, is the front, and is repeated upside down above
+	// it as the back, on paper twice as high.
+	Tent bool
+
+	// Duplex, DuplexLong or DuplexShort, prints Back cells on pages of
+	// their own after each page of the others, their grid mirrored to sit
+	// behind it when printed on both sides of the paper, flipped over its
+	// long or short edge. See DuplexOrder.
+	Duplex string
+}
+
+// DefaultOptions returns an A4 page at 300 DPI with four columns.
+func DefaultOptions() Options {
+	return Options{
+		Title:        "Chat QR Codes – One Scan = One Message",
+		Footer:       "https://github.com/arran4/chat-barcodes",
+		Columns:      4,
+		DPI:          300,
+		WidthInches:  8.27,
+		HeightInches: 11.69,
+		Margin:       80,
+		Theme:        Themes["light"],
+	}
+}
+
+// LargePrint adjusts opts for low-vision readers: two columns of three big
+// codes per page, 14pt labels and a high-contrast theme.
+func LargePrint(opts Options) Options {
+	opts.Columns = 2
+	opts.Rows = 3
+	opts.PageRows = 3
+	opts.TextScale = 14 * opts.DPI / 72 / 11 // labels are 11px at scale 1
+	opts.Margin = opts.DPI * 2 / 3
+	opts.Theme = Themes["high-contrast"]
+	return opts
+}
+
+// KeyboardStrip adjusts opts for strips to sit above a keyboard's function
+// row: pages 285 by 20 mm, each a single row of codes cells with no title
+// or footer.
+func KeyboardStrip(opts Options, codes int) Options {
+	mm := opts.DPI / 25.4
+	opts.WidthInches, opts.HeightInches = 285/25.4, 20/25.4
+	opts.Columns, opts.Rows, opts.PageRows = codes, 1, 1
+	opts.Margin = 1.5 * mm
+	opts.TextScale = 2 * opts.textScale() // labels about 2 mm high
+	opts.CellStyle = CellCompact
+	opts.Footer, opts.FooterLinks, opts.Disclaimer, opts.DigitalCopy = "", nil, "", ""
+	opts.Bare = true
+	return opts
+}
+
+// PerPage returns the number of cells on each page, 0 if all cells go on
+// one page.
+func (o Options) PerPage() int {
+	return o.Columns * o.PageRows
+}
+
+func (o Options) textScale() float64 {
+	if o.TextScale == 0 {
+		return 1
+	}
+	return o.TextScale
+}
+
+// SavePNG renders cells and writes them to path. If they don't fit on one
+// page (see Options.PageRows) page n is written to path with -n added
+// before the extension instead. It returns the files written.
+func SavePNG(path string, opts Options, cells []Cell) ([]string, error) {
+	pages := paginate(opts, cells)
+	ext := filepath.Ext(path)
+	var paths []string
+	for i := range pages {
+		name := path
+		if len(pages) > 1 {
+			name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), i+1, ext)
+		}
+		if err := savePage(name, opts, pages, i); err != nil {
+			return paths, err
+		}
+		paths = append(paths, name)
+	}
+	return paths, nil
+}
+
+// renderMu serialises rendering: font faces are cached between renders and
+// aren't safe for concurrent use.
+var renderMu sync.Mutex
+
+// RenderPages renders cells onto the pages SavePNG would write. It is safe
+// to call concurrently, renders taking turns.
+func RenderPages(opts Options, cells []Cell) ([]image.Image, error) {
+	renderMu.Lock()
+	defer renderMu.Unlock()
+	if err := opts.Fonts.load(); err != nil {
+		return nil, err
+	}
+	var images []image.Image
+	pages := paginate(opts, cells)
+	for i := range pages {
+		images = append(images, render(opts, pages, i).Image())
+	}
+	return images, nil
+}
+
+// RenderPage renders page (from 0) of the pages RenderPages would, without
+// rendering the others.
+func RenderPage(opts Options, cells []Cell, page int) (image.Image, error) {
+	pages := paginate(opts, cells)
+	if page < 0 || page >= len(pages) {
+		return nil, fmt.Errorf("page %d of %d", page+1, len(pages))
+	}
+	return renderPage(opts, pages, page)
+}
+
+// renderPage renders page of pages, see RenderPages.
+func renderPage(opts Options, pages [][]Cell, page int) (image.Image, error) {
+	renderMu.Lock()
+	defer renderMu.Unlock()
+	if err := opts.Fonts.load(); err != nil {
+		return nil, err
+	}
+	return render(opts, pages, page).Image(), nil
+}
+
+// RenderCell renders a single cell width by height pixels, drawn as on a
+// sheet; Options.CellSize is the size it has there.
+func RenderCell(opts Options, cell Cell, width, height int) (image.Image, error) {
+	renderMu.Lock()
+	defer renderMu.Unlock()
+	if err := opts.Fonts.load(); err != nil {
+		return nil, err
+	}
+	dc := gg.NewContext(width, height)
+	dc.SetColor(opts.Theme.Background)
+	dc.Clear()
+	if err := drawCell(dc, opts, cell, 0, 0, float64(width), float64(height)); err != nil {
+		return nil, err
+	}
+	return dc.Image(), nil
+}
+
+// CellSize returns the size in pixels cell i of a sheet of cells has.
+func (o Options) CellSize(cells []Cell, i int) (width, height int) {
+	for _, page := range paginate(o, cells) {
+		if i >= len(page) {
+			i -= len(page)
+			continue
+		}
+		slots, rows := place(o, page)
+		_, _, w, h := cellRect(o, rows, slots[i])
+		return int(w), int(h)
+	}
+	return 0, 0
+}
+
+// paginate splits cells into pages.
+func paginate(opts Options, cells []Cell) [][]Cell {
+	starts := PageStarts(opts, cells)
+	pages := make([][]Cell, len(starts))
+	for i, start := range starts {
+		end := len(cells)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		pages[i] = cells[start:end]
+	}
+	return pages
+}
+
+// grid returns the top left corner of a page's grid of the given number of
+// rows and the size of each cell, see place.
+func grid(opts Options, rows int) (left, top, cellWidth, cellHeight float64) {
+	width := float64(int(opts.WidthInches * opts.DPI))
+	height := float64(int(opts.HeightInches * opts.DPI))
+	left, top = opts.Margin, opts.Margin
+	cellWidth = (width - 2*opts.Margin) / float64(opts.Columns)
+	cellHeight = (height - 2*opts.Margin) / float64(rows)
+	return left, top, cellWidth, cellHeight
+}
+
+// cellRect returns where the cell in slot s of a page of rows rows is
+// drawn: in the grid, or on its sticker of Options.Stock.
+func cellRect(opts Options, rows int, s slot) (x, y, width, height float64) {
+	if st := opts.Stock; st != nil {
+		mm := opts.DPI / 25.4
+		return (st.Left + float64(s.col)*st.PitchX) * mm, (st.Top + float64(s.row)*st.PitchY) * mm, st.Diameter * mm, st.Diameter * mm
+	}
+	left, top, cellWidth, cellHeight := grid(opts, rows)
+	span := float64(s.span)
+	return opts.snapped(left+float64(s.col)*cellWidth, top+float64(s.row)*cellHeight, cellWidth*span, cellHeight*span)
+}
+
+// codeSize returns the size of the QR codes in cells of the given size.
+func (o Options) codeSize(cellWidth, cellHeight float64) int {
+	if o.Stock != nil {
+		size, _, _ := stickerLayout(o, cellWidth)
+		return size
+	}
+	if o.CellStyle == CellHorizontal {
+		// Nearly the cell's height, leaving over half its width for text.
+		return int(math.Min(cellWidth*0.45, cellHeight*0.85))
+	}
+	if o.CellStyle == CellCompact {
+		// Room underneath for the label and its descenders.
+		return int(math.Min(cellWidth*0.85, cellHeight-11*o.textScale()-20))
+	}
+	// QR codes are square; size them to fit comfortably in each cell.
+	return int(math.Min(cellWidth, cellHeight) * 0.6)
+}
+
+// render renders a page of a sheet.
+func render(opts Options, pages [][]Cell, page int) *gg.Context {
+	width := int(opts.WidthInches * opts.DPI)
+	height := int(opts.HeightInches * opts.DPI)
+
+	dc := gg.NewContext(width, height)
+
+	// Background
+	dc.SetColor(opts.Theme.Background)
+	dc.Clear()
+	drawBackdrop(dc, opts)
+
+	margin := opts.Margin
+	ts := opts.textScale()
+
+	// Title
+	if !opts.Bare {
+		dc.SetColor(opts.Theme.Text)
+		dc.SetFontFace(opts.Fonts.title(24 * ts))
+		// Shrink titles too long for the page (and logo) to fit.
+		fit := float64(width) - margin - 2*logoSpace(opts)
+		if w, _ := dc.MeasureString(opts.Title); w > fit {
+			dc.SetFontFace(opts.Fonts.title(24 * ts * fit / w))
+		}
+		drawString(dc, opts.Title, float64(width)/2, margin/2, 0.5, 0.5)
+		if opts.Logo != nil {
+			tw, _ := dc.MeasureString(opts.Title)
+			drawLogo(dc, opts, tw)
+		}
+		if opts.Subtitle != "" {
+			dc.SetFontFace(opts.Fonts.title(10 * ts))
+			drawString(dc, opts.Subtitle, float64(width)/2, margin/2+20*ts, 0.5, 0.5)
+		}
+	}
+
+	for _, p := range placeCells(opts, pages, page) {
+		draw := drawCell
+		if opts.Cache != nil {
+			draw = drawCached
+		}
+		if err := draw(dc, opts, p.cell, p.x, p.y, p.width, p.height); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+
+	if opts.hasFooter() {
+		drawFooter(dc, opts)
+	}
+	if opts.DigitalCopy != "" {
+		drawDigitalCopy(dc, opts)
+	}
+	var corner []string
+	if opts.Revision != "" {
+		corner = append(corner, opts.Revision)
+	}
+	if opts.Fingerprint != "" {
+		corner = append(corner, Expand(opts.Strings.Fingerprint, "Fingerprint {fingerprint}", "fingerprint", opts.Fingerprint))
+	}
+	if len(corner) > 0 {
+		dc.SetColor(opts.Theme.Text)
+		dc.SetFontFace(opts.Fonts.description(7 * ts))
+		dc.DrawStringAnchored(strings.Join(corner, " · "), margin, float64(height)-12, 0, 0)
+	}
+	if opts.PageNumbers && len(pages) > 1 {
+		dc.SetColor(opts.Theme.Text)
+		dc.SetFontFace(opts.Fonts.description(7 * ts))
+		dc.DrawStringAnchored(pageNumber(opts, pages, page), float64(width)-margin, float64(height)-12, 1, 0)
+	}
+	if opts.Tent {
+		return gg.NewContextForRGBA(tent(opts, dc.Image()))
+	}
+	return dc
+}
+
+// placedCell is a cell and where it is drawn on its page.
+type placedCell struct {
+	cell                Cell
+	x, y, width, height float64
+}
+
+// placeCells returns the cells of page as they are drawn.
+func placeCells(opts Options, pages [][]Cell, page int) []placedCell {
+	cells := pages[page]
+	slots, rows := place(opts, cells)
+	placed := make([]placedCell, len(cells))
+	for i, cell := range cells {
+		cell.continued = i == 0 && opts.PageNumbers && continues(pages, page)
+		p := placedCell{cell: cell}
+		p.x, p.y, p.width, p.height = cellRect(opts, rows, slots[i])
+		placed[i] = p
+	}
+	return placed
+}
+
+// pageNumber returns the page number line of page i, e.g. "Page 2 of 5 –
+// moderation (continued), deploy".
+func pageNumber(opts Options, pages [][]Cell, i int) string {
+	s := Expand(opts.Strings.Page, "Page {page} of {pages}", "page", strconv.Itoa(i+1), "pages", strconv.Itoa(len(pages)))
+	var categories []string
+	for j, cell := range pages[i] {
+		if cell.Category == "" || j > 0 && cell.Category == pages[i][j-1].Category {
+			continue
+		}
+		if continues(pages, i) && j == 0 {
+			categories = append(categories, opts.Strings.continued(cell.Category))
+		} else {
+			categories = append(categories, cell.Category)
+		}
+	}
+	if len(categories) > 0 {
+		s += " – " + strings.Join(categories, ", ")
+	}
+	return s
+}
+
+// continues reports whether page i starts part way through a category.
+func continues(pages [][]Cell, i int) bool {
+	if i == 0 || len(pages[i]) == 0 || len(pages[i-1]) == 0 {
+		return false
+	}
+	prev := pages[i-1][len(pages[i-1])-1].Category
+	return prev != "" && prev == pages[i][0].Category
+}
+
+// drawCell draws a cell with its top left corner at (x, y).
+func drawCell(dc *gg.Context, opts Options, cell Cell, x, y, cellWidth, cellHeight float64) error {
+	if opts.Stock != nil {
+		return drawSticker(dc, opts, cell, x, y, cellWidth)
+	}
+	ts := opts.textScale()
+	cx := x + cellWidth/2
+
+	// Light cell boundary, or the category's accent
+	border := opts.Theme.borderWidth()
+	dc.SetColor(opts.Theme.Border)
+	if cell.Accent != nil {
+		border = 3
+		dc.SetColor(cell.Accent)
+	}
+	strokeRectangle(dc, opts, x, y, cellWidth, cellHeight, border)
+	if cell.continued {
+		dc.SetFontFace(opts.Fonts.label(7 * ts))
+		if cell.Accent == nil {
+			dc.SetColor(opts.Theme.Text)
+		}
+		drawString(dc, opts.Strings.continued(cell.Category), x+8, y+6, 0, 1)
+	} else if cell.Accent != nil && cell.Category != "" {
+		dc.SetFontFace(opts.Fonts.label(7 * ts))
+		drawString(dc, cell.Category, x+8, y+6, 0, 1)
+	}
+	if cell.Number != "" {
+		dc.SetColor(opts.Theme.Text)
+		dc.SetFontFace(opts.Fonts.label(11 * ts))
+		drawString(dc, cell.Number, x+8, y+cellHeight-6, 0, 0)
+	}
+
+	if opts.CellStyle == CellHorizontal {
+		return drawHorizontal(dc, opts, cell, x, y, cellWidth, cellHeight)
+	}
+
+	// --- Barcode generation ---
+	qrSize := opts.codeSize(cellWidth, cellHeight)
+	scaled, err := opts.encode(cell, qrSize, int(cellWidth*0.85))
+	if err != nil {
+		return err
+	}
+
+	// Draw barcode near the top of the cell
+	bx := cx - float64(scaled.Bounds().Dx())/2
+	by := y + 6
+	dc.DrawImage(scaled, int(bx), int(by))
+
+	// Label under barcode
+	// The label is drawn on its baseline; make room for text taller
+	// than the default 11px.
+	labelY := by + float64(scaled.Bounds().Dy()) + 8 + 11*(ts-1)
+	labelColor := opts.Theme.Text
+	if cell.Accent != nil {
+		labelColor = cell.Accent
+	}
+	dc.SetColor(labelColor)
+	dc.SetFontFace(opts.Fonts.label(11 * ts))
+	label := cell.label()
+	if cell.Icon != nil {
+		drawIconLabel(dc, opts, cell.Icon, label, labelColor, cx, labelY, 0.5)
+	} else {
+		drawString(dc, label, cx, labelY, 0.5, 0)
+	}
+
+	// Description under label
+	if opts.CellStyle == CellCompact {
+		cell.Description = ""
+	}
+	dc.SetColor(opts.Theme.Text)
+	descY := labelY + 12*ts
+	dc.SetFontFace(opts.Fonts.description(8 * ts))
+	drawDescription(dc, opts, cell.Description, x+6, descY, cellWidth-12, gg.AlignCenter)
+
+	if cell.Extra != "" {
+		// Fit it in the margin beside the main code so neither is
+		// obscured.
+		side := int(cellWidth-float64(scaled.Bounds().Dx()))/2 - 12
+		drawExtra(dc, opts, cell, x+cellWidth, by, min(qrSize/3, side))
+	}
+	if cell.Mark != "" {
+		drawMark(dc, opts, cell, x, y, cellWidth, cellHeight)
+	}
+	return nil
+}
+
+// drawHorizontal draws the inside of a cell in the CellHorizontal style:
+// the code on the left, the label and description to its right, centred
+// together on the code.
+func drawHorizontal(dc *gg.Context, opts Options, cell Cell, x, y, cellWidth, cellHeight float64) error {
+	ts := opts.textScale()
+	qrSize := opts.codeSize(cellWidth, cellHeight)
+	scaled, err := opts.encode(cell, qrSize, qrSize)
+	if err != nil {
+		return err
+	}
+	codeWidth, codeHeight := float64(scaled.Bounds().Dx()), float64(scaled.Bounds().Dy())
+	pad := math.Min((cellHeight-codeHeight)/2, cellWidth*0.05)
+	bx, by := x+pad, y+(cellHeight-codeHeight)/2
+	dc.DrawImage(scaled, int(bx), int(by))
+
+	tx := bx + codeWidth + pad
+	textWidth := x + cellWidth - 8 - tx
+	if cell.Extra != "" {
+		size := min(qrSize/3, int(textWidth/3))
+		drawExtra(dc, opts, cell, x+cellWidth, by, size)
+		textWidth -= float64(size) + 6
+	}
+
+	// Centre the label and description beside the code, spaced as in
+	// stacked cells.
+	textHeight := 11 * ts
+	if cell.Description != "" {
+		dc.SetFontFace(opts.Fonts.description(8 * ts))
+		textHeight += 12*ts + descriptionHeight(dc, opts, cell.Description, textWidth)
+	}
+	labelY := y + (cellHeight-textHeight)/2 + 11*ts
+
+	labelColor := opts.Theme.Text
+	if cell.Accent != nil {
+		labelColor = cell.Accent
+	}
+	dc.SetColor(labelColor)
+	dc.SetFontFace(opts.Fonts.label(11 * ts))
+	if cell.Icon != nil {
+		drawIconLabel(dc, opts, cell.Icon, cell.label(), labelColor, tx, labelY, 0)
+	} else {
+		drawString(dc, cell.label(), tx, labelY, 0, 0)
+	}
+
+	dc.SetColor(opts.Theme.Text)
+	dc.SetFontFace(opts.Fonts.description(8 * ts))
+	drawDescription(dc, opts, cell.Description, tx, labelY+12*ts, textWidth, gg.AlignLeft)
+
+	if cell.Mark != "" {
+		drawMark(dc, opts, cell, x, y, cellWidth, cellHeight)
+	}
+	return nil
+}
+
+// drawMark outlines the cell in its mark colour, inside the boundary, with
+// the mark on a badge in the bottom right corner.
+func drawMark(dc *gg.Context, opts Options, cell Cell, x, y, cellWidth, cellHeight float64) {
+	ts := opts.textScale()
+	markColor := cell.MarkColor
+	if markColor == nil {
+		markColor = opts.Theme.Text
+	}
+	dc.SetColor(markColor)
+	strokeRectangle(dc, opts, x+3, y+3, cellWidth-6, cellHeight-6, 4)
+
+	dc.SetFontFace(opts.Fonts.label(10 * ts))
+	w, h := dc.MeasureString(cell.Mark)
+	pad := 4 * ts
+	bw, bh := w+2*pad, h+2*pad
+	bx, by := x+cellWidth-5-bw, y+cellHeight-5-bh
+	dc.DrawRectangle(bx, by, bw, bh)
+	dc.Fill()
+	dc.SetColor(opts.Theme.Background)
+	drawString(dc, cell.Mark, bx+bw/2, by+bh/2, 0.5, 0.5)
+}
+
+// encode renders the cell's barcode. QR codes are size x size pixels, linear
+// codes are as wide as maxWidth allows and a third of size high.
+func encode(cell Cell, size, maxWidth int) (image.Image, error) {
+	switch cell.Symbology {
+	case "", QR:
+		raw, err := qr.Encode(cell.Payload, qr.M, qr.Auto)
+		if err != nil {
+			return nil, fmt.Errorf("QR encode error for %q: %v", cell.Payload, err)
+		}
+		scaled, err := barcode.Scale(raw, size, size)
+		if err != nil {
+			return nil, fmt.Errorf("QR scale error for %q: %v", cell.Payload, err)
+		}
+		return scaled, nil
+	case Code128:
+		raw, err := code128.Encode(cell.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("Code 128 encode error for %q: %v", cell.Payload, err)
+		}
+		scaled, err := barcode.Scale(raw, maxWidth, size/3)
+		if err != nil {
+			return nil, fmt.Errorf("Code 128 scale error for %q: %v", cell.Payload, err)
+		}
+		return scaled, nil
+	}
+	return nil, fmt.Errorf("unknown symbology %q for %q", cell.Symbology, cell.Payload)
+}
+
+// drawExtra draws the cell's secondary QR code with its top right corner at
+// (right, top) and the caption underneath.
+func drawExtra(dc *gg.Context, opts Options, cell Cell, right, top float64, size int) {
+	img, err := opts.encode(Cell{Payload: cell.Extra}, size, size)
+	if err != nil {
+		log.Printf("%v", err)
+		return
+	}
+	x := right - 6 - float64(img.Bounds().Dx())
+	dc.DrawImage(img, int(x), int(top))
+	if cell.ExtraCaption != "" {
+		dc.SetColor(opts.Theme.Text)
+		dc.SetFontFace(opts.Fonts.description(7 * opts.textScale()))
+		drawString(dc, cell.ExtraCaption, x+float64(img.Bounds().Dx())/2, top+float64(img.Bounds().Dy())+8*opts.textScale(), 0.5, 0)
+	}
+}
+
+// FooterLink is a small captioned code at the bottom of the page.
+type FooterLink struct {
+	Caption string // URL if empty
+	URL     string
+}
+
+func (l FooterLink) caption() string {
+	if l.Caption == "" {
+		return l.URL
+	}
+	return l.Caption
+}
+
+// footerLinks returns the codes at the bottom of the page, the footer's
+// first.
+func (o Options) footerLinks() []FooterLink {
+	var links []FooterLink
+	if o.Footer != "" {
+		links = append(links, FooterLink{URL: o.Footer})
+	}
+	return append(links, o.FooterLinks...)
+}
+
+func (o Options) hasFooter() bool {
+	return o.Footer != "" || len(o.FooterLinks) > 0 || o.Disclaimer != ""
+}
+
+// footerSize returns the size of the footer QR on a page width pixels wide.
+func footerSize(opts Options, width int) int {
+	// Keep the QR comfortably inside the bottom margin
+	return int(math.Min(float64(width)*0.18, opts.Margin*0.8))
+}
+
+// drawFooter draws the footer QR codes side by side with their captions,
+// and the disclaimer under them.
+func drawFooter(dc *gg.Context, opts Options) {
+	width, height := float64(dc.Width()), float64(dc.Height())
+	margin := opts.Margin
+	ts := opts.textScale()
+
+	// Footer text just above the very bottom of the page
+	textY := height - 12
+	dc.SetColor(opts.Theme.Text)
+	if opts.Disclaimer != "" {
+		dc.SetFontFace(opts.Fonts.description(8 * ts))
+		drawString(dc, opts.Disclaimer, width/2, textY, 0.5, 0)
+		textY -= 14 * ts
+	}
+
+	size := footerSize(opts, dc.Width())
+	links := opts.footerLinks()
+	// Each code is centred over its caption, in a slot as wide as the
+	// wider of the two; the row of slots is centred on the page.
+	dc.SetFontFace(opts.Fonts.description(9 * ts))
+	gap := float64(size) / 4
+	slots := make([]float64, len(links))
+	total := gap * float64(len(links)-1)
+	for i, link := range links {
+		w, _ := dc.MeasureString(link.caption())
+		slots[i] = math.Max(float64(size), w)
+		total += slots[i]
+	}
+	x := width/2 - total/2
+	for i, link := range links {
+		footerScaled, err := opts.encode(Cell{Payload: link.URL}, size, size)
+		if err != nil {
+			log.Printf("footer: %v", err)
+			return
+		}
+
+		// Place QR above bottom margin
+		cx := x + slots[i]/2
+		fbX := cx - float64(footerScaled.Bounds().Dx())/2
+		fbY := height - margin - float64(size) - 10
+		dc.DrawImage(footerScaled, int(fbX), int(fbY))
+
+		dc.SetColor(opts.Theme.Text)
+		dc.DrawStringAnchored(link.caption(), cx, textY, 0.5, 0)
+		x += slots[i] + gap
+	}
+}
+
+// drawDigitalCopy draws the DigitalCopy code in the bottom right corner,
+// level with the footer's, captioned above the page number.
+func drawDigitalCopy(dc *gg.Context, opts Options) {
+	width, height := float64(dc.Width()), float64(dc.Height())
+	ts := opts.textScale()
+	size := footerSize(opts, dc.Width())
+	img, err := opts.encode(Cell{Payload: opts.DigitalCopy}, size, size)
+	if err != nil {
+		log.Printf("digital copy: %v", err)
+		return
+	}
+	right := width - opts.Margin
+	dc.DrawImage(img, int(right)-img.Bounds().Dx(), int(height-opts.Margin-float64(size)-10))
+	dc.SetColor(opts.Theme.Text)
+	dc.SetFontFace(opts.Fonts.description(9 * ts))
+	dc.DrawStringAnchored(Expand(opts.Strings.DigitalCopy, "Latest version"), right, height-12-14*ts, 1, 0)
+}
@@ -0,0 +1,65 @@
+package sheet
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+// scanRatio is how many module widths away phones read codes from: a
+// typical code of 25 modules scans from ten times its width.
+const scanRatio = 250
+
+// AtDPI returns opts for rendering at dpi pixels per inch, scaling the
+// margin, text and logo so the printed page looks the same.
+func AtDPI(opts Options, dpi float64) Options {
+	f := dpi / opts.DPI
+	opts.Margin *= f
+	opts.TextScale = opts.textScale() * f
+	opts.LogoHeight *= f
+	opts.DPI = dpi
+	return opts
+}
+
+// SizeWarning returns a warning if the cells' QR codes print too small to
+// scan from distance millimetres away, or if distance is 0 with modules
+// under 0.4 mm; otherwise "".
+func SizeWarning(opts Options, cells []Cell, distance float64) string {
+	want := math.Max(minModuleMM, distance/scanRatio)
+	small, total := 0, 0
+	smallest, smallestSize := math.Inf(1), 0.0
+	for _, page := range paginate(opts, cells) {
+		slots, rows := place(opts, page)
+		for i, cell := range page {
+			_, _, cellWidth, cellHeight := cellRect(opts, rows, slots[i])
+			size := opts.Theme.symbolSize(opts.codeSize(cellWidth, cellHeight))
+			if cell.Symbology != "" && cell.Symbology != QR {
+				continue
+			}
+			raw, err := qr.Encode(cell.Payload, qr.M, qr.Auto)
+			if err != nil {
+				continue // reported when rendering
+			}
+			// Codes are scaled by whole pixels per module.
+			dim := raw.Bounds().Dx()
+			module := float64(opts.modulePixels(size, dim)) / opts.DPI * 25.4
+			total++
+			if module < want {
+				small++
+			}
+			if module < smallest {
+				smallest, smallestSize = module, module*float64(dim)
+			}
+		}
+	}
+	if small == 0 {
+		return ""
+	}
+	from := "close up"
+	if distance > 0 {
+		from = fmt.Sprintf("from %.0f cm", distance/10)
+	}
+	return fmt.Sprintf("%d of %d codes print too small to scan %s: the smallest is %.1f cm across with %.2f mm modules, want %.2f mm (%.1f cm at that density); use fewer columns, -large-print or shorter payloads",
+		small, total, from, smallestSize/10, smallest, want, want*smallestSize/smallest/10)
+}
@@ -0,0 +1,206 @@
+package sheet
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/fogleman/gg"
+)
+
+// Stats summarises the codes of a sheet, for deciding which messages earn
+// their space on it.
+type Stats struct {
+	Categories []CategoryCount // in order of first appearance
+	Codes      []CodeStats     // QR codes, in sheet order
+
+	AveragePayload float64     // bytes, over every cell
+	Versions       map[int]int // number of QR codes of each version
+}
+
+// CategoryCount is the number of cells of a category, "" for cells without
+// one.
+type CategoryCount struct {
+	Category string
+	Cells    int
+}
+
+// CodeStats is one QR code of a sheet.
+type CodeStats struct {
+	Cell     int // index into the cells
+	Label    string
+	Payload  int // bytes
+	Version  int
+	Modules  int // across
+	ModuleMM float64
+}
+
+// Summarize returns the stats of a sheet of cells.
+func Summarize(opts Options, cells []Cell) Stats {
+	s := Stats{Versions: map[int]int{}}
+	counts := map[string]int{}
+	total, i := 0, 0
+	pages := paginate(opts, cells)
+	for page := range pages {
+		for _, p := range placeCells(opts, pages, page) {
+			cell := p.cell
+			if counts[cell.Category] == 0 {
+				s.Categories = append(s.Categories, CategoryCount{Category: cell.Category})
+			}
+			counts[cell.Category]++
+			total += len(cell.Payload)
+			if cell.Symbology == "" || cell.Symbology == QR {
+				if raw, err := qr.Encode(cell.Payload, qr.M, qr.Auto); err == nil {
+					dim := raw.Bounds().Dx()
+					size := opts.Theme.symbolSize(opts.codeSize(p.width, p.height))
+					c := CodeStats{
+						Cell:     i,
+						Label:    cell.label(),
+						Payload:  len(cell.Payload),
+						Version:  (dim - 17) / 4,
+						Modules:  dim,
+						ModuleMM: float64(opts.modulePixels(size, dim)) / opts.DPI * 25.4,
+					}
+					s.Codes = append(s.Codes, c)
+					s.Versions[c.Version]++
+				}
+			}
+			i++
+		}
+	}
+	for j := range s.Categories {
+		s.Categories[j].Cells = counts[s.Categories[j].Category]
+	}
+	if len(cells) > 0 {
+		s.AveragePayload = float64(total) / float64(len(cells))
+	}
+	return s
+}
+
+// Largest returns up to n codes with the most modules, longest payloads
+// first among equals.
+func (s Stats) Largest(n int) []CodeStats {
+	codes := slices.Clone(s.Codes)
+	slices.SortStableFunc(codes, func(a, b CodeStats) int {
+		return cmp.Or(cmp.Compare(b.Modules, a.Modules), cmp.Compare(b.Payload, a.Payload))
+	})
+	return codes[:min(n, len(codes))]
+}
+
+// Densest returns up to n codes with the smallest modules.
+func (s Stats) Densest(n int) []CodeStats {
+	codes := slices.Clone(s.Codes)
+	slices.SortStableFunc(codes, func(a, b CodeStats) int {
+		return cmp.Compare(a.ModuleMM, b.ModuleMM)
+	})
+	return codes[:min(n, len(codes))]
+}
+
+// RenderStats renders a summary page of the stats of a sheet of cells, on
+// a page like the sheet's.
+func RenderStats(opts Options, cells []Cell) (image.Image, error) {
+	renderMu.Lock()
+	defer renderMu.Unlock()
+	if err := opts.Fonts.load(); err != nil {
+		return nil, err
+	}
+	s := Summarize(opts, cells)
+	width := int(opts.WidthInches * opts.DPI)
+	height := int(opts.paperHeight() * opts.DPI)
+	ts := opts.textScale()
+	margin := opts.Margin
+	str := opts.Strings
+
+	dc := gg.NewContext(width, height)
+	dc.SetColor(opts.Theme.Background)
+	dc.Clear()
+	dc.SetColor(opts.Theme.Text)
+	dc.SetFontFace(opts.Fonts.title(24 * ts))
+	drawString(dc, Expand(str.Summary, "Summary – {title}", "title", opts.Title), float64(width)/2, margin/2, 0.5, 0.5)
+
+	y := margin + 20*ts
+	line := func(size float64, s string) {
+		dc.SetFontFace(opts.Fonts.description(size * ts))
+		drawString(dc, s, margin, y, 0, 0)
+		y += size * ts * 1.6
+	}
+	heading := func(s string) {
+		y += 10 * ts
+		dc.SetFontFace(opts.Fonts.label(20 * ts))
+		drawString(dc, s, margin, y, 0, 0)
+		y += 20 * ts * 1.6
+	}
+	// bars draws a labelled bar per count, scaled to the largest.
+	barWidth := (float64(width) - 2*margin) / 2
+	bars := func(labels []string, counts []int) {
+		most := slices.Max(counts)
+		for j, label := range labels {
+			w := barWidth * float64(counts[j]) / float64(most)
+			dc.SetFontFace(opts.Fonts.description(14 * ts))
+			drawString(dc, label, margin, y, 0, 0)
+			dc.DrawRectangle(margin+180*ts, y-12*ts, w, 14*ts)
+			dc.Fill()
+			drawString(dc, fmt.Sprint(counts[j]), margin+190*ts+w, y, 0, 0)
+			y += 22 * ts
+		}
+	}
+
+	line(16, Expand(str.SummaryTotals, "{messages} messages, {codes} QR codes, payloads {average} bytes long on average",
+		"messages", strconv.Itoa(len(cells)), "codes", strconv.Itoa(len(s.Codes)), "average", fmt.Sprintf("%.0f", s.AveragePayload)))
+
+	heading(Expand(str.PerCategory, "Messages per category"))
+	var labels []string
+	var counts []int
+	for _, c := range s.Categories {
+		name := c.Category
+		if name == "" {
+			name = Expand(str.NoCategory, "(none)")
+		}
+		labels, counts = append(labels, name), append(counts, c.Cells)
+	}
+	if len(counts) > 0 {
+		bars(labels, counts)
+	}
+
+	if len(s.Versions) > 0 {
+		heading(Expand(str.Versions, "QR versions"))
+		labels, counts = nil, nil
+		versions := make([]int, 0, len(s.Versions))
+		for v := range s.Versions {
+			versions = append(versions, v)
+		}
+		slices.Sort(versions)
+		for _, v := range versions {
+			labels = append(labels, Expand(str.Version, "Version {version} ({modules}×{modules})", "version", strconv.Itoa(v), "modules", strconv.Itoa(v*4+17)))
+			counts = append(counts, s.Versions[v])
+		}
+		bars(labels, counts)
+	}
+
+	codes := func(title string, codes []CodeStats) {
+		if len(codes) == 0 {
+			return
+		}
+		heading(title)
+		for _, c := range codes {
+			line(14, Expand(str.Code, "{label} – version {version}, {bytes} bytes, {module} mm modules",
+				"label", truncate(c.Label, 60), "version", strconv.Itoa(c.Version), "bytes", strconv.Itoa(c.Payload), "module", fmt.Sprintf("%.2f", c.ModuleMM)))
+		}
+	}
+	codes(Expand(str.Largest, "Largest codes"), s.Largest(5))
+	codes(Expand(str.Densest, "Densest codes"), s.Densest(5))
+	return dc.Image(), nil
+}
+
+// truncate shortens s to at most n characters, ending in an ellipsis if cut.
+func truncate(s string, n int) string {
+	r := []rune(strings.TrimSpace(s))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n-1]) + "…"
+}
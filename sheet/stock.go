@@ -0,0 +1,137 @@
+package sheet
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Stock is a page of round stickers for label printers' die-cut stock:
+// Columns by Rows circles Diameter across, the first with its edge Left
+// and Top from the corner of the page, the others PitchX and PitchY apart,
+// centre to centre. Lengths are in millimetres.
+type Stock struct {
+	PageWidth  float64 `json:"page_width"`
+	PageHeight float64 `json:"page_height"`
+	Diameter   float64 `json:"diameter"`
+	Columns    int     `json:"columns"`
+	Rows       int     `json:"rows"`
+	Left       float64 `json:"left"`
+	Top        float64 `json:"top"`
+	PitchX     float64 `json:"pitch_x"`
+	PitchY     float64 `json:"pitch_y"`
+	// Safe is kept clear inside the cut, so a sticker cut slightly off
+	// centre still shows its whole code and label.
+	Safe float64 `json:"safe"`
+}
+
+// Stocks are common sticker stocks by name.
+var Stocks = map[string]Stock{
+	// 35 circles 37 mm across on A4.
+	"round-37mm-35": {PageWidth: 210, PageHeight: 297, Diameter: 37, Columns: 5, Rows: 7, Left: 8.5, Top: 10, PitchX: 39, PitchY: 40, Safe: 2},
+	// 24 circles 40 mm across on A4.
+	"round-40mm-24": {PageWidth: 210, PageHeight: 297, Diameter: 40, Columns: 4, Rows: 6, Left: 14.5, Top: 16, PitchX: 47, PitchY: 45, Safe: 2},
+	// 20 circles 1½ inches across on US Letter.
+	"round-1.5in-20": {PageWidth: 215.9, PageHeight: 279.4, Diameter: 38.1, Columns: 4, Rows: 5, Left: 12.7, Top: 19.05, PitchX: 50.8, PitchY: 50.8, Safe: 2},
+}
+
+// WithStock returns opts printing onto stock: a page of its size with a
+// cell on each sticker, continuing on more pages, and no title or footer,
+// which would print across the stickers. Stickers show a code and its
+// label; descriptions, extra codes and marks are left off, and every cell
+// is one sticker whatever its Span.
+func WithStock(opts Options, stock Stock) Options {
+	opts.Stock = &stock
+	opts.WidthInches, opts.HeightInches = stock.PageWidth/25.4, stock.PageHeight/25.4
+	opts.Columns, opts.Rows, opts.PageRows = stock.Columns, stock.Rows, stock.Rows
+	opts.Footer, opts.FooterLinks, opts.Disclaimer, opts.DigitalCopy = "", nil, "", ""
+	opts.Bare = true
+	return opts
+}
+
+// stickerLayout returns the size of the code on a sticker diameter pixels
+// across, how far above the sticker's centre it is drawn, and the size of
+// the label curved under it: the largest code inside the safe area that
+// leaves a band for the label along the bottom.
+func stickerLayout(opts Options, diameter float64) (code int, lift, label float64) {
+	r := diameter/2 - opts.Stock.Safe*opts.DPI/25.4
+	label = diameter * 0.075
+	inner := r - label*1.4
+	if inner <= 0 {
+		return 0, 0, label
+	}
+	best := 0.0
+	// The top corners must be inside the safe area, the bottom ones above
+	// the label.
+	for i := 0; i <= 64; i++ {
+		d := inner * float64(i) / 64
+		q := math.Min(-d+math.Sqrt(2*r*r-d*d), d+math.Sqrt(2*inner*inner-d*d))
+		if q > best {
+			best, lift = q, d
+		}
+	}
+	return int(best), lift, label
+}
+
+// drawSticker draws a cell on the sticker diameter pixels across with its
+// bounding square's top left corner at (x, y): its code, and its label
+// curved along the bottom, in the category's accent if it has one, which
+// also rings the safe area.
+func drawSticker(dc *gg.Context, opts Options, cell Cell, x, y, diameter float64) error {
+	cx, cy := x+diameter/2, y+diameter/2
+	r := diameter/2 - opts.Stock.Safe*opts.DPI/25.4
+	code, lift, size := stickerLayout(opts, diameter)
+
+	scaled, err := opts.encode(cell, code, code)
+	if err != nil {
+		return err
+	}
+	w, h := float64(scaled.Bounds().Dx()), float64(scaled.Bounds().Dy())
+	dc.DrawImage(scaled, int(cx-w/2), int(cy-lift-h/2))
+
+	// The ring goes over the code's corners, which touch the safe area.
+	labelColor := opts.Theme.Text
+	if cell.Accent != nil {
+		labelColor = cell.Accent
+		dc.SetColor(cell.Accent)
+		dc.SetLineWidth(3)
+		dc.DrawCircle(cx, cy, r)
+		dc.Stroke()
+	}
+
+	dc.SetColor(labelColor)
+	label := cell.label()
+	// Keep the baseline clear of the ring, with room for descenders.
+	baseline := r - size*0.5
+	dc.SetFontFace(opts.Fonts.label(size))
+	if lw, _ := dc.MeasureString(label); lw > baseline*2.4 {
+		// At most a third of the way round.
+		dc.SetFontFace(opts.Fonts.label(size * baseline * 2.4 / lw))
+	}
+	if hasRTL(label) {
+		// Reordered runs can't follow the curve letter by letter.
+		drawString(dc, label, cx, cy+baseline, 0.5, 0)
+		return nil
+	}
+	drawCurved(dc, label, cx, cy, baseline)
+	return nil
+}
+
+// drawCurved draws s upright along the bottom of the circle of radius r
+// around (cx, cy), on its baseline and centred under the centre.
+func drawCurved(dc *gg.Context, s string, cx, cy, r float64) {
+	w, _ := dc.MeasureString(s)
+	// Angles are clockwise from the right, as the y axis points down, so
+	// left to right along the bottom is anticlockwise.
+	a := math.Pi/2 + w/r/2
+	for _, ch := range s {
+		adv, _ := dc.MeasureString(string(ch))
+		mid := a - adv/2/r
+		px, py := cx+r*math.Cos(mid), cy+r*math.Sin(mid)
+		dc.Push()
+		dc.RotateAbout(mid-math.Pi/2, px, py)
+		drawString(dc, string(ch), px, py, 0.5, 0)
+		dc.Pop()
+		a -= adv / r
+	}
+}
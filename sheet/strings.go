@@ -0,0 +1,42 @@
+package sheet
+
+import "strings"
+
+// Strings are the words printed around the cells, for sheets in other
+// languages. Empty strings are the English defaults shown; {name}
+// placeholders are replaced by their values.
+type Strings struct {
+	Page        string `json:"page,omitempty"`         // "Page {page} of {pages}"
+	Continued   string `json:"continued,omitempty"`    // "{category} (continued)"
+	Fingerprint string `json:"fingerprint,omitempty"`  // "Fingerprint {fingerprint}"
+	DigitalCopy string `json:"digital_copy,omitempty"` // "Latest version"
+	Fold        string `json:"fold,omitempty"`         // "fold", along a tent's fold line
+
+	// The summary page, see RenderStats.
+	Summary       string `json:"summary,omitempty"`        // "Summary – {title}"
+	SummaryTotals string `json:"summary_totals,omitempty"` // "{messages} messages, {codes} QR codes, payloads {average} bytes long on average"
+	PerCategory   string `json:"per_category,omitempty"`   // "Messages per category"
+	NoCategory    string `json:"no_category,omitempty"`    // "(none)"
+	Versions      string `json:"versions,omitempty"`       // "QR versions"
+	Version       string `json:"version,omitempty"`        // "Version {version} ({modules}×{modules})"
+	Largest       string `json:"largest,omitempty"`        // "Largest codes"
+	Densest       string `json:"densest,omitempty"`        // "Densest codes"
+	Code          string `json:"code,omitempty"`           // "{label} – version {version}, {bytes} bytes, {module} mm modules"
+}
+
+// Expand returns template, or def if template is empty, with each {name}
+// replaced by the value following name in args.
+func Expand(template, def string, args ...string) string {
+	if template == "" {
+		template = def
+	}
+	pairs := make([]string, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs[i], pairs[i+1] = "{"+args[i]+"}", args[i+1]
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+func (s Strings) continued(category string) string {
+	return Expand(s.Continued, "{category} (continued)", "category", category)
+}
@@ -0,0 +1,643 @@
+package sheet
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// RasterizeSVG draws the SVG image read from r at the given height in
+// pixels. Only the subset logos commonly use is understood: paths, basic
+// shapes, groups and transforms, with solid fills and strokes (gradients are
+// drawn in their first colour). Text, clipping, masks and filters are
+// ignored.
+func RasterizeSVG(r io.Reader, height int) (image.Image, error) {
+	d := xml.NewDecoder(r)
+	var dc *gg.Context
+	var stack []svgState
+	gradients := map[string]color.Color{}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("svg: %w", err)
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			attrs := svgAttrs(el)
+			if dc == nil {
+				if el.Name.Local != "svg" {
+					return nil, errors.New("svg: root element is not <svg>")
+				}
+				var base affine
+				if dc, base, err = svgCanvas(attrs, height); err != nil {
+					return nil, err
+				}
+				stack = append(stack, svgState{m: base, fill: color.Black, strokeWidth: 1, opacity: 1})
+				continue
+			}
+			switch el.Name.Local {
+			case "linearGradient", "radialGradient":
+				if c := firstStop(d); c != nil && attrs["id"] != "" {
+					gradients[attrs["id"]] = c
+				}
+				continue
+			case "defs":
+				collectGradients(d, gradients)
+				continue
+			case "clipPath", "mask", "symbol", "pattern", "filter", "text", "title", "desc", "metadata", "style":
+				if err := d.Skip(); err != nil {
+					return nil, fmt.Errorf("svg: %w", err)
+				}
+				continue
+			}
+			st := stack[len(stack)-1].child(attrs, gradients)
+			stack = append(stack, st)
+			if attrs["display"] == "none" || attrs["visibility"] == "hidden" {
+				continue
+			}
+			path, err := svgShape(el.Name.Local, attrs)
+			if err != nil {
+				return nil, err
+			}
+			if path != nil {
+				st.draw(dc, path)
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if dc == nil {
+		return nil, errors.New("svg: no <svg> element")
+	}
+	return dc.Image(), nil
+}
+
+// svgCanvas sizes the canvas from the root element's viewBox, or its width
+// and height, and returns the transform from user space onto it.
+func svgCanvas(attrs map[string]string, height int) (*gg.Context, affine, error) {
+	var minX, minY, w, h float64
+	if vb := svgNumbers(attrs["viewBox"]); len(vb) == 4 {
+		minX, minY, w, h = vb[0], vb[1], vb[2], vb[3]
+	} else {
+		w, h = svgLength(attrs["width"]), svgLength(attrs["height"])
+	}
+	if w <= 0 || h <= 0 {
+		return nil, affine{}, errors.New("svg: no viewBox or width and height")
+	}
+	s := float64(height) / h
+	dc := gg.NewContext(int(math.Ceil(w*s)), height)
+	return dc, affine{s, 0, 0, s, -minX * s, -minY * s}, nil
+}
+
+// affine is the SVG transform matrix(a b c d e f).
+type affine [6]float64
+
+var identity = affine{1, 0, 0, 1, 0, 0}
+
+// then returns the transform applying n, then m.
+func (m affine) then(n affine) affine {
+	return affine{
+		m[0]*n[0] + m[2]*n[1], m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3], m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4], m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m affine) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+var transformRe = regexp.MustCompile(`(\w+)\s*\(([^)]*)\)`)
+
+func parseTransform(s string) affine {
+	m := identity
+	for _, t := range transformRe.FindAllStringSubmatch(s, -1) {
+		v := append(svgNumbers(t[2]), 0, 0, 0, 0, 0, 0)
+		var n affine
+		switch t[1] {
+		case "matrix":
+			copy(n[:], v)
+		case "translate":
+			n = affine{1, 0, 0, 1, v[0], v[1]}
+		case "scale":
+			sy := v[1]
+			if len(svgNumbers(t[2])) < 2 {
+				sy = v[0]
+			}
+			n = affine{v[0], 0, 0, sy, 0, 0}
+		case "rotate":
+			a := v[0] * math.Pi / 180
+			cos, sin := math.Cos(a), math.Sin(a)
+			n = affine{1, 0, 0, 1, v[1], v[2]}.
+				then(affine{cos, sin, -sin, cos, 0, 0}).
+				then(affine{1, 0, 0, 1, -v[1], -v[2]})
+		case "skewX":
+			n = affine{1, 0, math.Tan(v[0] * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			n = affine{1, math.Tan(v[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			continue
+		}
+		m = m.then(n)
+	}
+	return m
+}
+
+// svgState is the inherited transform and paint of an element.
+type svgState struct {
+	m           affine
+	fill        color.Color // nil for none
+	stroke      color.Color
+	strokeWidth float64
+	evenOdd     bool
+	opacity     float64
+	fillOpacity float64
+	lineOpacity float64
+}
+
+// svgAttrs returns an element's attributes with its style declarations
+// applied over them.
+func svgAttrs(el xml.StartElement) map[string]string {
+	attrs := map[string]string{}
+	for _, a := range el.Attr {
+		attrs[a.Name.Local] = strings.TrimSpace(a.Value)
+	}
+	for _, decl := range strings.Split(attrs["style"], ";") {
+		if k, v, ok := strings.Cut(decl, ":"); ok {
+			attrs[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return attrs
+}
+
+func (s svgState) child(attrs map[string]string, gradients map[string]color.Color) svgState {
+	s.fillOpacity, s.lineOpacity = 1, 1
+	if t, ok := attrs["transform"]; ok {
+		s.m = s.m.then(parseTransform(t))
+	}
+	if v, ok := attrs["fill"]; ok {
+		s.fill = svgColor(v, gradients)
+	}
+	if v, ok := attrs["stroke"]; ok {
+		s.stroke = svgColor(v, gradients)
+	}
+	if v, ok := attrs["stroke-width"]; ok {
+		s.strokeWidth = svgLength(v)
+	}
+	if v, ok := attrs["fill-rule"]; ok {
+		s.evenOdd = v == "evenodd"
+	}
+	if v, err := strconv.ParseFloat(attrs["opacity"], 64); err == nil {
+		s.opacity *= v
+	}
+	if v, err := strconv.ParseFloat(attrs["fill-opacity"], 64); err == nil {
+		s.fillOpacity = v
+	}
+	if v, err := strconv.ParseFloat(attrs["stroke-opacity"], 64); err == nil {
+		s.lineOpacity = v
+	}
+	return s
+}
+
+func (s svgState) draw(dc *gg.Context, path []pathSeg) {
+	if s.fill != nil {
+		tracePath(dc, s.m, path)
+		dc.SetFillRule(gg.FillRuleWinding)
+		if s.evenOdd {
+			dc.SetFillRule(gg.FillRuleEvenOdd)
+		}
+		dc.SetColor(withAlpha(s.fill, s.opacity*s.fillOpacity))
+		dc.Fill()
+	}
+	if s.stroke != nil && s.strokeWidth > 0 {
+		tracePath(dc, s.m, path)
+		scale := math.Sqrt(math.Abs(s.m[0]*s.m[3] - s.m[1]*s.m[2]))
+		dc.SetLineWidth(s.strokeWidth * scale)
+		dc.SetColor(withAlpha(s.stroke, s.opacity*s.lineOpacity))
+		dc.Stroke()
+	}
+}
+
+func withAlpha(c color.Color, alpha float64) color.Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(float64(n.A) * math.Max(0, math.Min(1, alpha)))
+	return n
+}
+
+var svgNamedColors = map[string]color.Color{
+	"black": color.Black, "white": color.White, "currentcolor": color.Black,
+	"red": color.RGBA{255, 0, 0, 255}, "green": color.RGBA{0, 128, 0, 255},
+	"blue": color.RGBA{0, 0, 255, 255}, "yellow": color.RGBA{255, 255, 0, 255},
+	"orange": color.RGBA{255, 165, 0, 255}, "purple": color.RGBA{128, 0, 128, 255},
+	"gray": color.RGBA{128, 128, 128, 255}, "grey": color.RGBA{128, 128, 128, 255},
+	"silver": color.RGBA{192, 192, 192, 255}, "navy": color.RGBA{0, 0, 128, 255},
+	"teal": color.RGBA{0, 128, 128, 255}, "maroon": color.RGBA{128, 0, 0, 255},
+}
+
+var rgbRe = regexp.MustCompile(`^rgba?\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)`)
+
+// svgColor parses a paint, returning nil for none. Unknown paints are
+// black, as SVG renders a fill it can't resolve.
+func svgColor(v string, gradients map[string]color.Color) color.Color {
+	v = strings.ToLower(v)
+	switch {
+	case v == "none" || v == "transparent":
+		return nil
+	case strings.HasPrefix(v, "url(#"):
+		if c, ok := gradients[strings.TrimSuffix(strings.TrimPrefix(v, "url(#"), ")")]; ok {
+			return c
+		}
+	case strings.HasPrefix(v, "#"):
+		if c, err := ParseColor(v); err == nil {
+			return c
+		}
+	case rgbRe.MatchString(v):
+		m := rgbRe.FindStringSubmatch(v)
+		r, _ := strconv.Atoi(m[1])
+		g, _ := strconv.Atoi(m[2])
+		b, _ := strconv.Atoi(m[3])
+		return color.RGBA{uint8(r), uint8(g), uint8(b), 255}
+	}
+	if c, ok := svgNamedColors[v]; ok {
+		return c
+	}
+	return color.Black
+}
+
+// collectGradients reads a defs element, recording the first colour of the
+// gradients in it by ID.
+func collectGradients(d *xml.Decoder, gradients map[string]color.Color) {
+	for depth := 1; depth > 0; {
+		tok, err := d.Token()
+		if err != nil {
+			return
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if n := el.Name.Local; n == "linearGradient" || n == "radialGradient" {
+				if c := firstStop(d); c != nil {
+					gradients[svgAttrs(el)["id"]] = c
+				}
+				depth-- // firstStop consumed the end element
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// firstStop reads a gradient element and returns the colour of its first
+// stop.
+func firstStop(d *xml.Decoder) color.Color {
+	var first color.Color
+	for depth := 1; depth > 0; {
+		tok, err := d.Token()
+		if err != nil {
+			return first
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if el.Name.Local == "stop" && first == nil {
+				if c, ok := svgAttrs(el)["stop-color"]; ok {
+					first = svgColor(c, nil)
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return first
+}
+
+var numberRe = regexp.MustCompile(`^[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// svgNumbers returns the numbers in a comma and/or space separated list.
+func svgNumbers(s string) []float64 {
+	var nums []float64
+	p := pathScanner{s: s}
+	for {
+		v, ok := p.number()
+		if !ok {
+			return nums
+		}
+		nums = append(nums, v)
+	}
+}
+
+// svgLength parses a length in user units, ignoring a px unit.
+func svgLength(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSuffix(s, "px"), 64)
+	return v
+}
+
+// pathSeg is a path command in absolute user coordinates: M, L and C take
+// one, one and three points, Z none.
+type pathSeg struct {
+	op  byte
+	pts []float64
+}
+
+func tracePath(dc *gg.Context, m affine, path []pathSeg) {
+	dc.ClearPath()
+	for _, seg := range path {
+		p := make([]float64, len(seg.pts))
+		for i := 0; i < len(p); i += 2 {
+			p[i], p[i+1] = m.apply(seg.pts[i], seg.pts[i+1])
+		}
+		switch seg.op {
+		case 'M':
+			dc.MoveTo(p[0], p[1])
+		case 'L':
+			dc.LineTo(p[0], p[1])
+		case 'C':
+			dc.CubicTo(p[0], p[1], p[2], p[3], p[4], p[5])
+		case 'Z':
+			dc.ClosePath()
+		}
+	}
+}
+
+// svgShape returns the outline of a shape element, nil for other elements.
+func svgShape(name string, attrs map[string]string) ([]pathSeg, error) {
+	num := func(k string) float64 { return svgLength(attrs[k]) }
+	switch name {
+	case "path":
+		return parsePath(attrs["d"])
+	case "rect":
+		x, y, w, h := num("x"), num("y"), num("width"), num("height")
+		rx, ry := num("rx"), num("ry")
+		if rx == 0 {
+			rx = ry
+		}
+		if ry == 0 {
+			ry = rx
+		}
+		rx, ry = math.Min(rx, w/2), math.Min(ry, h/2)
+		if rx == 0 {
+			return []pathSeg{{'M', []float64{x, y}}, {'L', []float64{x + w, y}}, {'L', []float64{x + w, y + h}}, {'L', []float64{x, y + h}}, {'Z', nil}}, nil
+		}
+		p := []pathSeg{{'M', []float64{x + rx, y}}, {'L', []float64{x + w - rx, y}}}
+		p = append(p, arcSegs(x+w-rx, y, rx, ry, 0, false, true, x+w, y+ry)...)
+		p = append(p, pathSeg{'L', []float64{x + w, y + h - ry}})
+		p = append(p, arcSegs(x+w, y+h-ry, rx, ry, 0, false, true, x+w-rx, y+h)...)
+		p = append(p, pathSeg{'L', []float64{x + rx, y + h}})
+		p = append(p, arcSegs(x+rx, y+h, rx, ry, 0, false, true, x, y+h-ry)...)
+		p = append(p, pathSeg{'L', []float64{x, y + ry}})
+		p = append(p, arcSegs(x, y+ry, rx, ry, 0, false, true, x+rx, y)...)
+		return append(p, pathSeg{'Z', nil}), nil
+	case "circle", "ellipse":
+		cx, cy, rx, ry := num("cx"), num("cy"), num("rx"), num("ry")
+		if name == "circle" {
+			rx, ry = num("r"), num("r")
+		}
+		p := []pathSeg{{'M', []float64{cx + rx, cy}}}
+		p = append(p, arcSegs(cx+rx, cy, rx, ry, 0, false, true, cx-rx, cy)...)
+		p = append(p, arcSegs(cx-rx, cy, rx, ry, 0, false, true, cx+rx, cy)...)
+		return append(p, pathSeg{'Z', nil}), nil
+	case "line":
+		return []pathSeg{{'M', []float64{num("x1"), num("y1")}}, {'L', []float64{num("x2"), num("y2")}}}, nil
+	case "polyline", "polygon":
+		pts := svgNumbers(attrs["points"])
+		var p []pathSeg
+		for i := 0; i+1 < len(pts); i += 2 {
+			op := byte('L')
+			if i == 0 {
+				op = 'M'
+			}
+			p = append(p, pathSeg{op, pts[i : i+2]})
+		}
+		if name == "polygon" && len(p) > 0 {
+			p = append(p, pathSeg{'Z', nil})
+		}
+		return p, nil
+	}
+	return nil, nil
+}
+
+// pathScanner reads the numbers and flags of path data.
+type pathScanner struct {
+	s string
+	i int
+}
+
+func (p *pathScanner) skip() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n,", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *pathScanner) number() (float64, bool) {
+	p.skip()
+	m := numberRe.FindString(p.s[p.i:])
+	if m == "" {
+		return 0, false
+	}
+	p.i += len(m)
+	v, err := strconv.ParseFloat(m, 64)
+	return v, err == nil
+}
+
+// flag reads an arc flag, which may be written without a separator.
+func (p *pathScanner) flag() (bool, bool) {
+	p.skip()
+	if p.i < len(p.s) && (p.s[p.i] == '0' || p.s[p.i] == '1') {
+		p.i++
+		return p.s[p.i-1] == '1', true
+	}
+	return false, false
+}
+
+// parsePath converts path data to absolute M, L, C and Z segments.
+func parsePath(d string) ([]pathSeg, error) {
+	p := &pathScanner{s: d}
+	var path []pathSeg
+	var x, y, startX, startY, ctrlX, ctrlY float64
+	var cmd, prev byte
+	bad := func() ([]pathSeg, error) {
+		return nil, fmt.Errorf("svg: bad path data near %q", d[:min(p.i+10, len(d))])
+	}
+	for {
+		p.skip()
+		if p.i >= len(d) {
+			return path, nil
+		}
+		if c := d[p.i]; strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", c) >= 0 {
+			cmd = c
+			p.i++
+		} else if cmd == 0 {
+			return bad()
+		}
+		rel := cmd >= 'a'
+		var dx, dy float64
+		if rel {
+			dx, dy = x, y
+		}
+		// nums reads n coordinates, relative numbers made absolute in pairs.
+		nums := func(n int) ([]float64, bool) {
+			v := make([]float64, n)
+			for i := range v {
+				var ok bool
+				if v[i], ok = p.number(); !ok {
+					return nil, false
+				}
+				if i%2 == 0 {
+					v[i] += dx
+				} else {
+					v[i] += dy
+				}
+			}
+			return v, true
+		}
+		upper := cmd &^ 0x20
+		// Without a reflectable previous curve, S and T controls are the
+		// current point.
+		if !(upper == 'S' && (prev == 'C' || prev == 'S')) && !(upper == 'T' && (prev == 'Q' || prev == 'T')) {
+			ctrlX, ctrlY = x, y
+		}
+		switch upper {
+		case 'Z':
+			path = append(path, pathSeg{'Z', nil})
+			x, y = startX, startY
+		case 'M', 'L':
+			v, ok := nums(2)
+			if !ok {
+				return bad()
+			}
+			path = append(path, pathSeg{upper, v})
+			x, y = v[0], v[1]
+			if upper == 'M' {
+				startX, startY = x, y
+				// Further coordinate pairs are line tos.
+				cmd = cmd - 'M' + 'L'
+			}
+		case 'H', 'V':
+			v, ok := p.number()
+			if !ok {
+				return bad()
+			}
+			if upper == 'H' {
+				x = v + dx
+			} else {
+				y = v + dy
+			}
+			path = append(path, pathSeg{'L', []float64{x, y}})
+		case 'C', 'S':
+			var v []float64
+			var ok bool
+			if upper == 'C' {
+				v, ok = nums(6)
+			} else if v, ok = nums(4); ok {
+				v = append([]float64{2*x - ctrlX, 2*y - ctrlY}, v...)
+			}
+			if !ok {
+				return bad()
+			}
+			path = append(path, pathSeg{'C', v})
+			ctrlX, ctrlY, x, y = v[2], v[3], v[4], v[5]
+		case 'Q', 'T':
+			var v []float64
+			var ok bool
+			if upper == 'Q' {
+				v, ok = nums(4)
+			} else if v, ok = nums(2); ok {
+				v = append([]float64{2*x - ctrlX, 2*y - ctrlY}, v...)
+			}
+			if !ok {
+				return bad()
+			}
+			// Elevate the quadratic to a cubic.
+			qx, qy := v[0], v[1]
+			path = append(path, pathSeg{'C', []float64{
+				x + 2*(qx-x)/3, y + 2*(qy-y)/3,
+				v[2] + 2*(qx-v[2])/3, v[3] + 2*(qy-v[3])/3,
+				v[2], v[3],
+			}})
+			ctrlX, ctrlY, x, y = qx, qy, v[2], v[3]
+		case 'A':
+			rx, ok1 := p.number()
+			ry, ok2 := p.number()
+			rot, ok3 := p.number()
+			large, ok4 := p.flag()
+			sweep, ok5 := p.flag()
+			end, ok6 := nums(2)
+			if !(ok1 && ok2 && ok3 && ok4 && ok5 && ok6) {
+				return bad()
+			}
+			path = append(path, arcSegs(x, y, rx, ry, rot, large, sweep, end[0], end[1])...)
+			x, y = end[0], end[1]
+		}
+		prev = upper
+	}
+}
+
+// arcSegs approximates an SVG elliptical arc from (x1, y1) to (x2, y2) with
+// cubic Béziers, following the endpoint to centre conversion of the SVG
+// specification (appendix F.6).
+func arcSegs(x1, y1, rx, ry, rotation float64, large, sweep bool, x2, y2 float64) []pathSeg {
+	if rx == 0 || ry == 0 || x1 == x2 && y1 == y2 {
+		return []pathSeg{{'L', []float64{x2, y2}}}
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	phi := rotation * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	x1p, y1p := cos*dx+sin*dy, -sin*dx+cos*dy
+	if l := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cxp, cyp := coef*rx*y1p/ry, -coef*ry*x1p/rx
+	cx, cy := cos*cxp-sin*cyp+(x1+x2)/2, sin*cxp+cos*cyp+(y1+y2)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (x1p-cxp)/rx, (y1p-cyp)/ry)
+	delta := angle((x1p-cxp)/rx, (y1p-cyp)/ry, (-x1p-cxp)/rx, (-y1p-cyp)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	t := 4.0 / 3 * math.Tan(step/4)
+	point := func(a float64) (float64, float64) {
+		return cx + rx*math.Cos(a)*cos - ry*math.Sin(a)*sin, cy + rx*math.Cos(a)*sin + ry*math.Sin(a)*cos
+	}
+	deriv := func(a float64) (float64, float64) {
+		return -rx*math.Sin(a)*cos - ry*math.Cos(a)*sin, -rx*math.Sin(a)*sin + ry*math.Cos(a)*cos
+	}
+	segs := make([]pathSeg, 0, n)
+	for i := 0; i < n; i++ {
+		a1, a2 := theta+float64(i)*step, theta+float64(i+1)*step
+		px1, py1 := point(a1)
+		px2, py2 := point(a2)
+		d1x, d1y := deriv(a1)
+		d2x, d2y := deriv(a2)
+		segs = append(segs, pathSeg{'C', []float64{px1 + t*d1x, py1 + t*d1y, px2 - t*d2x, py2 - t*d2y, px2, py2}})
+	}
+	return segs
+}
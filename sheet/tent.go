@@ -0,0 +1,51 @@
+package sheet
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// paperHeight returns the height in inches of the paper pages are printed
+// on: HeightInches, or twice it for Tent.
+func (o Options) paperHeight() float64 {
+	if o.Tent {
+		return 2 * o.HeightInches
+	}
+	return o.HeightInches
+}
+
+// PagePixels returns the width and height in pixels of the pages of a
+// sheet, each rendered whole.
+func (o Options) PagePixels() (width, height int) {
+	return int(o.WidthInches * o.DPI), int(o.paperHeight() * o.DPI)
+}
+
+// tent returns face, a page rendered HeightInches high, as a table tent
+// twice as high: face on the bottom half and upside down on the top, with
+// a dashed line to fold along between them. Folded, the card stands with
+// face upright on both sides.
+func tent(opts Options, face image.Image) *image.RGBA {
+	b := face.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), 2*b.Dy()))
+	draw.Draw(out, image.Rect(0, b.Dy(), b.Dx(), 2*b.Dy()), face, b.Min, draw.Src)
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			out.Set(b.Dx()-1-x, b.Dy()-1-y, face.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	dc := gg.NewContextForRGBA(out)
+	fold := float64(b.Dy())
+	dc.SetColor(opts.Theme.Border)
+	dc.SetLineWidth(math.Max(1, opts.DPI/300))
+	dc.SetDash(12*opts.textScale(), 8*opts.textScale())
+	dc.DrawLine(0, fold, float64(b.Dx()), fold)
+	dc.Stroke()
+	dc.SetColor(opts.Theme.Text)
+	dc.SetFontFace(opts.Fonts.description(7 * opts.textScale()))
+	drawString(dc, Expand(opts.Strings.Fold, "fold"), opts.Margin/2, fold-4, 0, 0)
+	return out
+}
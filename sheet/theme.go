@@ -0,0 +1,147 @@
+package sheet
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+// Theme is the colour scheme of a sheet.
+type Theme struct {
+	Background  color.Color
+	Text        color.Color
+	Border      color.Color // cell boundaries
+	BorderWidth float64     // in pixels, 0.4 if zero
+
+	// Plate, if set, is drawn behind every barcode with a quiet zone
+	// around it, so that codes stay dark on light on a dark page. Most
+	// scanners can't read light-on-dark codes.
+	Plate color.Color
+	// InvertCodes draws barcodes in the text colour on the background
+	// instead, for phones and scanners that read inverted codes. Plate is
+	// ignored.
+	InvertCodes bool
+}
+
+// Themes are the built-in themes by name.
+var Themes = map[string]Theme{
+	"light": {
+		Background: color.White,
+		Text:       color.Black,
+		Border:     color.RGBA{R: 230, G: 230, B: 230, A: 255},
+	},
+	"high-contrast": {
+		Background:  color.White,
+		Text:        color.Black,
+		Border:      color.Black,
+		BorderWidth: 3,
+	},
+	"dark": {
+		Background: color.RGBA{R: 0x12, G: 0x12, B: 0x12, A: 255},
+		Text:       color.RGBA{R: 0xed, G: 0xed, B: 0xed, A: 255},
+		Border:     color.RGBA{R: 0x3a, G: 0x3a, B: 0x3a, A: 255},
+		Plate:      color.White,
+	},
+}
+
+func (t Theme) borderWidth() float64 {
+	if t.BorderWidth == 0 {
+		return 0.4
+	}
+	return t.BorderWidth
+}
+
+// symbolSize returns the size codes size pixels across on the page are
+// encoded at, leaving room for a plate.
+func (t Theme) symbolSize(size int) int {
+	if t.Plate == nil || t.InvertCodes {
+		return size
+	}
+	// Four QR modules is a tenth of a typical code.
+	return size - 2*(size/10)
+}
+
+// encode is Theme.encode, with QR codes sized to whole pixels a module
+// with IntegerModules.
+func (o Options) encode(cell Cell, size, maxWidth int) (image.Image, error) {
+	if o.IntegerModules && (cell.Symbology == "" || cell.Symbology == QR) {
+		if raw, err := qr.Encode(cell.Payload, qr.M, qr.Auto); err == nil {
+			size = o.Theme.plated(raw.Bounds().Dx() * o.modulePixels(o.Theme.symbolSize(size), raw.Bounds().Dx()))
+		}
+	}
+	return o.Theme.encode(cell, size, maxWidth)
+}
+
+// modulePixels returns how many pixels across each module of a QR code dim
+// modules across is drawn in size pixels: as many as fit, or the nearest
+// whole number with IntegerModules, which may spill half a module into the
+// space around the code. Codes on stickers always fit.
+func (o Options) modulePixels(size, dim int) int {
+	if o.IntegerModules && o.Stock == nil {
+		return max(1, int(math.Round(float64(size)/float64(dim))))
+	}
+	return size / dim
+}
+
+// plated returns the smallest size whose symbolSize is size.
+func (t Theme) plated(size int) int {
+	n := size
+	for t.symbolSize(n) < size {
+		n++
+	}
+	return n
+}
+
+// encode is encode with the barcode coloured for the theme, at the same
+// overall size.
+func (t Theme) encode(cell Cell, size, maxWidth int) (image.Image, error) {
+	if t.InvertCodes {
+		img, err := encode(cell, size, maxWidth)
+		if err != nil {
+			return nil, err
+		}
+		return recolor(img, 0, t.Text, t.Background), nil
+	}
+	if t.Plate == nil {
+		return encode(cell, size, maxWidth)
+	}
+	pad := (size - t.symbolSize(size)) / 2
+	img, err := encode(cell, size-2*pad, maxWidth-2*pad)
+	if err != nil {
+		return nil, err
+	}
+	return recolor(img, pad, color.Black, t.Plate), nil
+}
+
+// recolor redraws a black and white barcode in dark and light, with pad
+// pixels of light around it.
+func recolor(img image.Image, pad int, dark, light color.Color) image.Image {
+	b := img.Bounds()
+	out := image.NewPaletted(image.Rect(0, 0, b.Dx()+2*pad, b.Dy()+2*pad), color.Palette{light, dark})
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < 128 {
+				out.SetColorIndex(x-b.Min.X+pad, y-b.Min.Y+pad, 1)
+			}
+		}
+	}
+	return out
+}
+
+// ParseColor parses a #rgb or #rrggbb colour.
+func ParseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("%q is not a #rrggbb colour", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
@@ -0,0 +1,158 @@
+package sheet
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+)
+
+// minContrast is the lowest difference between the lightest dark module and
+// the darkest light module Verify accepts, as a fraction of black to white.
+// It is the 40% of symbol contrast grade C in ISO/IEC 15415.
+const minContrast = 0.4
+
+// Verify decodes the QR codes on the pages SavePNG wrote to paths and checks
+// that every cell's codes, and the footer, read back as their payloads with
+// at least minContrast and quietZone modules of light space around them.
+// Codes that only read after error correction fail too: the render is
+// exact, so damage means something was drawn over the code. Cells with
+// linear codes aren't checked.
+func Verify(paths []string, opts Options, cells []Cell, quietZone float64) error {
+	var errs []error
+	err := eachCode(paths, opts, cells, func(path string, page *image.Gray, r image.Rectangle, i int, want string) {
+		name := "footer"
+		if i >= 0 {
+			name = fmt.Sprintf("cell %d %q", i+1, cells[i].label())
+		}
+		if err := verifyCode(page, r, want, quietZone); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, name, err))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// eachCode calls fn with the page and region of each QR code on the pages
+// written to paths, the index of its cell (-1 for the footer) and its
+// payload.
+func eachCode(paths []string, opts Options, cells []Cell, fn func(path string, page *image.Gray, r image.Rectangle, i int, payload string)) error {
+	pages := paginate(opts, cells)
+	if len(pages) != len(paths) {
+		return fmt.Errorf("%d pages for %d files", len(pages), len(paths))
+	}
+	// The front of a tent is the bottom half of the paper.
+	front := int((opts.paperHeight() - opts.HeightInches) * opts.DPI)
+	first := 0
+	for i, path := range paths {
+		page, err := readGray(path)
+		if err != nil {
+			return err
+		}
+		slots, rows := place(opts, pages[i])
+		for j, cell := range pages[i] {
+			if cell.Symbology != "" && cell.Symbology != QR {
+				continue
+			}
+			x, y, w, h := cellRect(opts, rows, slots[j])
+			r := image.Rect(int(x), front+int(y), int(x+w), front+int(y+h))
+			payloads := []string{cell.Payload, cell.Extra}
+			if opts.Stock != nil {
+				payloads = payloads[:1] // stickers have no room for extra codes
+			}
+			for _, payload := range payloads {
+				if payload != "" {
+					fn(path, page, r, first+j, payload)
+				}
+			}
+		}
+		var urls []string
+		for _, link := range opts.footerLinks() {
+			urls = append(urls, link.URL)
+		}
+		if opts.DigitalCopy != "" {
+			urls = append(urls, opts.DigitalCopy)
+		}
+		for _, url := range urls {
+			b := page.Bounds()
+			r := image.Rect(0, b.Dy()-int(opts.Margin)-footerSize(opts, b.Dx())-20, b.Dx(), b.Dy())
+			fn(path, page, r, -1, url)
+		}
+		first += len(pages[i])
+	}
+	return nil
+}
+
+// findCode returns the code reading as want in r of page.
+func findCode(page *image.Gray, r image.Rectangle, want string) (decodedQR, error) {
+	var codes []decodedQR
+	for _, invert := range []bool{false, true} {
+		codes = append(codes, bitmap{gray: page, r: r, invert: invert}.decodeAll()...)
+	}
+	for _, code := range codes {
+		if code.text == want {
+			return code, nil
+		}
+	}
+	if len(codes) > 0 {
+		return decodedQR{}, fmt.Errorf("reads as %q", codes[0].text)
+	}
+	return decodedQR{}, errors.New("unreadable")
+}
+
+// verifyCode checks that a code in r of page reads as want.
+func verifyCode(page *image.Gray, r image.Rectangle, want string, quietZone float64) error {
+	code, err := findCode(page, r, want)
+	switch {
+	case err != nil:
+		return err
+	case code.corrected > 0:
+		return fmt.Errorf("%d damaged codewords", code.corrected)
+	case code.contrast < minContrast:
+		return fmt.Errorf("contrast %.0f%%, want at least %.0f%%", code.contrast*100, minContrast*100)
+	}
+	if q := quiet(page, code, quietZone); q < quietZone {
+		return fmt.Errorf("quiet zone %.1f modules, want %g", q, quietZone)
+	}
+	return nil
+}
+
+// quiet returns the width in modules of the light space around a code, up
+// to limit. The edge of the page counts as light.
+func quiet(page *image.Gray, code decodedQR, limit float64) float64 {
+	b := bitmap{gray: page, r: page.Bounds(), invert: code.invert}
+	for d := 1; float64(d-1) < limit*code.module; d++ {
+		ring := code.bounds.Inset(-d)
+		for x := ring.Min.X; x < ring.Max.X; x++ {
+			if b.at(x, ring.Min.Y) || b.at(x, ring.Max.Y-1) {
+				return float64(d-1) / code.module
+			}
+		}
+		for y := ring.Min.Y; y < ring.Max.Y; y++ {
+			if b.at(ring.Min.X, y) || b.at(ring.Max.X-1, y) {
+				return float64(d-1) / code.module
+			}
+		}
+	}
+	return limit
+}
+
+// readGray reads a PNG as greyscale.
+func readGray(path string) (*image.Gray, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray, nil
+}
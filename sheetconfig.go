@@ -0,0 +1,521 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// sheetConfig is the flags describing a sheet, shared by the commands that
+// render one.
+type sheetConfig struct {
+	messagesPath   string
+	roster         string
+	fragments      bool
+	emoji          bool
+	targetName     string
+	payloadName    string
+	payloadOpts    keyValueFlag
+	short          shortener
+	aimSafe        bool
+	fonts          fontFlags
+	themeName      string
+	invertCodes    bool
+	background     string
+	watermark      string
+	logo           string
+	logoAlign      string
+	logoHeight     float64
+	dpi            float64
+	columns        int
+	cellStyle      string
+	descColumns    int
+	descSpacing    float64
+	descAlign      string
+	descLines      int
+	hyphenate      bool
+	integerModules bool
+	pixelSnap      bool
+	tent           bool
+	back           string
+	duplex         string
+	stock          string
+	pageRows       int
+	pageNumbers    bool
+	keepCategories bool
+	scanDistance   string
+	fingerprint    bool
+	largePrint     bool
+	keyboardStrip  int
+	categoryColors bool
+	localePath     string
+	language       string
+	variant        string
+	shuffle        shuffleFlag
+	bingo          int
+	numbers        bool
+	prefix, suffix string
+	footer         string
+	footerLinks    stringsFlag
+	disclaimer     string
+	digitalCopy    string
+	encryptTo      string
+	signSecret     string
+	indirect       bool
+	transforms     stringsFlag
+	normalize      string
+	asciiPunct     bool
+
+	// pack is the -pack bundle, and settings the sheet flags set so far,
+	// in order, to export into one; see chatpack.go.
+	pack     string
+	packed   bool // the message set is the pack's, so icons must be in it
+	settings []packSetting
+
+	// team, if set, picks and fills in the messages of one team of a
+	// -teams file.
+	team *team
+}
+
+func (c *sheetConfig) register(fs *flag.FlagSet) {
+	before := map[string]bool{}
+	fs.VisitAll(func(f *flag.Flag) { before[f.Name] = true })
+	fs.StringVar(&c.messagesPath, "messages", "", "JSON message set to render instead of the built-in messages")
+	fs.StringVar(&c.roster, "roster", "", "team directory CSV (username, name, role, team columns) to render as a sheet of @mentions instead of messages")
+	fs.BoolVar(&c.fragments, "fragments", false, "render the fragment sheet for composing messages instead")
+	fs.BoolVar(&c.emoji, "emoji", false, "render the emoji reaction sheet instead")
+	fs.StringVar(&c.targetName, "target", "", "chat application the codes are for: "+targetNames())
+	fs.StringVar(&c.payloadName, "payload", "text", "what each QR code encodes: "+payloadModeNames())
+	c.payloadOpts = keyValueFlag{}
+	fs.Var(c.payloadOpts, "payload-opt", "payload mode setting as key=value, may be repeated")
+	fs.IntVar(&c.short.Over, "shorten-over", 0, "replace payloads longer than this many bytes with short URLs")
+	fs.StringVar(&c.short.Service, "shortener", "", "external shortener URL template returning the short URL, with {url} for the payload")
+	fs.StringVar(&c.short.Base, "shorten-base", "", "base URL of a bridge's /s/ links, e.g. https://bridge.example/s/")
+	fs.Var(&c.transforms, "transform", "step every message's text goes through before encoding, in order, as name or name=arg: "+textTransformNames()+"; may be repeated")
+	fs.StringVar(&c.normalize, "normalize", "", "Unicode normalization form to put every message in before encoding, reporting those it changes: "+normalFormNames())
+	fs.BoolVar(&c.asciiPunct, "ascii-punctuation", false, "encode smart quotes, dashes and ellipses as plain ASCII, which scanners type right on any keyboard layout; labels keep them")
+	fs.BoolVar(&c.aimSafe, "aim-safe", false, "refuse payloads that would be mangled by stripping AIM symbology identifiers")
+	fs.BoolVar(&c.indirect, "indirect", false, "encode only each message's ID, for the expand command (or a typer or bridge) to send its message from the -manifest, so wording can change after printing")
+	fs.StringVar(&c.signSecret, "sign-secret", "", "secret to sign every payload with, for a typer or bridge with the same -sign-secret to refuse codes from other sheets")
+	fs.StringVar(&c.encryptTo, "encrypt-to", "", "public key from keygen to seal every payload to, for a typer or bridge with its -decrypt-key to open")
+	c.fonts.register(fs)
+	fs.StringVar(&c.themeName, "theme", "light", "sheet colours: "+themeNames())
+	fs.BoolVar(&c.invertCodes, "invert-codes", false, "draw codes light on dark with the dark theme, only for scanners that read inverted codes")
+	fs.StringVar(&c.background, "background", "", "PNG/JPEG image drawn faded behind the grid")
+	fs.StringVar(&c.watermark, "watermark", "", "text drawn faded diagonally across the page, e.g. INTERNAL")
+	fs.StringVar(&c.logo, "logo", "", "PNG/JPEG/SVG logo drawn on the title line")
+	fs.StringVar(&c.logoAlign, "logo-align", sheet.LogoLeft, "logo position: left|right|title (beside the title)")
+	fs.Float64Var(&c.logoHeight, "logo-height", 0, "logo height in pixels, at most the margin; 60% of the margin if 0")
+	fs.Float64Var(&c.dpi, "dpi", 300, "printer resolution the sheet is rendered at")
+	fs.IntVar(&c.columns, "columns", 0, "codes per row, 4 (2 with -large-print) if 0")
+	fs.StringVar(&c.stock, "stock", "", "print on round sticker stock: "+stockNames()+", or a JSON file describing one")
+	fs.StringVar(&c.cellStyle, "cell-style", sheet.CellStacked, "cell layout: stacked (text under the code), horizontal (text beside it, for wide cells) or compact (the biggest code over its label)")
+	fs.IntVar(&c.descColumns, "description-columns", 1, "set descriptions in this many columns, for long explanations in wide cells")
+	fs.Float64Var(&c.descSpacing, "description-spacing", 1.3, "distance between the lines of descriptions, in lines")
+	fs.StringVar(&c.descAlign, "description-align", "", "description alignment: left|center|right|justify; centred under the code, or left beside it, if empty")
+	fs.IntVar(&c.descLines, "description-lines", 0, "cut descriptions short with an ellipsis past this many lines a column, so they can't overflow their cells; 0 for no limit")
+	fs.BoolVar(&c.hyphenate, "hyphenate", false, "break and hyphenate words too long for their line in descriptions, as suits the message set's language")
+	fs.BoolVar(&c.integerModules, "integer-modules", false, "draw QR codes a whole number of pixels a module, the nearest to their room in the cell, rather than the most that fit with padding left over")
+	fs.BoolVar(&c.tent, "tent", false, "print table tents: each page folds in half to stand on a desk, the codes on both sides; best with a handful of messages")
+	fs.StringVar(&c.back, "back", "", "comma separated categories to print on the back of the paper, behind the others, for two-sided cards")
+	fs.StringVar(&c.duplex, "duplex", sheet.DuplexLong, "with -back, the edge the printer flips the paper over: long|short")
+	fs.BoolVar(&c.pixelSnap, "pixel-snap", false, "put cells and their borders on whole pixels with lines at least a pixel wide, for crisp thermal and low resolution prints")
+	fs.IntVar(&c.pageRows, "page-rows", 0, "rows per page, continuing on more pages; 0 for one page (3 with -large-print)")
+	fs.BoolVar(&c.pageNumbers, "page-numbers", false, "number the pages of multi-page sheets with their categories and mark continued categories")
+	fs.BoolVar(&c.keepCategories, "keep-categories", false, "start a new page rather than break a category across pages")
+	fs.StringVar(&c.scanDistance, "scan-distance", "", "distance codes must scan from, e.g. 50cm or 2ft, to warn when they print too small")
+	fs.BoolVar(&c.fingerprint, "fingerprint", false, "print the sheet's fingerprint (see -manifest) in the bottom corner")
+	fs.IntVar(&c.keyboardStrip, "keyboard-strip", 0, "print strips of this many codes (8 to 12 suit most keyboards) to sit above the function row, with labels only")
+	fs.BoolVar(&c.largePrint, "large-print", false, "accessibility preset: big codes, 14pt labels, high contrast, six cells per page")
+	fs.BoolVar(&c.categoryColors, "category-colors", false, "accent cells by category, with palette colours for categories the message file doesn't colour")
+	fs.StringVar(&c.localePath, "locale", "", "JSON file translating the title, subtitle, footer and page words printed around the messages, {lang} for the -lang")
+	fs.StringVar(&c.language, "lang", "", "print the message set's translations into this language, e.g. es")
+	fs.StringVar(&c.variant, "variant", "", "print each message's short or long version where it has one: short|long")
+	fs.Var(&c.shuffle, "shuffle", "place the messages in a random order, the same each time for -shuffle=seed, to print sheets that differ")
+	fs.IntVar(&c.bingo, "bingo", 0, "deal the messages into this many 5×5 bingo cards, one a page, each different with a free middle cell; -shuffle=seed deals the same ones again")
+	fs.BoolVar(&c.numbers, "numbers", false, "print a number such as 042 in each cell, for asking someone with a scanner to send that message")
+	fs.StringVar(&c.prefix, "prefix", "", "text added before every message, e.g. a greeting; may use {label} and the other template fields")
+	fs.StringVar(&c.suffix, "suffix", "", "text added after every message, e.g. \" – the support team\" or a ticket footer; may use template fields too")
+	fs.StringVar(&c.footer, "footer", sheet.DefaultOptions().Footer, "URL encoded and printed at the bottom of the page, '' for none")
+	fs.Var(&c.footerLinks, "footer-link", "another code beside the footer's as caption=URL, e.g. \"Feedback=https://forms.example/x\", may be repeated")
+	fs.StringVar(&c.disclaimer, "disclaimer", "", "line of small print at the bottom of the page")
+	fs.StringVar(&c.digitalCopy, "digital-copy", "", "URL of the sheet's hosted copy, encoded small in the bottom corner so printouts lead back to the latest; {lang} for the language")
+	fs.StringVar(&c.pack, "pack", "", ".chatpack bundle (or imported directory) to render, flags override its settings")
+	c.recordSettings(fs, before)
+}
+
+// layout is a sheet ready to render.
+type layout struct {
+	set      *messageSet // as loaded; msgs may be the fragment or emoji sheet instead
+	locale   *locale
+	msgs     []ChatMsg
+	opts     sheet.Options
+	cells    []sheet.Cell
+	manifest manifest
+	// texts are the cells' payloads before -indirect, signing, sealing and
+	// shortening, what a scan of each ends up sending.
+	texts []string
+	// warning is about codes too small for -scan-distance, see
+	// sheet.SizeWarning.
+	warning string
+	// untranslated are the keys of messages without a translation into
+	// -lang, printed in their own language.
+	untranslated []string
+	// normalized describes the messages -normalize changed.
+	normalized []string
+}
+
+// build loads the message set and lays out its sheet.
+func (c *sheetConfig) build() (*layout, error) {
+	set, err := c.messageSet()
+	if err != nil {
+		return nil, err
+	}
+	if c.team != nil {
+		if set, err = c.team.apply(set); err != nil {
+			return nil, err
+		}
+	}
+	return c.layout(set)
+}
+
+// messageSet loads the -messages set, or the people of the -roster.
+func (c *sheetConfig) messageSet() (*messageSet, error) {
+	if c.roster == "" {
+		return loadMessages(c.messagesPath)
+	}
+	if c.messagesPath != "" {
+		return nil, fmt.Errorf("-roster can't be used with -messages")
+	}
+	return loadRoster(c.roster)
+}
+
+// layout lays out the sheet of set.
+func (c *sheetConfig) layout(set *messageSet) (*layout, error) {
+	target, err := lookupTarget(c.targetName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := lookupPayloadMode(c.payloadName); err != nil {
+		return nil, err
+	}
+	if c.indirect && (c.signSecret != "" || c.encryptTo != "") {
+		return nil, fmt.Errorf("-indirect codes only send messages from the manifest, so aren't signed or sealed")
+	}
+	if c.indirect && c.fragments {
+		return nil, fmt.Errorf("-indirect can't be used with -fragments, which the typer composes from their text")
+	}
+	var recipient *ecdh.PublicKey
+	if c.encryptTo != "" {
+		if recipient, err = parsePublicKey(c.encryptTo); err != nil {
+			return nil, fmt.Errorf("-encrypt-to: %w", err)
+		}
+	}
+
+	set, untranslated := set.translate(c.language)
+	set, err = set.variant(c.variant)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := loadLocale(strings.ReplaceAll(c.localePath, "{lang}", set.Language))
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, opts := set.Messages, sheet.DefaultOptions()
+	opts.Strings = loc.Strings
+	if loc.Title != "" {
+		opts.Title = loc.Title
+	}
+	if loc.Footer != "" {
+		opts.Footer = loc.Footer
+	}
+	if c.footer != sheet.DefaultOptions().Footer {
+		opts.Footer = c.footer
+	}
+	for _, link := range c.footerLinks {
+		caption, url, ok := strings.Cut(link, "=")
+		if !ok || strings.Contains(caption, ":") {
+			// A bare URL, which may have = in its query.
+			caption, url = "", link
+		}
+		opts.FooterLinks = append(opts.FooterLinks, sheet.FooterLink{Caption: caption, URL: url})
+	}
+	opts.Disclaimer = c.disclaimer
+	opts.DigitalCopy = strings.ReplaceAll(c.digitalCopy, "{lang}", set.Language)
+	opts.Fonts = c.fonts.Fonts()
+	theme, ok := sheet.Themes[c.themeName]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q", c.themeName)
+	}
+	theme.InvertCodes = c.invertCodes
+	opts.Theme = theme
+	if c.dpi != opts.DPI {
+		opts = sheet.AtDPI(opts, c.dpi)
+	}
+	if c.largePrint {
+		opts = sheet.LargePrint(opts)
+	}
+	if c.columns > 0 {
+		opts.Columns = c.columns
+	}
+	switch c.cellStyle {
+	case sheet.CellStacked, "":
+	case sheet.CellHorizontal, sheet.CellCompact:
+		opts.CellStyle = c.cellStyle
+	default:
+		return nil, fmt.Errorf("unknown -cell-style %q", c.cellStyle)
+	}
+	switch c.descAlign {
+	case "", sheet.DescriptionLeft, sheet.DescriptionCenter, sheet.DescriptionRight, sheet.DescriptionJustify:
+		opts.DescriptionAlign = c.descAlign
+	default:
+		return nil, fmt.Errorf("unknown -description-align %q", c.descAlign)
+	}
+	if c.descColumns < 1 || c.descSpacing <= 0 {
+		return nil, fmt.Errorf("-description-columns must be at least 1 and -description-spacing more than 0")
+	}
+	opts.DescriptionColumns, opts.DescriptionLineSpacing = c.descColumns, c.descSpacing
+	if c.descLines < 0 {
+		return nil, fmt.Errorf("-description-lines can't be negative")
+	}
+	opts.DescriptionMaxLines, opts.Hyphenate, opts.Language = c.descLines, c.hyphenate, set.Language
+	opts.IntegerModules, opts.PixelSnap = c.integerModules, c.pixelSnap
+	if c.pageRows > 0 {
+		opts.Rows, opts.PageRows = c.pageRows, c.pageRows
+	}
+	if c.keyboardStrip > 0 {
+		opts = sheet.KeyboardStrip(opts, c.keyboardStrip)
+	}
+	if c.stock != "" {
+		stock, err := loadStock(c.stock)
+		if err != nil {
+			return nil, err
+		}
+		opts = sheet.WithStock(opts, stock)
+	}
+	if c.tent {
+		if c.stock != "" {
+			return nil, fmt.Errorf("-tent can't be printed on -stock")
+		}
+		// Each side is half the paper.
+		opts.HeightInches /= 2
+		opts.Tent = true
+	}
+	back := map[string]bool{}
+	if c.back != "" {
+		if c.duplex != sheet.DuplexLong && c.duplex != sheet.DuplexShort {
+			return nil, fmt.Errorf("unknown -duplex %q, want long or short", c.duplex)
+		}
+		if c.tent || c.stock != "" {
+			return nil, fmt.Errorf("-back can't be used with -tent or -stock")
+		}
+		for category := range strings.SplitSeq(c.back, ",") {
+			back[strings.TrimSpace(category)] = true
+		}
+		opts.Duplex = c.duplex
+	}
+	opts.PageNumbers, opts.KeepCategories = c.pageNumbers, c.keepCategories
+	distance, err := parseDistance(c.scanDistance)
+	if err != nil {
+		return nil, err
+	}
+	opts.Watermark = c.watermark
+	if c.background != "" {
+		if opts.Background, err = loadImage(c.background); err != nil {
+			return nil, err
+		}
+	}
+	switch c.logoAlign {
+	case sheet.LogoLeft, sheet.LogoRight, sheet.LogoTitle:
+		opts.LogoAlign = c.logoAlign
+	default:
+		return nil, fmt.Errorf("unknown -logo-align %q", c.logoAlign)
+	}
+	opts.LogoHeight = c.logoHeight
+	if c.logo != "" {
+		if opts.Logo, err = loadGraphic(c.logo, opts.LogoSize()); err != nil {
+			return nil, err
+		}
+	}
+	if set.Title != "" {
+		opts.Title = set.Title
+	}
+	if c.fragments {
+		msgs = Fragments
+		opts.Title = sheet.Expand(loc.FragmentsTitle, "Chat QR Fragments – Scan Pieces, Then SEND")
+	}
+	if c.emoji {
+		msgs = EmojiReactions
+		opts.Title = sheet.Expand(loc.EmojiTitle, "Emoji Reactions – Scan to React to the Last Message")
+	}
+	if !c.fragments && !c.emoji {
+		msgs = wrapMessages(msgs, c.prefix, c.suffix)
+	}
+	if msgs, err = transformMessages(msgs, set.Transforms, c.transforms); err != nil {
+		return nil, err
+	}
+	var normalized []string
+	if msgs, normalized, err = normalizeMessages(msgs, c.normalize); err != nil {
+		return nil, err
+	}
+	if c.asciiPunct {
+		msgs = foldPunctuation(msgs)
+	}
+	if c.aimSafe {
+		if err := checkAIMSafe(msgs); err != nil {
+			return nil, err
+		}
+	}
+
+	accents, err := categoryAccents(&messageSet{Messages: msgs, Categories: set.Categories}, c.categoryColors)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.targetName != "" {
+		opts.Subtitle = sheet.Expand(loc.Subtitle, "For {target} – program the scanner suffix as {send}", "target", c.targetName, "send", target.SendLabel)
+	}
+
+	icons := iconLoader{dir: set.dir, height: opts.IconSize(), bundledOnly: set.posted, localOnly: c.packed}
+	m := manifest{Title: opts.Title, Indirect: c.indirect}
+	if !c.fragments && !c.emoji {
+		m.Pack, m.Version = set.Name, set.Version
+		opts.Revision = set.revision()
+	}
+	if m.MessagesSHA256, err = messagesSHA256(msgs); err != nil {
+		return nil, err
+	}
+	var numbers map[string]string
+	if c.numbers {
+		numbers = messageNumbers(msgs)
+	}
+	switch {
+	case c.bingo > 0:
+		seed := c.shuffle.seed
+		if !c.shuffle.on {
+			seed = rand.Uint64()
+		}
+		if msgs, err = dealBingo(msgs, c.bingo, seed); err != nil {
+			return nil, err
+		}
+		// The footer's codes would sit over the card's bottom row.
+		opts.Columns, opts.Rows, opts.PageRows = bingoSize, bingoSize, bingoSize
+		opts.Footer, opts.FooterLinks, opts.Disclaimer, opts.DigitalCopy = "", nil, "", ""
+		if c.shuffle.on {
+			m.Shuffle = &seed
+		}
+	case c.shuffle.on:
+		seed := c.shuffle.seed
+		msgs = shuffled(msgs, seed)
+		m.Shuffle = &seed
+	}
+	cells := make([]sheet.Cell, len(msgs))
+	texts := make([]string, len(msgs))
+	for i, msg := range msgs {
+		payload, err := msg.payload(c.payloadName, c.payloadOpts, target)
+		if err != nil {
+			return nil, err
+		}
+		texts[i] = payload
+		var original string
+		if c.indirect {
+			original, payload = payload, msg.Key()
+		}
+		if c.signSecret != "" {
+			payload = signPayload(c.signSecret, payload)
+		}
+		if recipient != nil {
+			if payload, err = sealText(recipient, payload); err != nil {
+				return nil, err
+			}
+		}
+		entry := manifestCell{ID: msg.Key(), Label: msg.Label, Payload: payload, Number: numbers[msg.Key()], Original: original}
+		if shortened, key, err := c.short.shorten(payload); err != nil {
+			return nil, err
+		} else if shortened != payload && !c.indirect {
+			entry.Payload, entry.Short, entry.Original = shortened, key, payload
+		}
+		entry.SHA256 = sha256Hex([]byte(entry.Payload))
+		m.Cells = append(m.Cells, entry)
+		cells[i] = sheet.Cell{Payload: entry.Payload, Label: msg.Label, Description: msg.Description, Category: msg.Category, Accent: accents[msg.Category], Span: msg.Size, Number: entry.Number, Back: back[msg.Category]}
+		if cells[i].Extra, cells[i].ExtraCaption, err = msg.extraPayload(c.payloadName, c.payloadOpts); err != nil {
+			return nil, err
+		}
+		if msg.Icon != "" {
+			if cells[i].Icon, err = icons.load(msg.Icon); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.Duplex != "" {
+		order, rows, err := sheet.DuplexOrder(opts, cells)
+		if err != nil {
+			return nil, fmt.Errorf("-back: %w", err)
+		}
+		opts.Rows = rows
+		msgs, cells, m.Cells = reordered(msgs, order), reordered(cells, order), reordered(m.Cells, order)
+		texts = reordered(texts, order)
+	}
+	for i, pos := range sheet.Positions(opts, cells) {
+		m.Cells[i].Page, m.Cells[i].Cell = cellPosition(pos, opts)
+	}
+	m.Fingerprint = m.fingerprint()
+	if c.fingerprint {
+		opts.Fingerprint = m.Fingerprint
+	}
+	return &layout{
+		set:          set,
+		locale:       loc,
+		msgs:         msgs,
+		opts:         opts,
+		cells:        cells,
+		manifest:     m,
+		texts:        texts,
+		warning:      sheet.SizeWarning(opts, cells, distance),
+		untranslated: untranslated,
+		normalized:   normalized,
+	}, nil
+}
+
+// loadStock returns the named sticker stock, or reads one from a JSON file
+// of sheet.Stock's fields in millimetres.
+func loadStock(name string) (sheet.Stock, error) {
+	if stock, ok := sheet.Stocks[name]; ok {
+		return stock, nil
+	}
+	if filepath.Ext(name) != ".json" {
+		return sheet.Stock{}, fmt.Errorf("unknown -stock %q, want %s or a .json file", name, stockNames())
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return sheet.Stock{}, err
+	}
+	var stock sheet.Stock
+	if err := json.Unmarshal(b, &stock); err != nil {
+		return sheet.Stock{}, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	if stock.Diameter <= 0 || stock.Columns <= 0 || stock.Rows <= 0 || stock.PageWidth <= 0 || stock.PageHeight <= 0 {
+		return sheet.Stock{}, fmt.Errorf("%s: page size, diameter, columns and rows are required", name)
+	}
+	return stock, nil
+}
+
+func stockNames() string {
+	names := make([]string, 0, len(sheet.Stocks))
+	for name := range sheet.Stocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// shortener replaces long payloads with short URLs so they don't end up as
+// dense, hard to scan QR codes. It is meant for codes scanned with phones:
+// a scanner would type the short URL rather than the message.
+type shortener struct {
+	// Over is the payload length in bytes above which payloads are
+	// shortened; 0 disables shortening.
+	Over int
+	// Service is an URL template for an external shortener returning the
+	// short URL as plain text, with {url} replaced by the escaped payload,
+	// e.g. https://is.gd/create.php?format=simple&url={url}. Only URL
+	// payloads can go through it.
+	Service string
+	// Base is the /s/ URL of a bridge started with -manifest, e.g.
+	// https://bridge.example/s/. It serves any payload: URLs redirect, text
+	// is shown as a page.
+	Base string
+}
+
+// shorten returns the short payload for p and the key it is stored under
+// on the bridge (empty for external shorteners). Payloads at or under Over
+// are returned unchanged.
+func (s shortener) shorten(p string) (short, key string, err error) {
+	if s.Over == 0 || len(p) <= s.Over {
+		return p, "", nil
+	}
+	isURL := strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+	switch {
+	case s.Service != "" && isURL:
+		short, err := s.external(p)
+		return short, "", err
+	case s.Base != "":
+		sum := sha256.Sum256([]byte(p))
+		key := strings.ToLower(tokenEncoding.EncodeToString(sum[:])[:tokenLen])
+		return s.Base + key, key, nil
+	case s.Service != "":
+		return "", "", fmt.Errorf("%q is not an URL for -shortener; set -shorten-base to host it on the bridge", p)
+	}
+	return "", "", errors.New("-shorten-over needs -shortener or -shorten-base")
+}
+
+func (s shortener) external(p string) (string, error) {
+	endpoint := strings.ReplaceAll(s.Service, "{url}", url.QueryEscape(p))
+	resp, err := httpClient.Get(endpoint)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("shortener: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	short := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(short, "http") {
+		return "", fmt.Errorf("shortener returned %q, not an URL", short)
+	}
+	return short, nil
+}
+
+// shortLinkHandler serves GET /s/{key} from a manifest: URLs are
+// redirected to, anything else is shown as plain text.
+func shortLinkHandler(m *manifest) http.Handler {
+	links := map[string]string{}
+	for _, c := range m.Cells {
+		if c.Short != "" {
+			links[c.Short] = c.Original
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := links[r.PathValue("key")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+			http.Redirect(w, r, p, http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, p)
+	})
+}
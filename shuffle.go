@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math/rand/v2"
+	"strconv"
+)
+
+// shuffleFlag is -shuffle: off by default, a random seed given bare, or
+// the seed given as -shuffle=N.
+type shuffleFlag struct {
+	on     bool
+	seed   uint64
+	picked bool // the seed was picked at random, so is worth printing
+}
+
+func (f *shuffleFlag) String() string {
+	if f == nil || !f.on {
+		return ""
+	}
+	return strconv.FormatUint(f.seed, 10)
+}
+
+func (f *shuffleFlag) Set(s string) error {
+	switch s {
+	case "false":
+		*f = shuffleFlag{}
+		return nil
+	case "true":
+		// Picked once, so every -languages sheet is shuffled the same way.
+		*f = shuffleFlag{on: true, seed: rand.Uint64(), picked: true}
+		return nil
+	}
+	seed, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*f = shuffleFlag{on: true, seed: seed}
+	return nil
+}
+
+func (f *shuffleFlag) IsBoolFlag() bool { return true }
+
+// shuffled returns msgs in an order given by seed, the same for the same
+// seed and messages, so that several sheets of one set can put its
+// messages in different places and each can be printed again.
+func shuffled(msgs []ChatMsg, seed uint64) []ChatMsg {
+	out := append([]ChatMsg(nil), msgs...)
+	r := rand.New(rand.NewPCG(seed, 0))
+	r.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	return out
+}
+
+// reordered returns s in order, the indices of its elements.
+func reordered[T any](s []T, order []int) []T {
+	out := make([]T, len(order))
+	for i, j := range order {
+		out[i] = s[j]
+	}
+	return out
+}
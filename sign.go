@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// signatureLen is the number of hex digits of the HMAC kept in payloads;
+// 64 bits is plenty against guessing while keeping QR codes small.
+const signatureLen = 16
+
+// signText returns the truncated HMAC-SHA256 of text under secret.
+func signText(secret, text string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(text))
+	return hex.EncodeToString(m.Sum(nil))[:signatureLen]
+}
+
+// verifyText reports whether sig is signText(secret, text).
+func verifyText(secret, text, sig string) bool {
+	return hmac.Equal([]byte(sig), []byte(signText(secret, text)))
+}
+
+// signedSeparator comes between a -sign-secret payload and its signature.
+const signedSeparator = "~"
+
+// signPayload returns payload with its signature under secret added, for
+// verifyScan to check.
+func signPayload(secret, payload string) string {
+	return payload + signedSeparator + signText(secret, payload)
+}
+
+// verifyScan returns the message of a scan signed by signPayload under
+// secret, failing for unsigned or wrongly signed scans, so only codes from
+// sheets made with the secret are sent. Scans pass as they are if secret
+// is empty.
+func verifyScan(secret, scan string) (string, error) {
+	if secret == "" {
+		return scan, nil
+	}
+	i := len(scan) - signatureLen - len(signedSeparator)
+	if i < 0 || scan[i:i+len(signedSeparator)] != signedSeparator {
+		return "", errors.New("unsigned code, not from a -sign-secret sheet")
+	}
+	msg, sig := scan[:i], scan[i+len(signedSeparator):]
+	if !verifyText(secret, msg, sig) {
+		return "", errors.New("bad signature, not from a sheet made with this secret")
+	}
+	return msg, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// slashChat is a self-hosted chat whose clients run slash commands typed
+// into the message box.
+type slashChat struct {
+	name string
+	// channel is the prefix of channel names, which /msg can't send to.
+	channel string
+	// escape rewrites message text so it is sent as typed.
+	escape func(string) string
+}
+
+var (
+	mattermost = slashChat{"mattermost", "~", func(s string) string {
+		return escapeLeadingSlash(" /")(mattermostMarkdown.Replace(s))
+	}}
+	// Rocket.Chat's markdown doesn't honour backslash escapes, they would
+	// show, so only a leading slash is escaped.
+	rocketChat = slashChat{"rocketchat", "#", escapeLeadingSlash(" /")}
+)
+
+// payload turns a message into a command typed into the chat's message
+// box: /msg to the user given by the to option, with or without its @, a
+// /me action for me=true, or the slash command named by the command option,
+// e.g. command=remind; the message text as typed otherwise.
+func (c slashChat) payload(msg ChatMsg, opts map[string]string) (string, error) {
+	if strings.ContainsAny(msg.Code, "\r\n") {
+		return "", fmt.Errorf("%q: %s slash commands end at the first line", msg.Code, c.name)
+	}
+	to, me, command := opts["to"], opts["me"] == "true", strings.TrimPrefix(opts["command"], "/")
+	if strings.HasPrefix(to, c.channel) {
+		return "", fmt.Errorf("%s can only /msg users, not channel %s; post to channels with a bridge webhook instead", c.name, to)
+	}
+	if to != "" && !strings.HasPrefix(to, "@") {
+		to = "@" + to
+	}
+	if strings.ContainsAny(to+command, " \t") {
+		return "", fmt.Errorf("%s user %q or command %q has a space in it", c.name, to, command)
+	}
+	text := c.escape(msg.Code)
+	switch {
+	case command != "" && (to != "" || me):
+		return "", fmt.Errorf("%s payloads take command or to and me, not both", c.name)
+	case command != "":
+		return "/" + command + " " + msg.Code, nil
+	case me && to != "":
+		return "", fmt.Errorf("%s can't send a /me action with /msg", c.name)
+	case me:
+		return "/me " + strings.TrimPrefix(text, " "), nil
+	case to != "":
+		return "/msg " + to + " " + strings.TrimPrefix(text, " "), nil
+	}
+	return text, nil
+}
+
+func mattermostPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	return mattermost.payload(msg, opts)
+}
+
+func rocketChatPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	return rocketChat.payload(msg, opts)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"runtime"
+	"strings"
+)
+
+// defaultSpeech returns the command speaking its standard input here:
+// espeak-ng on Linux, say on macOS and System.Speech on Windows.
+func defaultSpeech() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "powershell -NoProfile -Command Add-Type -AssemblyName System.Speech; (New-Object System.Speech.Synthesis.SpeechSynthesizer).Speak([Console]::In.ReadToEnd())"
+	case "darwin":
+		return "say"
+	default:
+		return "espeak-ng"
+	}
+}
+
+// announcer speaks what each scan sends, e.g. "Sending: Deploy OK", for
+// scanning without looking at the chat window. Announcements are spoken in
+// turn while scanning carries on; any queued up behind a slow voice are
+// dropped.
+type announcer struct {
+	command string
+	format  string
+	labels  scanLabels
+	queue   chan string
+}
+
+// speechFlags registers the -speak flags of a command reading scans, and
+// returns a function making its announcer from the sheet's manifest, or
+// nil without -speak.
+func speechFlags(fs *flag.FlagSet) func(m *manifest) *announcer {
+	speak := fs.Bool("speak", false, "say what each scan sends out loud, by its label in the -manifest")
+	command := fs.String("speech", defaultSpeech(), "with -speak, command speaking its input")
+	format := fs.String("announce", "Sending: {label}", "with -speak, what is said for each scan; {label} falls back to the message, {text} is the message")
+	return func(m *manifest) *announcer {
+		if !*speak {
+			return nil
+		}
+		return newAnnouncer(*command, *format, m)
+	}
+}
+
+func newAnnouncer(command, format string, m *manifest) *announcer {
+	a := &announcer{command: command, format: format, queue: make(chan string, 2)}
+	a.relabel(m)
+	go a.run()
+	return a
+}
+
+// relabel looks labels up in m from now on.
+func (a *announcer) relabel(m *manifest) {
+	if a == nil {
+		return
+	}
+	a.labels = newScanLabels(m)
+}
+
+func (a *announcer) run() {
+	for text := range a.queue {
+		if err := pipeTo(a.command, text); err != nil {
+			log.Printf("speak: %v", err)
+		}
+	}
+}
+
+// announce queues the announcement of a scan sending msg. It does nothing
+// on a nil announcer, so commands can call it whether or not -speak is on.
+func (a *announcer) announce(scan, msg string) {
+	if a == nil {
+		return
+	}
+	fields := map[string]string{"label": a.labels.label(scan, msg), "text": msg}
+	select {
+	case a.queue <- expandFields(a.format, fields):
+	default:
+	}
+}
+
+// scanLabels are the cells of a sheet by each one's payload, what it
+// stood for, ID and number, for naming what scans send.
+type scanLabels map[string]manifestCell
+
+func newScanLabels(m *manifest) scanLabels {
+	labels := scanLabels{}
+	if m == nil {
+		return labels
+	}
+	for _, c := range m.Cells {
+		if c.Label == "" {
+			continue
+		}
+		for _, s := range []string{c.Payload, c.Original, c.ID} {
+			if s != "" {
+				labels[s] = c
+			}
+		}
+		if c.Number != "" {
+			labels[trimNumber(c.Number)] = c
+		}
+	}
+	return labels
+}
+
+// cell returns the cell scan or msg came from.
+func (l scanLabels) cell(scan, msg string) (manifestCell, bool) {
+	for _, s := range []string{strings.TrimSpace(scan), trimNumber(strings.TrimSpace(scan)), msg} {
+		if c, ok := l[s]; ok {
+			return c, true
+		}
+	}
+	return manifestCell{}, false
+}
+
+// label returns the label of the cell scan or msg came from, or msg.
+func (l scanLabels) label(scan, msg string) string {
+	if c, ok := l.cell(scan, msg); ok {
+		return c.Label
+	}
+	return msg
+}
+
+// runAnnounce implements `chat-barcodes announce`, which speaks what each
+// scan sends while something else sends it:
+//
+//	chat-barcodes announce -device /dev/input/by-id/usb-Scanner-event-kbd -manifest sheet.json
+//
+// Keyboard wedge scanners are read without being grabbed, so their scans
+// still type into the chat window as ever. Scanners read by the typer,
+// bridge or expander are announced with their -speak flag instead.
+func runAnnounce(args []string) error {
+	fs := flag.NewFlagSet("announce", flag.ExitOnError)
+	device := fs.String("device", "", "/dev/input/event* device of the keyboard wedge scanner")
+	baud := fs.Int("baud", 9600, "serial baud rate, for serial scanners nothing else reads")
+	manifestPath := fs.String("manifest", "", "manifest of the sheet, whose labels are announced")
+	command := fs.String("speech", defaultSpeech(), "command speaking its input")
+	format := fs.String("announce", "Sending: {label}", "what is said for each scan; {label} falls back to the message, {text} is the message")
+	stripAIMIDs := fs.Bool("strip-aim", true, "remove AIM symbology identifiers (]Q1 etc.) the scanner prepends")
+	mqtt := mqttFlags(fs)
+	scanLog := scanLogFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *device == "" {
+		return errors.New("usage: announce -device /dev/input/by-id/usb-Scanner-event-kbd [-manifest sheet.json]")
+	}
+	var m *manifest
+	var numbers map[string]string
+	if *manifestPath != "" {
+		var err error
+		if m, err = loadManifest(*manifestPath); err != nil {
+			return err
+		}
+		numbers = m.scanPayloads()
+	}
+	port, err := watchScanner(*device, *baud)
+	if err != nil {
+		return err
+	}
+	defer port.Close()
+
+	a := newAnnouncer(*command, *format, m)
+	publisher, err := mqtt(m)
+	if err != nil {
+		return err
+	}
+	scans, err := scanLog(m)
+	if err != nil {
+		return err
+	}
+	log.Printf("announce: speaking scans from %s", *device)
+	return readMessages(port, *stripAIMIDs, nil, func(scan, msg string) error {
+		msg = expandNumber(numbers, msg)
+		a.announce(scan, msg)
+		publisher.publish(scan, msg)
+		scans.record(scan, msg)
+		return nil
+	})
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// A writer of small SQLite databases, for Anki decks, see
+// https://www.sqlite.org/fileformat.html. Each table is written once, in
+// full, as a b-tree at most two levels deep.
+
+const sqlitePageSize = 4096
+
+// sqliteTable is a table written by writeSQLite. Its rows hold int64,
+// string or nil values; if key is set, the first column is an INTEGER
+// PRIMARY KEY, the row ID, otherwise rows are numbered from 1.
+type sqliteTable struct {
+	name    string
+	sql     string // CREATE TABLE statement
+	key     bool
+	rows    [][]any
+	indexes []sqliteIndex
+}
+
+// sqliteIndex is an index of integer columns of its table.
+type sqliteIndex struct {
+	name    string
+	sql     string // CREATE INDEX statement
+	columns []int
+}
+
+type sqliteWriter struct {
+	pages [][]byte // from page 1
+}
+
+// writeSQLite writes a database of tables to out.
+func writeSQLite(out io.Writer, tables []sqliteTable) error {
+	w := &sqliteWriter{}
+	w.page() // the schema, written last
+	var schema [][]byte
+	addSchema := func(typ, name, table string, root uint32, sql string) {
+		rec := sqliteRecord([]any{typ, name, table, int64(root), sql})
+		schema = append(schema, w.tableCell(int64(len(schema)+1), rec))
+	}
+	for _, t := range tables {
+		rows := slices.Clone(t.rows)
+		rowid := func(i int) int64 {
+			if t.key {
+				return rows[i][0].(int64)
+			}
+			return int64(i + 1)
+		}
+		if t.key {
+			slices.SortFunc(rows, func(a, b []any) int { return cmp.Compare(a[0].(int64), b[0].(int64)) })
+		}
+		cells := make([][]byte, len(rows))
+		keys := make([]int64, len(rows))
+		for i, row := range rows {
+			keys[i] = rowid(i)
+			if t.key {
+				row = append([]any{nil}, row[1:]...) // stored as the row ID
+			}
+			cells[i] = w.tableCell(keys[i], sqliteRecord(row))
+		}
+		root, err := w.tableTree(cells, keys)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", t.name, err)
+		}
+		addSchema("table", t.name, t.name, root, t.sql)
+
+		for _, index := range t.indexes {
+			entries := make([][]int64, len(rows))
+			for i, row := range rows {
+				for _, c := range index.columns {
+					entries[i] = append(entries[i], row[c].(int64))
+				}
+				entries[i] = append(entries[i], rowid(i))
+			}
+			slices.SortFunc(entries, slices.Compare[[]int64])
+			payloads := make([][]byte, len(entries))
+			for i, e := range entries {
+				values := make([]any, len(e))
+				for j, v := range e {
+					values[j] = v
+				}
+				payloads[i] = sqliteRecord(values)
+			}
+			root, err := w.indexTree(payloads)
+			if err != nil {
+				return fmt.Errorf("index %s: %w", index.name, err)
+			}
+			addSchema("index", index.name, t.name, root, index.sql)
+		}
+	}
+	if !fits(100, 8, schema) {
+		return errors.New("schema too large for the first page")
+	}
+	putPage(w.pages[0], 100, 0x0d, schema, 0)
+
+	h := w.pages[0]
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], sqlitePageSize)
+	h[18], h[19] = 1, 1                   // rollback journal
+	h[21], h[22], h[23] = 64, 32, 32      // payload fractions, fixed
+	binary.BigEndian.PutUint32(h[24:], 1) // change counter
+	binary.BigEndian.PutUint32(h[28:], uint32(len(w.pages)))
+	binary.BigEndian.PutUint32(h[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(h[44:], 4) // schema format
+	binary.BigEndian.PutUint32(h[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(h[92:], 1) // valid for change 1
+	binary.BigEndian.PutUint32(h[96:], 3045000)
+	for _, p := range w.pages {
+		if _, err := out.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// page adds an empty page and returns its number.
+func (w *sqliteWriter) page() uint32 {
+	w.pages = append(w.pages, make([]byte, sqlitePageSize))
+	return uint32(len(w.pages))
+}
+
+// tableCell returns the cell of a row of a table leaf page, spilling
+// payloads too long for a page onto overflow pages.
+func (w *sqliteWriter) tableCell(rowid int64, payload []byte) []byte {
+	const usable = sqlitePageSize
+	maxLocal := usable - 35
+	minLocal := (usable-12)*32/255 - 23
+	local := len(payload)
+	if local > maxLocal {
+		local = minLocal + (len(payload)-minLocal)%(usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	c := sqliteVarint(nil, uint64(len(payload)))
+	c = sqliteVarint(c, uint64(rowid))
+	c = append(c, payload[:local]...)
+	if rest := payload[local:]; len(rest) > 0 {
+		first := w.page()
+		for p := first; ; {
+			n := copy(w.pages[p-1][4:], rest)
+			if rest = rest[n:]; len(rest) == 0 {
+				break
+			}
+			next := w.page()
+			binary.BigEndian.PutUint32(w.pages[p-1], next)
+			p = next
+		}
+		c = binary.BigEndian.AppendUint32(c, first)
+	}
+	return c
+}
+
+// tableTree writes the cells of a table with row IDs keys as leaf pages,
+// under an interior page if they don't fit one, and returns the root page.
+func (w *sqliteWriter) tableTree(cells [][]byte, keys []int64) (uint32, error) {
+	var interior [][]byte
+	var last uint32
+	for start := 0; ; {
+		end := start
+		for end < len(cells) && fits(0, 8, cells[start:end+1]) {
+			end++
+		}
+		last = w.page()
+		putPage(w.pages[last-1], 0, 0x0d, cells[start:end], 0)
+		if end == len(cells) {
+			break
+		}
+		interior = append(interior, sqliteVarint(binary.BigEndian.AppendUint32(nil, last), uint64(keys[end-1])))
+		start = end
+	}
+	return w.interior(0x05, interior, last)
+}
+
+// indexTree writes the sorted record payloads of an index as leaf pages,
+// under an interior page if they don't fit one, separated by the entries
+// kept in it, and returns the root page.
+func (w *sqliteWriter) indexTree(payloads [][]byte) (uint32, error) {
+	cells := make([][]byte, len(payloads))
+	for i, p := range payloads {
+		if len(p) > (sqlitePageSize-12)*64/255-23 {
+			return 0, errors.New("entry too large")
+		}
+		cells[i] = append(sqliteVarint(nil, uint64(len(p))), p...)
+	}
+	var interior [][]byte
+	var last uint32
+	for start := 0; ; {
+		end := start
+		for end < len(cells) && fits(0, 8, cells[start:end+1]) {
+			end++
+		}
+		last = w.page()
+		putPage(w.pages[last-1], 0, 0x0a, cells[start:end], 0)
+		if end >= len(cells)-1 {
+			if end == len(cells)-1 {
+				last = w.page()
+				putPage(w.pages[last-1], 0, 0x0a, cells[end:], 0)
+			}
+			break
+		}
+		interior = append(interior, append(binary.BigEndian.AppendUint32(nil, last), cells[end]...))
+		start = end + 1
+	}
+	return w.interior(0x02, interior, last)
+}
+
+// interior writes an interior page of cells and the right-most child
+// last, and returns it, or returns last if there are no other children.
+func (w *sqliteWriter) interior(typ byte, cells [][]byte, last uint32) (uint32, error) {
+	if len(cells) == 0 {
+		return last, nil
+	}
+	if !fits(0, 12, cells) {
+		return 0, errors.New("too many rows")
+	}
+	root := w.page()
+	putPage(w.pages[root-1], 0, typ, cells, last)
+	return root, nil
+}
+
+// fits reports whether cells fit a page with a b-tree header of size
+// header at offset.
+func fits(offset, header int, cells [][]byte) bool {
+	n := offset + header
+	for _, c := range cells {
+		n += 2 + len(c)
+	}
+	return n <= sqlitePageSize
+}
+
+// putPage writes a b-tree page of type typ with its header at offset:
+// cell pointers after the header, and cells packed at the end of the page.
+func putPage(p []byte, offset int, typ byte, cells [][]byte, right uint32) {
+	pointers := offset + 8
+	if typ == 0x02 || typ == 0x05 {
+		binary.BigEndian.PutUint32(p[offset+8:], right)
+		pointers += 4
+	}
+	end := len(p)
+	for i, c := range cells {
+		end -= len(c)
+		copy(p[end:], c)
+		binary.BigEndian.PutUint16(p[pointers+2*i:], uint16(end))
+	}
+	p[offset] = typ
+	binary.BigEndian.PutUint16(p[offset+3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(p[offset+5:], uint16(end)) // 0 for 65536
+}
+
+// sqliteRecord encodes a row of int64, string or nil values.
+func sqliteRecord(values []any) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = append(types, 0)
+		case int64:
+			switch {
+			case v == 0:
+				types = append(types, 8)
+			case v == 1:
+				types = append(types, 9)
+			default:
+				// Serial types 1 to 6 are integers of these sizes.
+				sizes := []int{1, 2, 3, 4, 6, 8}
+				i := 0
+				for i < 5 && (v < -1<<(8*sizes[i]-1) || v >= 1<<(8*sizes[i]-1)) {
+					i++
+				}
+				types = append(types, byte(i+1))
+				for j := sizes[i] - 1; j >= 0; j-- {
+					body = append(body, byte(v>>(8*j)))
+				}
+			}
+		case string:
+			types = sqliteVarint(types, uint64(13+2*len(v)))
+			body = append(body, v...)
+		default:
+			panic(fmt.Sprintf("sqlite: unsupported value %T", v))
+		}
+	}
+	n := len(types) + 1 // the header's length counts itself
+	if n >= 0x80 {
+		n++
+	}
+	rec := sqliteVarint(nil, uint64(n))
+	rec = append(rec, types...)
+	return append(rec, body...)
+}
+
+// sqliteVarint appends v to b as a SQLite varint: big-endian groups of 7
+// bits, or 8 in the ninth byte.
+func sqliteVarint(b []byte, v uint64) []byte {
+	if v >= 1<<56 {
+		for i := 7; i >= 0; i-- {
+			b = append(b, byte(v>>(8+7*i))&0x7f|0x80)
+		}
+		return append(b, byte(v))
+	}
+	var groups []byte
+	for {
+		groups = append(groups, byte(v&0x7f))
+		if v >>= 7; v == 0 {
+			break
+		}
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		if i > 0 {
+			groups[i] |= 0x80
+		}
+		b = append(b, groups[i])
+	}
+	return b
+}
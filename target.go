@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// chatTarget describes the quirks of a chat application that scanned text
+// is typed into.
+type chatTarget struct {
+	// SendKeys are pressed after the text to send the message.
+	SendKeys []key
+	// SendLabel names SendKeys on the printed sheet, it is the suffix the
+	// scanner has to be programmed with.
+	SendLabel string
+	// Escape rewrites a payload so the client sends it as typed.
+	Escape func(string) string
+	// Validate, if set, rejects payloads the client can't send.
+	Validate func(string) error
+}
+
+var targets = map[string]chatTarget{
+	// Slack only treats a message as a slash command when "/" is the first
+	// character.
+	"slack": {
+		SendKeys:  []key{keyEnter},
+		SendLabel: "Enter",
+		Escape:    escapeLeadingSlash(" /"),
+	},
+	// Teams swallows a leading "/" into its command box and sends with
+	// Ctrl+Enter when "Enter starts a new line" is set, which is what most
+	// shared desks use so stray newlines don't send half messages.
+	"teams": {
+		SendKeys:  []key{keyCtrl, keyEnter},
+		SendLabel: "Ctrl+Enter",
+		Escape:    escapeLeadingSlash(" /"),
+	},
+	// Discord renders markdown and runs slash commands; backslash escapes
+	// both.
+	"discord": {
+		SendKeys:  []key{keyEnter},
+		SendLabel: "Enter",
+		Escape: func(s string) string {
+			return escapeLeadingSlash(`\/`)(discordMarkdown.Replace(s))
+		},
+	},
+	// IRC clients run anything starting with "/" as a command; /say sends
+	// the rest verbatim.
+	"irc": {
+		SendKeys:  []key{keyEnter},
+		SendLabel: "Enter",
+		Escape:    escapeLeadingSlash("/say /"),
+		Validate:  validateIRC,
+	},
+	// Mattermost runs slash commands and renders CommonMark, which
+	// backslash escapes.
+	"mattermost": {
+		SendKeys:  []key{keyEnter},
+		SendLabel: "Enter",
+		Escape:    mattermost.escape,
+	},
+	// Rocket.Chat runs slash commands too.
+	"rocketchat": {
+		SendKeys:  []key{keyEnter},
+		SendLabel: "Enter",
+		Escape:    rocketChat.escape,
+	},
+	// Google Chat offers app commands for a leading "/" and has no escape
+	// for its *bold* and _italic_ formatting.
+	"googlechat": {
+		SendKeys:  []key{keyEnter},
+		SendLabel: "Enter",
+		Escape:    escapeLeadingSlash(" /"),
+	},
+	// Zoom's in-meeting chat sends plain text, but Team Chat runs slash
+	// commands for its apps.
+	"zoom": {
+		SendKeys:  []key{keyEnter},
+		SendLabel: "Enter",
+		Escape:    escapeLeadingSlash(" /"),
+	},
+}
+
+var discordMarkdown = strings.NewReplacer(
+	`\`, `\\`, `*`, `\*`, `_`, `\_`, `~`, `\~`, "`", "\\`", `|`, `\|`, `>`, `\>`,
+)
+
+// mattermostMarkdown adds headings and links to discordMarkdown's escapes.
+var mattermostMarkdown = strings.NewReplacer(
+	`\`, `\\`, `*`, `\*`, `_`, `\_`, `~`, `\~`, "`", "\\`", `|`, `\|`, `>`, `\>`, `#`, `\#`, `[`, `\[`,
+)
+
+// defaultTarget is used when no -target is given: payloads are sent as-is
+// and Enter sends them.
+var defaultTarget = chatTarget{
+	SendKeys:  []key{keyEnter},
+	SendLabel: "Enter",
+	Escape:    func(s string) string { return s },
+}
+
+// escapeLeadingSlash returns an Escape func replacing a leading "/" with
+// replacement.
+func escapeLeadingSlash(replacement string) func(string) string {
+	return func(s string) string {
+		if strings.HasPrefix(s, "/") {
+			return replacement + s[1:]
+		}
+		return s
+	}
+}
+
+// lookupTarget returns the named target, or defaultTarget for "".
+func lookupTarget(name string) (chatTarget, error) {
+	if name == "" {
+		return defaultTarget, nil
+	}
+	t, ok := targets[name]
+	if !ok {
+		return chatTarget{}, fmt.Errorf("unknown target %q (want one of %s)", name, targetNames())
+	}
+	return t, nil
+}
+
+func targetNames() string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
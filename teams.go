@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// team is one team of a -teams file: which of the messages it gets and
+// the values of its own placeholders in them, so several teams can share
+// one message set rather than each keeping a copy.
+//
+//	{"teams": [{"name": "support", "categories": ["status", "support"],
+//	            "exclude": ["escalate"], "fields": {"channel": "#support"}}]}
+type team struct {
+	Name  string `json:"name"`            // added to the names of the team's files
+	Title string `json:"title,omitempty"` // of the team's sheet, the set's if empty
+
+	// Categories and Messages pick the team's messages: those in any of
+	// Categories or with an ID in Messages, every message if both are
+	// empty. Exclude leaves messages out again by ID.
+	Categories []string `json:"categories,omitempty"`
+	Messages   []string `json:"messages,omitempty"`
+	Exclude    []string `json:"exclude,omitempty"`
+
+	// Fields are {name} placeholders replaced in the messages' text,
+	// labels, descriptions and payload settings, and in -prefix and
+	// -suffix; {team} is the team's name unless Fields sets it.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// loadTeams reads a -teams file.
+func loadTeams(path string) ([]team, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		Teams []team `json:"teams"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(f.Teams) == 0 {
+		return nil, fmt.Errorf("%s: no teams", path)
+	}
+	seen := map[string]bool{}
+	for _, t := range f.Teams {
+		if t.Name == "" || strings.ContainsAny(t.Name, `/\`) {
+			return nil, fmt.Errorf("%s: team name %q can't name files", path, t.Name)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("%s: team %s is listed twice", path, t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return f.Teams, nil
+}
+
+// fields returns the team's placeholders, with {team} for its name.
+func (t *team) fields() map[string]string {
+	fields := map[string]string{"team": t.Name}
+	for k, v := range t.Fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+// apply returns the team's sheet of set: its messages, with its fields
+// filled in, under its title.
+func (t *team) apply(set *messageSet) (*messageSet, error) {
+	for _, id := range slices.Concat(t.Messages, t.Exclude) {
+		if _, ok := set.find(id); !ok {
+			return nil, fmt.Errorf("team %s: no message %q", t.Name, id)
+		}
+	}
+	fields := t.fields()
+	out := *set
+	out.Messages = nil
+	for _, m := range set.Messages {
+		picked := len(t.Categories) == 0 && len(t.Messages) == 0 ||
+			slices.Contains(t.Categories, m.Category) || slices.Contains(t.Messages, m.Key())
+		if !picked || slices.Contains(t.Exclude, m.Key()) {
+			continue
+		}
+		out.Messages = append(out.Messages, m.fill(fields))
+	}
+	if len(out.Messages) == 0 {
+		return nil, fmt.Errorf("team %s: no messages", t.Name)
+	}
+	if t.Title != "" {
+		out.Title, out.Titles = t.Title, nil
+	}
+	out.Title = expandFields(out.Title, fields)
+	if len(out.Titles) > 0 {
+		out.Titles = fillMap(out.Titles, fields)
+	}
+	return &out, nil
+}
+
+// fill returns m with fields' placeholders replaced in its text, in every
+// language and variant, and in its payload settings.
+func (m ChatMsg) fill(fields map[string]string) ChatMsg {
+	m.ID = m.Key()
+	m.Code = expandFields(m.Code, fields)
+	m.Label = expandFields(m.Label, fields)
+	m.Description = expandFields(m.Description, fields)
+	m.Short = expandFields(m.Short, fields)
+	m.Long = expandFields(m.Long, fields)
+	if len(m.Fields) > 0 {
+		m.Fields = fillMap(m.Fields, fields)
+	}
+	if len(m.Translations) > 0 {
+		translations := make(map[string]Translation, len(m.Translations))
+		for lang, tr := range m.Translations {
+			tr.Code = expandFields(tr.Code, fields)
+			tr.Label = expandFields(tr.Label, fields)
+			tr.Description = expandFields(tr.Description, fields)
+			tr.Short = expandFields(tr.Short, fields)
+			tr.Long = expandFields(tr.Long, fields)
+			translations[lang] = tr
+		}
+		m.Translations = translations
+	}
+	return m
+}
+
+// fillMap returns a copy of values with fields' placeholders replaced.
+func fillMap(values, fields map[string]string) map[string]string {
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		out[k] = expandFields(v, fields)
+	}
+	return out
+}
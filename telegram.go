@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// telegramStartParam is what Telegram accepts as a deep-link start
+// parameter.
+var telegramStartParam = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// telegramPayload encodes a Telegram link. With the bot option it is a
+// t.me/<bot>?start=<param> link that opens the bot and sends it
+// "/start <param>", param defaulting to the message ID so the bot can look
+// up what to do; without it, a tg://msg link sharing the message text.
+func telegramPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	bot := opts["bot"]
+	if bot == "" {
+		return "tg://msg?text=" + uriEscape(msg.Code), nil
+	}
+	param := opts["start"]
+	if param == "" {
+		param = msg.Key()
+	} else {
+		param = expandFields(param, msg.templateFields())
+	}
+	if !telegramStartParam.MatchString(param) {
+		return "", fmt.Errorf("telegram start parameter %q must be 1-64 letters, digits, _ or -", param)
+	}
+	return "https://t.me/" + bot + "?start=" + param, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import "strings"
+
+// templateFields are the {name} placeholders available to payload
+// templates.
+func (m ChatMsg) templateFields() map[string]string {
+	return map[string]string{
+		"text":        m.Code,
+		"label":       m.Label,
+		"description": m.Description,
+		"id":          m.Key(),
+	}
+}
+
+// wrapMessages returns msgs with prefix and suffix, after expanding their
+// template fields, added to each message's code: a sign-off or footer on
+// every message without editing each one.
+func wrapMessages(msgs []ChatMsg, prefix, suffix string) []ChatMsg {
+	if prefix == "" && suffix == "" {
+		return msgs
+	}
+	wrapped := make([]ChatMsg, len(msgs))
+	for i, m := range msgs {
+		fields := m.templateFields()
+		m.Code = expandFields(prefix, fields) + m.Code + expandFields(suffix, fields)
+		wrapped[i] = m
+	}
+	return wrapped
+}
+
+// expandFields replaces {name} placeholders in s with fields[name].
+// Unknown names are left alone, as is anything in double braces: {{name}}
+// placeholders are resolved by the companion tools at scan time, not when
+// generating.
+func expandFields(s string, fields map[string]string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '{')
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		if strings.HasPrefix(s, "{{") {
+			end := strings.Index(s, "}}")
+			if end < 0 {
+				b.WriteString(s)
+				return b.String()
+			}
+			b.WriteString(s[:end+2])
+			s = s[end+2:]
+			continue
+		}
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		if v, ok := fields[s[1:end]]; ok {
+			b.WriteString(v)
+		} else {
+			b.WriteString(s[:end+1])
+		}
+		s = s[end+1:]
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tokenLen is the number of base32 characters in a message token: 40 bits,
+// short enough for a tiny QR code and too many to guess.
+const tokenLen = 8
+
+var tokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// messageToken derives the opaque token for a message ID. Tokens only
+// depend on the ID (and secret), so the bridge can rebuild the mapping
+// from the message set and the text behind a printed code can be edited.
+func messageToken(secret, id string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(id))
+	return strings.ToLower(tokenEncoding.EncodeToString(m.Sum(nil))[:tokenLen])
+}
+
+// tokenPayload encodes only the message's token: as base+token when base is
+// set (an URL on a bridge started with -listen, for phones), otherwise as a
+// cb://t/<token> bridge code for scanners attached to the bridge.
+func tokenPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	if opts["secret"] == "" {
+		return "", errors.New("token payloads need -payload-opt secret=…, the bridge's -token-secret")
+	}
+	token := messageToken(opts["secret"], msg.Key())
+	if base := opts["base"]; base != "" {
+		return base + token, nil
+	}
+	return bridgeCode("t", []string{token}, nil), nil
+}
+
+// tokenIndex maps the tokens of a message set back to message text.
+func tokenIndex(set *messageSet, secret string) map[string]string {
+	idx := make(map[string]string, len(set.Messages))
+	for _, m := range set.Messages {
+		idx[messageToken(secret, m.Key())] = m.Code
+	}
+	return idx
+}
+
+// tokenService posts the message behind cb://t/<token> codes with post.
+func tokenService(idx map[string]string, post func(text string) error) bridgeService {
+	return func(u *url.URL, path []string) error {
+		if len(path) != 1 {
+			return fmt.Errorf("%s: want cb://t/<token>", u)
+		}
+		text, ok := idx[path[0]]
+		if !ok {
+			return fmt.Errorf("%s: unknown token", u)
+		}
+		return post(text)
+	}
+}
+
+// tokenHandler serves GET /t/{token}, posting the message behind token.
+func tokenHandler(idx map[string]string, post func(text string) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text, ok := idx[r.PathValue("token")]
+		if !ok {
+			http.Error(w, "unknown token", http.StatusNotFound)
+			return
+		}
+		if err := post(text); err != nil {
+			log.Printf("bridge: posting %q: %v", text, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintf(w, "Sent: %s\n", text)
+	})
+}
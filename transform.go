@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textTransform rewrites a message's text before it is encoded, see
+// -transform. arg is what followed = in the step, "" if nothing did.
+type textTransform func(m ChatMsg, arg string) (string, error)
+
+var textTransforms = map[string]textTransform{
+	"trim": func(m ChatMsg, arg string) (string, error) {
+		return strings.TrimSpace(m.Code), nil
+	},
+	"case": func(m ChatMsg, arg string) (string, error) {
+		switch arg {
+		case "upper":
+			return strings.ToUpper(m.Code), nil
+		case "lower":
+			return strings.ToLower(m.Code), nil
+		case "sentence":
+			i := strings.IndexFunc(m.Code, unicode.IsLetter)
+			if i < 0 {
+				return m.Code, nil
+			}
+			r, n := utf8.DecodeRuneInString(m.Code[i:])
+			return m.Code[:i] + string(unicode.ToUpper(r)) + m.Code[i+n:], nil
+		}
+		return "", fmt.Errorf("case=%s, want case=upper|lower|sentence", arg)
+	},
+	"prefix": func(m ChatMsg, arg string) (string, error) {
+		return expandFields(arg, m.templateFields()) + m.Code, nil
+	},
+	"suffix": func(m ChatMsg, arg string) (string, error) {
+		return m.Code + expandFields(arg, m.templateFields()), nil
+	},
+	"strip-emoji": func(m ChatMsg, arg string) (string, error) {
+		return stripEmoji(m.Code), nil
+	},
+	"urlencode": func(m ChatMsg, arg string) (string, error) {
+		return url.QueryEscape(m.Code), nil
+	},
+	"template": func(m ChatMsg, arg string) (string, error) {
+		if arg == "" {
+			return "", fmt.Errorf("template needs its text, e.g. template=\"{label}: {text}\"")
+		}
+		return expandFields(arg, m.templateFields()), nil
+	},
+}
+
+func textTransformNames() string {
+	names := make([]string, 0, len(textTransforms))
+	for name := range textTransforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+// transformStep is one step of a transform chain, e.g. suffix= (ref {id}).
+type transformStep struct {
+	fn  textTransform
+	arg string
+}
+
+// parseTransforms parses a chain of steps, each a transform name and
+// optionally = and its argument.
+func parseTransforms(steps []string) ([]transformStep, error) {
+	chain := make([]transformStep, 0, len(steps))
+	for _, s := range steps {
+		name, arg, _ := strings.Cut(s, "=")
+		fn, ok := textTransforms[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q, want %s", name, textTransformNames())
+		}
+		chain = append(chain, transformStep{fn, arg})
+	}
+	return chain, nil
+}
+
+// transformMessages runs each message's text through the chain of its
+// category in the set's "transforms", then through the sheet's chain, in
+// order. Each step sees the text the one before it left.
+func transformMessages(msgs []ChatMsg, byCategory map[string][]string, sheetSteps []string) ([]ChatMsg, error) {
+	if len(byCategory) == 0 && len(sheetSteps) == 0 {
+		return msgs, nil
+	}
+	sheetChain, err := parseTransforms(sheetSteps)
+	if err != nil {
+		return nil, fmt.Errorf("-transform: %w", err)
+	}
+	chains := map[string][]transformStep{}
+	for category, steps := range byCategory {
+		if chains[category], err = parseTransforms(steps); err != nil {
+			return nil, fmt.Errorf("transforms of %q: %w", category, err)
+		}
+	}
+	out := make([]ChatMsg, len(msgs))
+	for i, m := range msgs {
+		for _, step := range append(chains[m.Category], sheetChain...) {
+			if m.Code, err = step.fn(m, step.arg); err != nil {
+				return nil, fmt.Errorf("%s: %w", m.Key(), err)
+			}
+		}
+		out[i] = m
+	}
+	return out, nil
+}
+
+// stripEmoji removes emoji, with their modifiers and joiners, from s and
+// the spaces they leave at either end or doubled up.
+func stripEmoji(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if isEmoji(r) {
+			return -1
+		}
+		return r
+	}, s)
+	for strings.Contains(s, "  ") {
+		s = strings.ReplaceAll(s, "  ", " ")
+	}
+	return strings.TrimSpace(s)
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, emoticons, flags and skin tones
+		r >= 0x2600 && r <= 0x27BF,   // miscellaneous symbols and dingbats
+		r >= 0x2B00 && r <= 0x2BFF,   // arrows and stars
+		r >= 0xE0020 && r <= 0xE007F, // tag sequences
+		r == 0x200D, r == 0xFE0F, r == 0x20E3:
+		return true
+	}
+	return false
+}
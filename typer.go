@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// key is a platform independent key identifier understood by keyboard
+// implementations. Printable text goes through keyboard.Type instead.
+type key int
+
+const (
+	keyEnter key = iota
+	keyCtrl
+	keyShift
+	keyV // for pasting with Ctrl+V
+)
+
+// keyboard injects synthetic key presses into the focused window.
+type keyboard interface {
+	// Type enters s as if it had been typed by hand.
+	Type(s string) error
+	// Press holds down keys in order and then releases them in reverse,
+	// so Press(keyCtrl, keyEnter) sends Ctrl+Enter.
+	Press(keys ...key) error
+	Close() error
+}
+
+// runTyper implements `chat-barcodes typer`. It is for scanners that
+// present as a serial port (USB CDC / "COM port emulation") rather than as a
+// keyboard wedge: every newline-terminated scan read from the device is
+// replayed as keyboard input, followed by the target's send keys. Fragment scans are held
+// back and typed together once the message is complete.
+func runTyper(args []string) error {
+	fs := flag.NewFlagSet("typer", flag.ExitOnError)
+	device := fs.String("device", defaultSerialDevice, "serial device the scanner is attached to")
+	baud := fs.Int("baud", 9600, "serial baud rate (ignored where the OS doesn't let us set it)")
+	enter := fs.Bool("enter", true, "send each message after typing it")
+	targetName := fs.String("target", "", "chat application being typed into, picks the send keys: "+targetNames())
+	stripAIMIDs := fs.Bool("strip-aim", true, "remove AIM symbology identifiers (]Q1 etc.) the scanner prepends")
+	manifestPath := fs.String("manifest", "", "manifest of a sheet printed with -numbers or -indirect, whose numbers and IDs are typed as their messages")
+	decryptKey := fs.String("decrypt-key", "", "secret key file from keygen, opening payloads sealed with -encrypt-to")
+	signSecret := fs.String("sign-secret", "", "secret the sheet was signed with; other codes are ignored")
+	speech := speechFlags(fs)
+	mqtt := mqttFlags(fs)
+	scanLog := scanLogFlags(fs)
+	fields := placeholderFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var m *manifest
+	var numbers map[string]string
+	if *manifestPath != "" {
+		var err error
+		if m, err = loadManifest(*manifestPath); err != nil {
+			return err
+		}
+		numbers = m.scanPayloads()
+	}
+	var key *ecdh.PrivateKey
+	if *decryptKey != "" {
+		var err error
+		if key, err = loadSecretKey(*decryptKey); err != nil {
+			return err
+		}
+	}
+
+	target, err := lookupTarget(*targetName)
+	if err != nil {
+		return err
+	}
+
+	port, err := openScanner(*device, *baud)
+	if err != nil {
+		return err
+	}
+	defer port.Close()
+
+	kb, err := newKeyboard()
+	if err != nil {
+		return err
+	}
+	defer kb.Close()
+
+	log.Printf("typer: reading scans from %s", *device)
+
+	speaker := speech(m)
+	publisher, err := mqtt(m)
+	if err != nil {
+		return err
+	}
+	scans, err := scanLog(m)
+	if err != nil {
+		return err
+	}
+	open := func(scan string) (string, bool) {
+		msg, err := openScan(key, expandNumber(numbers, scan))
+		if err == nil {
+			msg, err = verifyScan(*signSecret, msg)
+		}
+		if err != nil {
+			// A bad scan shouldn't stop the typer.
+			log.Printf("typer: %v", err)
+			return "", false
+		}
+		return msg, true
+	}
+	return readMessages(port, *stripAIMIDs, open, func(scan, msg string) error {
+		msg = fields.resolve(msg)
+		speaker.announce(scan, msg)
+		publisher.publish(scan, msg)
+		scans.record(scan, msg)
+		if err := kb.Type(msg); err != nil {
+			return fmt.Errorf("typing %q: %w", msg, err)
+		}
+		if !*enter {
+			return nil
+		}
+		return kb.Press(target.SendKeys...)
+	})
+}
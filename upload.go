@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/arran4/chat-barcodes/sheet"
+)
+
+// uploadPDF renders l as a PDF and PUTs it to dest, such as a presigned
+// object storage or WebDAV URL, failing on non-2xx responses.
+func uploadPDF(dest string, l *layout) error {
+	var buf bytes.Buffer
+	if err := sheet.WritePDF(&buf, l.opts, l.cells); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, dest, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/pdf")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", publicURL(dest), resp.Status)
+	}
+	return nil
+}
+
+// publicURL returns an upload URL without its query, which for presigned
+// URLs holds the signature: the address a printed sheet can link to.
+func publicURL(upload string) string {
+	if i := strings.IndexAny(upload, "?#"); i >= 0 {
+		return upload[:i]
+	}
+	return upload
+}
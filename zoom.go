@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// zoomPayload encodes a zoommtg: link joining the meeting with the ID in
+// the meeting option, and pwd its passcode, in the Zoom app without going
+// through the browser. Zoom can't pre-fill in-meeting chat either; once
+// joined, text codes scanned with -target zoom type into it. host is the
+// account's vanity domain, zoom.us if unset.
+func zoomPayload(msg ChatMsg, opts map[string]string) (string, error) {
+	id := strings.NewReplacer(" ", "", "-", "").Replace(opts["meeting"])
+	if len(id) < 9 || len(id) > 11 || strings.Trim(id, "0123456789") != "" {
+		return "", fmt.Errorf("zoom payloads need -payload-opt meeting=…, a 9 to 11 digit meeting ID, not %q", opts["meeting"])
+	}
+	host := opts["host"]
+	if host == "" {
+		host = "zoom.us"
+	}
+	if host != "zoom.us" && !strings.HasSuffix(host, ".zoom.us") {
+		return "", fmt.Errorf("zoom host %q is not zoom.us or a subdomain of it", host)
+	}
+	q := url.Values{"action": {"join"}, "confno": {id}}
+	if pwd := opts["pwd"]; pwd != "" {
+		q.Set("pwd", pwd)
+	}
+	return "zoommtg://" + host + "/join?" + q.Encode(), nil
+}